@@ -0,0 +1,115 @@
+package diffmatchpatch
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// byteDMP returns a copy of dmp that treats texts as bytes.
+func (dmp *DiffMatchPatch) byteDMP() *DiffMatchPatch {
+	b := *dmp
+	b.byteMode = true
+	return &b
+}
+
+// diffMainBytes finds the differences between two texts byte by byte.
+func (dmp *DiffMatchPatch) diffMainBytes(ctx context.Context, text1, text2 string, deadline time.Time) []Diff {
+	d := &differ[byte]{dmp: dmp, ctx: ctx, deadline: deadline}
+	edits := d.main([]byte(text1), []byte(text2), false)
+	diffs := make([]Diff, 0, len(edits))
+	for _, e := range edits {
+		diffs = append(diffs, Diff{e.Type, string(e.Items)})
+	}
+	return dmp.DiffCleanupMerge(diffs)
+}
+
+// DiffBytes finds the differences between two byte slices, which unlike
+// the texts of DiffMain need not be valid UTF-8.  The Text of each Diff
+// holds the bytes it covers.
+func (dmp *DiffMatchPatch) DiffBytes(a, b []byte) []Diff {
+	return dmp.byteDMP().DiffMain(string(a), string(b), false)
+}
+
+// PatchMakeBytes computes a list of patches to turn a into b.  The offsets
+// and lengths of the patches count bytes.
+func (dmp *DiffMatchPatch) PatchMakeBytes(a, b []byte) []Patch {
+	return dmp.byteDMP().PatchMakeFromTexts(string(a), string(b))
+}
+
+// PatchApplyBytes applies patches made by PatchMakeBytes to data as
+// PatchApply does to a text.  It returns the patched data and which patches
+// were applied.
+func (dmp *DiffMatchPatch) PatchApplyBytes(patches []Patch, data []byte) ([]byte, []bool) {
+	text, results := dmp.byteDMP().PatchApply(patches, string(data))
+	return []byte(text), results
+}
+
+// DiffToBinaryDelta encodes diffs compactly as the operations that turn
+// the source into the destination: each is one of the bytes '=', '-' and
+// '+' followed by a length in bytes as an unsigned varint, and for
+// insertions the inserted bytes.
+func (dmp *DiffMatchPatch) DiffToBinaryDelta(diffs []Diff) []byte {
+	var delta bytes.Buffer
+	var length [binary.MaxVarintLen64]byte
+	for _, aDiff := range diffs {
+		switch aDiff.Type {
+		case DiffInsert:
+			delta.WriteByte('+')
+		case DiffDelete:
+			delta.WriteByte('-')
+		default:
+			delta.WriteByte('=')
+		}
+		delta.Write(length[:binary.PutUvarint(length[:], uint64(len(aDiff.Text)))])
+		if aDiff.Type == DiffInsert {
+			delta.WriteString(aDiff.Text)
+		}
+	}
+	return delta.Bytes()
+}
+
+// DiffFromBinaryDelta rebuilds the diffs encoded by DiffToBinaryDelta from
+// the source a and the delta.
+func (dmp *DiffMatchPatch) DiffFromBinaryDelta(a, delta []byte) ([]Diff, error) {
+	diffs := []Diff{}
+	pointer := 0 // Cursor in a
+	for len(delta) != 0 {
+		op := delta[0]
+		n, size := binary.Uvarint(delta[1:])
+		if size <= 0 {
+			return diffs, errors.New("Invalid length in DiffFromBinaryDelta")
+		}
+		delta = delta[1+size:]
+
+		switch op {
+		case '+':
+			if n > uint64(len(delta)) {
+				return diffs, errors.New("Insertion longer than the rest of the delta in DiffFromBinaryDelta")
+			}
+			diffs = append(diffs, Diff{DiffInsert, string(delta[:n])})
+			delta = delta[n:]
+		case '=', '-':
+			if n > uint64(len(a)-pointer) {
+				return diffs, errors.New("Delta length exceeds source length in DiffFromBinaryDelta")
+			}
+			text := string(a[pointer : pointer+int(n)])
+			pointer += int(n)
+			if op == '=' {
+				diffs = append(diffs, Diff{DiffEqual, text})
+			} else {
+				diffs = append(diffs, Diff{DiffDelete, text})
+			}
+		default:
+			return diffs, errors.New("Invalid diff operation in DiffFromBinaryDelta: " + strconv.Quote(string(op)))
+		}
+	}
+
+	if pointer != len(a) {
+		return diffs, errors.New("Delta length (" + strconv.Itoa(pointer) + ") smaller than source length (" + strconv.Itoa(len(a)) + ").")
+	}
+	return diffs, nil
+}
@@ -0,0 +1,84 @@
+package diffmatchpatch
+
+import (
+	"github.com/bmizerany/assert"
+	"math/rand"
+	"testing"
+)
+
+func Test_diffBytes(t *testing.T) {
+	dmp := createDMP()
+	a := []byte{0xff, 0x00, 0x80, 0xe4, 0xb8}
+	b := []byte{0xff, 0x01, 0x80, 0xe4, 0xb8, 0xad}
+	assert.Equal(t, []Diff{
+		Diff{DiffEqual, "\xff"},
+		Diff{DiffDelete, "\x00"},
+		Diff{DiffInsert, "\x01"},
+		Diff{DiffEqual, "\x80\xe4\xb8"},
+		Diff{DiffInsert, "\xad"}}, dmp.DiffBytes(a, b))
+
+	assert.Equal(t, []Diff{}, dmp.DiffBytes(nil, nil))
+}
+
+func Test_patchBytes(t *testing.T) {
+	dmp := createDMP()
+	r := rand.New(rand.NewSource(1))
+	a := make([]byte, 1000)
+	r.Read(a)
+	// Continuation bytes at the start, where the context stops.
+	a[0], a[1] = 0x80, 0x81
+	b := append([]byte(nil), a...)
+	b[2] ^= 0xff
+	b[500] ^= 0xff
+	b = append(b[:700], b[710:]...)
+
+	patches := dmp.PatchMakeBytes(a, b)
+	assert.Equal(t, 3, len(patches))
+	patched, results := dmp.PatchApplyBytes(patches, a)
+	assert.Equal(t, b, patched)
+	assert.Equal(t, []bool{true, true, true}, results)
+
+	// Patches apply to shifted data too.
+	shifted := append([]byte{0x80, 0x80, 0x80}, a...)
+	patched, _ = dmp.PatchApplyBytes(patches, shifted)
+	assert.Equal(t, append([]byte{0x80, 0x80, 0x80}, b...), patched)
+}
+
+func Test_binaryDelta(t *testing.T) {
+	dmp := createDMP()
+	a := []byte("\xffjumps over the lazy\x00")
+	diffs := []Diff{
+		Diff{DiffEqual, "\xffjump"},
+		Diff{DiffDelete, "s"},
+		Diff{DiffInsert, "ed"},
+		Diff{DiffEqual, " over "},
+		Diff{DiffDelete, "the"},
+		Diff{DiffInsert, "a"},
+		Diff{DiffEqual, " lazy\x00"}}
+	delta := dmp.DiffToBinaryDelta(diffs)
+	assert.Equal(t, []byte("=\x05-\x01+\x02ed=\x06-\x03+\x01a=\x06"), delta)
+	result, err := dmp.DiffFromBinaryDelta(a, delta)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, diffs, result)
+
+	// Lengths above 127 take more than one byte.
+	long := make([]byte, 300)
+	delta = dmp.DiffToBinaryDelta([]Diff{Diff{DiffEqual, string(long)}})
+	assert.Equal(t, []byte{'=', 0xac, 0x02}, delta)
+
+	// Generates error (source too long).
+	_, err = dmp.DiffFromBinaryDelta(append(a, 'x'), dmp.DiffToBinaryDelta(diffs))
+	assert.NotEqual(t, nil, err)
+	// Generates error (source too short).
+	_, err = dmp.DiffFromBinaryDelta(a[1:], dmp.DiffToBinaryDelta(diffs))
+	assert.NotEqual(t, nil, err)
+	// Generates error (truncated insertion).
+	_, err = dmp.DiffFromBinaryDelta(nil, []byte("+\x05ab"))
+	assert.NotEqual(t, nil, err)
+	// Generates error (truncated length).
+	_, err = dmp.DiffFromBinaryDelta(nil, []byte("+"))
+	assert.NotEqual(t, nil, err)
+	// Generates error (invalid operation).
+	_, err = dmp.DiffFromBinaryDelta(nil, []byte("*\x00"))
+	assert.NotEqual(t, nil, err)
+}
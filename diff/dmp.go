@@ -18,12 +18,13 @@ package diffmatchpatch
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"math"
 	"net/url"
-	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -105,21 +106,45 @@ type DiffMatchPatch struct {
 	DiffTimeout float64
 	// Cost of an empty edit operation in terms of edit characters.
 	DiffEditCost int
+	// The algorithm that finds the differences (AlgorithmMyers by default).
+	DiffAlgorithm Algorithm
+	// Rough limit in bytes on the working memory of a diff (0 for no
+	// limit).  Texts too large for it are diffed line by line or in chunks
+	// of lines rather than character by character.
+	DiffMaxMemory int
 	// How far to search for a match (0 = exact location, 1000+ = broad match).
 	// A match this many characters away from the expected location will add
 	// 1.0 to the score (0.0 is a perfect match).
 	MatchDistance int
-	// When deleting a large block of text (over ~64 characters), how close do
-	// the contents have to be to match the expected contents. (0.0 = perfection,
-	// 1.0 = very loose).  Note that Match_Threshold controls how closely the
+	// When deleting a large block of text (over MatchMaxBits characters), how
+	// close do the contents have to be to match the expected contents. (0.0 =
+	// perfection, 1.0 = very loose).  Note that Match_Threshold controls how closely the
 	// end points of a delete need to match.
 	PatchDeleteThreshold float64
 	// Chunk size for context length.
 	PatchMargin int
-	// The number of bits in an int.
+	// The longest text a patch is located by as a whole.  Longer patches
+	// are split, and long deletions are located by their ends.
 	MatchMaxBits int
 	// At what point is no match declared (0.0 = perfection, 1.0 = very loose).
 	MatchThreshold float64
+	// Scores candidate matches (nil for ProximityScorer with MatchDistance).
+	MatchScorer MatchScorer
+	// Folds texts before diffs, matches and patches compare them (nil to
+	// compare them as they are).  Diffs keep the original texts, those of
+	// text1 for equalities.
+	Folder Folder
+	// Source of the current time for DiffTimeout (nil for the system clock).
+	Clock Clock
+	// byteMode makes diffs and patches treat texts as bytes rather than
+	// characters; it is set on the copies the []byte API works with.
+	byteMode bool
+}
+
+// Clock tells the time.  Substituting a fake Clock makes DiffTimeout
+// deterministic.
+type Clock interface {
+	Now() time.Time
 }
 
 // Diff represents one diff operation
@@ -143,28 +168,114 @@ type Patch struct {
 	length2 int
 }
 
+// NewPatch returns a patch that applies diffs at offset start1 of the
+// source text and offset start2 of the destination text.  The lengths of
+// the affected ranges are derived from diffs.
+func NewPatch(start1, start2 int, diffs []Diff) Patch {
+	patch := Patch{
+		diffs:  append([]Diff(nil), diffs...),
+		start1: start1,
+		start2: start2,
+	}
+	for _, aDiff := range diffs {
+		if aDiff.Type != DiffInsert {
+			patch.length1 += len(aDiff.Text)
+		}
+		if aDiff.Type != DiffDelete {
+			patch.length2 += len(aDiff.Text)
+		}
+	}
+	return patch
+}
+
+// Diffs returns a copy of the diffs that make up the patch, including its
+// context.
+func (patch *Patch) Diffs() []Diff {
+	return append([]Diff(nil), patch.diffs...)
+}
+
+// Range1 returns the offset and length of the text the patch replaces in
+// the source text.
+func (patch *Patch) Range1() (start, length int) {
+	return patch.start1, patch.length1
+}
+
+// Range2 returns the offset and length of the replacement text in the
+// destination text.
+func (patch *Patch) Range2() (start, length int) {
+	return patch.start2, patch.length2
+}
+
+// Equal reports whether two patches cover the same ranges with the same
+// diffs.
+func (patch *Patch) Equal(other Patch) bool {
+	if patch.start1 != other.start1 || patch.start2 != other.start2 ||
+		patch.length1 != other.length1 || patch.length2 != other.length2 ||
+		len(patch.diffs) != len(other.diffs) {
+		return false
+	}
+	for i, aDiff := range patch.diffs {
+		if aDiff != other.diffs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks that the patch's ranges are consistent with its diffs.
+func (patch *Patch) Validate() error {
+	if patch.start1 < 0 || patch.start2 < 0 {
+		return fmt.Errorf("Negative start in patch: %d, %d", patch.start1, patch.start2)
+	}
+	length1, length2 := 0, 0
+	for _, aDiff := range patch.diffs {
+		switch aDiff.Type {
+		case DiffDelete:
+			length1 += len(aDiff.Text)
+		case DiffInsert:
+			length2 += len(aDiff.Text)
+		case DiffEqual:
+			length1 += len(aDiff.Text)
+			length2 += len(aDiff.Text)
+		default:
+			return fmt.Errorf("Invalid diff operation in patch: %d", aDiff.Type)
+		}
+	}
+	if length1 != patch.length1 {
+		return fmt.Errorf("Patch length1 is %d but its diffs cover %d", patch.length1, length1)
+	}
+	if length2 != patch.length2 {
+		return fmt.Errorf("Patch length2 is %d but its diffs cover %d", patch.length2, length2)
+	}
+	return nil
+}
+
 // String emulates GNU diff's format.
 // Header: @@ -382,8 +481,9 @@
 // Indicies are printed as 1-based, not 0-based.
 func (patch *Patch) String() string {
+	return patch.format(patch.start1, patch.length1, patch.start2, patch.length2)
+}
+
+// format renders the patch with the given header coordinates, which may
+// be counted in a unit other than bytes.
+func (patch *Patch) format(start1, length1, start2, length2 int) string {
 	var coords1, coords2 string
-	start1 := int64(patch.start1)
-	start2 := int64(patch.start2)
 
-	if patch.length1 == 0 {
-		coords1 = strconv.FormatInt(start1, 10) + ",0"
-	} else if patch.length1 == 1 {
-		coords1 = strconv.FormatInt(start1+1, 10)
+	if length1 == 0 {
+		coords1 = strconv.Itoa(start1) + ",0"
+	} else if length1 == 1 {
+		coords1 = strconv.Itoa(start1 + 1)
 	} else {
-		coords1 = strconv.FormatInt(start1+1, 10) + "," + strconv.FormatInt(int64(patch.length1), 10)
+		coords1 = strconv.Itoa(start1+1) + "," + strconv.Itoa(length1)
 	}
 
-	if patch.length2 == 0 {
-		coords2 = strconv.FormatInt(start2, 10) + ",0"
-	} else if patch.length2 == 1 {
-		coords2 = strconv.FormatInt(start2+1, 10)
+	if length2 == 0 {
+		coords2 = strconv.Itoa(start2) + ",0"
+	} else if length2 == 1 {
+		coords2 = strconv.Itoa(start2 + 1)
 	} else {
-		coords2 = strconv.FormatInt(start2+1, 10) + "," + strconv.FormatInt(int64(patch.length2), 10)
+		coords2 = strconv.Itoa(start2+1) + "," + strconv.Itoa(length2)
 	}
 
 	var text bytes.Buffer
@@ -191,10 +302,11 @@ func (patch *Patch) String() string {
 	return unescaper.Replace(text.String())
 }
 
+// createDMP returns a DiffMatchPatch holding the defaults that New starts from.
 func createDMP() DiffMatchPatch {
 	dmp := DiffMatchPatch{}
 	// Defaults.
-	// Set these on your diff_match_patch instance to override the defaults.
+	// Pass options to New to override the defaults.
 
 	// Number of seconds to map a diff before giving up (0 for infinity).
 	dmp.DiffTimeout = 1.0
@@ -206,358 +318,302 @@ func createDMP() DiffMatchPatch {
 	// A match this many characters away from the expected location will add
 	// 1.0 to the score (0.0 is a perfect match).
 	dmp.MatchDistance = 1000
-	// When deleting a large block of text (over ~64 characters), how close
-	// do the contents have to be to match the expected contents. (0.0 =
-	// perfection, 1.0 = very loose).  Note that Match_Threshold controls
+	// When deleting a large block of text (over MatchMaxBits characters),
+	// how close do the contents have to be to match the expected contents.
+	// (0.0 = perfection, 1.0 = very loose).  Note that Match_Threshold controls
 	// how closely the end points of a delete need to match.
 	dmp.PatchDeleteThreshold = 0.5
 	// Chunk size for context length.
 	dmp.PatchMargin = 4
 
-	// The number of bits in an int.
-	dmp.MatchMaxBits = 32
+	// MatchBitap takes patterns of any length, so patches are only split
+	// and located by their ends when they are long.
+	dmp.MatchMaxBits = 1000
 
 	return dmp
 }
 
-// DiffMain finds the differences between two texts.
+// CleanupMode selects the cleanup pass DiffMainWithOptions runs over its
+// result.
+type CleanupMode int
+
+const (
+	// CleanupNone only merges adjacent edits, as DiffMain does.
+	CleanupNone CleanupMode = iota
+	// CleanupSemantic applies DiffCleanupSemantic.
+	CleanupSemantic
+	// CleanupSemanticLossless applies DiffCleanupSemanticLossless.
+	CleanupSemanticLossless
+	// CleanupEfficiency applies DiffCleanupEfficiency.
+	CleanupEfficiency
+)
+
+// Algorithm selects how DiffMain finds the differences between two texts.
+type Algorithm int
+
+const (
+	// AlgorithmMyers bisects the texts as in Myers' O(ND) algorithm, which
+	// finds a minimal diff.
+	AlgorithmMyers Algorithm = iota
+	// AlgorithmPatience lines up the items that occur exactly once in both
+	// texts first, as git diff --patience does, and only bisects the gaps
+	// between them.  The diffs aren't always minimal but tend to be easier
+	// to read for source code.
+	AlgorithmPatience
+	// AlgorithmHistogram repeatedly lines up the longest run of items that
+	// are rare in the first text, as git diff --histogram does.  It is
+	// usually faster than AlgorithmPatience and copes better with common
+	// items that occur a few times.
+	AlgorithmHistogram
+)
+
+// DiffOptions controls a single call to DiffMainWithOptions.
+type DiffOptions struct {
+	// CheckLines enables the line-level speedup for long texts.
+	CheckLines bool
+	// Deadline is the time after which the diff is abandoned.  The zero
+	// value means DiffTimeout seconds from the start of the call.
+	Deadline time.Time
+	// Cleanup is the cleanup pass to run over the result.
+	Cleanup CleanupMode
+}
+
+// DiffMain finds the differences between two texts, using the line-level
+// speedup for long texts and giving up after DiffTimeout seconds.
+//
+// For backwards compatibility DiffMain also accepts checklines (a bool) and
+// a deadline (a time.Time) as optional arguments; arguments of any other
+// type are ignored.  Passing them is deprecated, use DiffMainWithOptions.
 func (dmp *DiffMatchPatch) DiffMain(text1 string, text2 string, opt ...interface{}) []Diff {
-	checklines := true
-	var deadline int32
+	opts := DiffOptions{CheckLines: true}
 
-	if opt != nil && len(opt) > 0 {
-		checklines = opt[0].(bool)
+	if len(opt) > 0 {
+		if checklines, ok := opt[0].(bool); ok {
+			opts.CheckLines = checklines
+		}
 
 		if len(opt) > 1 {
-			deadline = opt[1].(int32)
-		} else {
-			if dmp.DiffTimeout <= 0 {
-				deadline = max32
-			} else {
-				deadline = int32(int(time.Now().Unix()) + int(dmp.DiffTimeout*1000))
+			if deadline, ok := opt[1].(time.Time); ok {
+				opts.Deadline = deadline
 			}
 		}
 	}
 
-	diffs := []Diff{}
-	if text1 == text2 {
-		if len(text1) > 0 {
-			diffs = append(diffs, Diff{DiffEqual, text1})
-		}
-		return diffs
-	}
-
-	commonlength := dmp.DiffCommonPrefix(text1, text2)
-	commonprefix := text1[0:commonlength]
-	text1 = text1[commonlength:]
-	text2 = text2[commonlength:]
+	return dmp.DiffMainWithOptions(text1, text2, opts)
+}
 
-	// Trim off common suffix (speedup).
-	commonlength = dmp.DiffCommonSuffix(text1, text2)
-	commonsuffix := text1[len(text1)-commonlength:]
+// DiffMainWithOptions finds the differences between two texts as configured
+// by opts.
+func (dmp *DiffMatchPatch) DiffMainWithOptions(text1, text2 string, opts DiffOptions) []Diff {
+	diffs, _ := dmp.DiffMainContextWithOptions(context.Background(), text1, text2, opts)
+	return diffs
+}
 
-	text1 = text1[0 : len(text1)-commonlength]
-	text2 = text2[0 : len(text2)-commonlength]
+// DiffMainContext finds the differences between two texts, giving up when
+// ctx is done or DiffTimeout expires, whichever comes first. If ctx is done
+// before the diff is complete, the best-effort diff computed so far is
+// returned together with ctx.Err(); it still transforms text1 into text2,
+// it just isn't minimal.
+func (dmp *DiffMatchPatch) DiffMainContext(ctx context.Context, text1, text2 string) ([]Diff, error) {
+	return dmp.DiffMainContextWithOptions(ctx, text1, text2, DiffOptions{CheckLines: true})
+}
 
-	// Compute the diff on the middle block.
-	diffs = dmp.diffCompute(text1, text2, checklines, deadline)
-	// Restore the prefix and suffix.
-	if len(commonprefix) != 0 {
-		diffs = append([]Diff{Diff{DiffEqual, commonprefix}}, diffs...)
+// DiffMainContextWithOptions is like DiffMainContext but configured by
+// opts.
+func (dmp *DiffMatchPatch) DiffMainContextWithOptions(ctx context.Context, text1, text2 string, opts DiffOptions) ([]Diff, error) {
+	deadline := opts.Deadline
+	if deadline.IsZero() {
+		deadline = dmp.deadline()
 	}
 
-	if len(commonsuffix) != 0 {
-		diffs = append(diffs, Diff{DiffEqual, commonsuffix})
-	}
+	diffs := dmp.diffMain(ctx, text1, text2, opts.CheckLines, deadline)
 
-	return dmp.DiffCleanupMerge(diffs)
+	switch opts.Cleanup {
+	case CleanupSemantic:
+		diffs = dmp.DiffCleanupSemantic(diffs)
+	case CleanupSemanticLossless:
+		diffs = dmp.DiffCleanupSemanticLossless(diffs)
+	case CleanupEfficiency:
+		diffs = dmp.DiffCleanupEfficiency(diffs)
+	}
+	return diffs, ctx.Err()
 }
 
-// diffCompute finds the differences between two texts.  Assumes that the texts do not
-// have any common prefix or suffix.
-func (dmp *DiffMatchPatch) diffCompute(text1 string, text2 string, checklines bool, deadline int32) []Diff {
-	diffs := []Diff{}
-	if len(text1) == 0 {
-		// Just add some text (speedup).
-		return append(diffs, Diff{DiffInsert, text2})
+func (dmp *DiffMatchPatch) diffMain(ctx context.Context, text1, text2 string, checklines bool, deadline time.Time) []Diff {
+	if dmp.byteMode {
+		return dmp.diffMainBytes(ctx, text1, text2, deadline)
 	}
-
-	if len(text2) == 0 {
-		// Just delete some text (speedup).
-		return append(diffs, Diff{DiffInsert, text1})
+	if !dmp.fits(utf8.RuneCountInString(text1), utf8.RuneCountInString(text2), dmp.charSize()) {
+		return dmp.diffBounded(ctx, text1, text2, checklines, deadline)
 	}
+	return dmp.diffChars(ctx, text1, text2, checklines, deadline)
+}
 
-	var longtext, shorttext string
-
-	if utf8.RuneCountInString(text1) > utf8.RuneCountInString(text2) {
-		longtext = text1
-		shorttext = text2
-	} else {
-		longtext = text2
-		shorttext = text1
+// diffChars finds the differences between two texts character by
+// character, as Folder sees them if there is one.
+func (dmp *DiffMatchPatch) diffChars(ctx context.Context, text1, text2 string, checklines bool, deadline time.Time) []Diff {
+	if dmp.Folder != nil {
+		return dmp.diffFolded(ctx, text1, text2, checklines, deadline)
 	}
+	return dmp.diffMainRunes(ctx, []rune(text1), []rune(text2), checklines, deadline)
+}
 
-	var i = strings.Index(longtext, shorttext)
-	if i != -1 {
-		var op int8 = DiffInsert
-		// Swap insertions for deletions if diff is reversed.
-		if utf8.RuneCountInString(text1) > utf8.RuneCountInString(text2) {
-			op = DiffDelete
-		}
-		// Shorter text is inside the longer text (speedup).
-		diffs = []Diff{
-			Diff{op, longtext[0:i]},
-			Diff{DiffEqual, shorttext},
-			Diff{op, longtext[i+utf8.RuneCountInString(shorttext):]},
-		}
+// diffMainRunes finds the differences between two rune slices.  Working on
+// runes rather than bytes guarantees that no diff boundary falls inside a
+// code point.
+func (dmp *DiffMatchPatch) diffMainRunes(ctx context.Context, text1, text2 []rune, checklines bool, deadline time.Time) []Diff {
+	edits := dmp.runeDiffer(ctx, deadline).main(text1, text2, checklines)
+	return dmp.DiffCleanupMerge(editsToDiffs(edits))
+}
 
-		return diffs
-	}
-	if utf8.RuneCountInString(shorttext) == 1 {
-		// Single character string.
-		// After the previous speedup, the character can't be an equality.
-		return []Diff{
-			Diff{DiffDelete, text1},
-			Diff{DiffInsert, text2},
+// runeDiffer returns a differ for runes that falls back on diffLineMode for
+// long texts.
+func (dmp *DiffMatchPatch) runeDiffer(ctx context.Context, deadline time.Time) *differ[rune] {
+	d := &differ[rune]{dmp: dmp, ctx: ctx, deadline: deadline}
+	d.lineMode = func(text1, text2 []rune) []Edit[rune] {
+		var edits []Edit[rune]
+		for _, aDiff := range dmp.diffLineMode(ctx, string(text1), string(text2), deadline) {
+			edits = append(edits, Edit[rune]{aDiff.Type, []rune(aDiff.Text)})
 		}
+		return edits
 	}
-	// Check to see if the problem can be split in two.
-	hm := dmp.DiffHalfMatch(text1, text2)
-	if hm != nil {
-		// A half-match was found, sort out the return data.
-		text1_a := hm[0]
-		text1_b := hm[1]
-		text2_a := hm[2]
-		text2_b := hm[3]
-		mid_common := hm[4]
-		// Send both pairs off for separate processing.
-		diffs_a := dmp.DiffMain(text1_a, text2_a, checklines, deadline)
-		diffs_b := dmp.DiffMain(text1_b, text2_b, checklines, deadline)
-		// Merge the results.
-		// TODO: Concat should accept several arguments
-		concat1 := concat(diffs_a, []Diff{Diff{DiffEqual, mid_common}})
-		return concat(concat1, diffs_b)
-	}
-	if checklines && utf8.RuneCountInString(text1) > 100 && utf8.RuneCountInString(text2) > 100 {
-		return dmp.diffLineMode(text1, text2, deadline)
+	return d
+}
+
+// editsToDiffs converts the edits of a rune diff to a diff.
+func editsToDiffs(edits []Edit[rune]) []Diff {
+	diffs := make([]Diff, 0, len(edits))
+	for _, e := range edits {
+		diffs = append(diffs, Diff{e.Type, string(e.Items)})
 	}
-	return dmp.DiffBisect(text1, text2, deadline)
+	return diffs
 }
 
 // diffLineMode does a quick line-level diff on both strings, then rediff the parts for
 // greater accuracy. This speedup can produce non-minimal diffs.
-func (dmp *DiffMatchPatch) diffLineMode(text1 string, text2 string, deadline int32) []Diff {
+func (dmp *DiffMatchPatch) diffLineMode(ctx context.Context, text1, text2 string, deadline time.Time) []Diff {
 	// Scan the text on a line-by-line basis first.
-	text1, text2, linearray := dmp.DiffLinesToChars(text1, text2)
+	chars1, chars2, _, lines1, lines2 := dmp.diffLinesToChars(text1, text2)
 
-	diffs := dmp.DiffMain(text1, text2, false, deadline)
+	diffs := dmp.diffMainRunes(ctx, []rune(chars1), []rune(chars2), false, deadline)
 
 	// Convert the diff back to original text.
-	diffs = dmp.DiffCharsToLines(diffs, linearray)
+	diffs = diffCharsToTokens(diffs, lines1, lines2)
 	// Eliminate freak matches (e.g. blank lines)
-	dmp.DiffCleanupSemantic(diffs)
+	diffs = dmp.DiffCleanupSemantic(diffs)
 
 	// Rediff any replacement blocks, this time character-by-character.
-	// Add a dummy entry at the end.
-	diffs = append(diffs, Diff{DiffEqual, ""})
-
-	pointer := 0
-	count_delete := 0
-	count_insert := 0
-	text_delete := ""
-	text_insert := ""
-
-	for pointer < len(diffs) {
-		switch diffs[pointer].Type {
-		case DiffInsert:
-			count_insert++
-			text_insert += diffs[pointer].Text
-			break
-
-		case DiffDelete:
-			count_delete++
-			text_delete += diffs[pointer].Text
-			break
-
-		case DiffEqual:
-			// Upon reaching an equality, check for prior redundancies.
-			if count_delete >= 1 && count_insert >= 1 {
-				// Delete the offending records and add the merged ones.
-				diffs := splice(diffs, pointer-count_delete-count_insert,
-					count_delete+count_insert)
-
-				pointer = pointer - count_delete - count_insert
-				a := dmp.DiffMain(text_delete, text_insert, false, deadline)
-				for j := len(a) - 1; j >= 0; j-- {
-					diffs = splice(diffs, pointer, 0, a[j])
-				}
-				pointer = pointer + len(a)
-			}
+	return dmp.diffRediff(ctx, diffs, deadline)
+}
 
-			//count_insert := 0
-			//count_delete := 0
-			//text_delete := ""
-			//text_insert := ""
-			break
+// diffRediff diffs the deletions and insertions between each pair of
+// equalities of a coarse diff character by character, unless that would
+// exceed DiffMaxMemory.
+func (dmp *DiffMatchPatch) diffRediff(ctx context.Context, diffs []Diff, deadline time.Time) []Diff {
+	result := make([]Diff, 0, len(diffs))
+	start := 0
+	for i := 0; i <= len(diffs); i++ {
+		if i < len(diffs) && diffs[i].Type != DiffEqual {
+			continue
 		}
-		pointer++
+		textDelete := joinDiffs(diffs[start:i], DiffDelete)
+		textInsert := joinDiffs(diffs[start:i], DiffInsert)
+		if len(textDelete) != 0 && len(textInsert) != 0 &&
+			dmp.fits(utf8.RuneCountInString(textDelete), utf8.RuneCountInString(textInsert), dmp.charSize()) {
+			// Slice the texts of the diff from those rediffed rather than
+			// keep the copies the diff makes.
+			result = append(result, dmp.mergeDiffs(dmp.diffMain(ctx, textDelete, textInsert, false, deadline), textDelete, textInsert)...)
+		} else {
+			result = append(result, diffs[start:i]...)
+		}
+		if i < len(diffs) {
+			result = append(result, diffs[i])
+		}
+		start = i + 1
 	}
+	return result
+}
 
-	return diffs[:len(diffs)-1] // Remove the dummy entry at the end.
+// joinDiffs returns the text of the diffs of type op.
+func joinDiffs(diffs []Diff, op int8) string {
+	var texts []string
+	for _, aDiff := range diffs {
+		if aDiff.Type == op {
+			texts = append(texts, aDiff.Text)
+		}
+	}
+	return strings.Join(texts, "")
 }
 
 // DiffBisect finds the 'middle snake' of a diff, split the problem in two
 // and return the recursively constructed diff.
 // See Myers 1986 paper: An O(ND) Difference Algorithm and Its Variations.
-func (dmp *DiffMatchPatch) DiffBisect(text1 string, text2 string, deadline int32) []Diff {
-	// Cache the text lengths to prevent multiple calls.
-	text1_length := len(text1)
-	text2_length := len(text2)
-
-	max_d := int(math.Ceil(float64(((text1_length + text2_length) / 2))))
-	v_offset := max_d
-	v_length := 2 * max_d
-	v1 := make([]int, v_length)
-	v2 := make([]int, v_length)
-
-	v1[v_offset+1] = 0
-	v2[v_offset+1] = 0
-
-	delta := text1_length - text2_length
-	// If the total number of characters is odd, then the front path will collide
-	// with the reverse path.
-	front := (delta%2 != 0)
-	// Offsets for start and end of k loop.
-	// Prevents mapping of space beyond the grid.
-	k1start := 0
-	k1end := 0
-	k2start := 0
-	k2end := 0
-	for d := 0; d < max_d; d++ {
-		// Bail out if deadline is reached.
-		if int32(time.Now().Unix()) > deadline {
-			break
-		}
-
-		// Walk the front path one step.
-		for k1 := -d + k1start; k1 <= d-k1end; k1 += 2 {
-			var k1_offset = v_offset + k1
-			var x1 int
-
-			if k1 == -d || (k1 != d && v1[k1_offset-1] < v1[k1_offset+1]) {
-				x1 = v1[k1_offset+1]
-			} else {
-				x1 = v1[k1_offset-1] + 1
-			}
+// A zero deadline means the search never gives up.
+func (dmp *DiffMatchPatch) DiffBisect(text1 string, text2 string, deadline time.Time) []Diff {
+	d := dmp.runeDiffer(context.Background(), deadline)
+	return editsToDiffs(d.bisect([]rune(text1), []rune(text2)))
+}
 
-			y1 := x1 - k1
-			for x1 < text1_length && y1 < text2_length &&
-				text1[x1] == text2[y1] {
-				x1++
-				y1++
-			}
-			v1[k1_offset] = x1
-			if x1 > text1_length {
-				// Ran off the right of the graph.
-				k1end += 2
-			} else if y1 > text2_length {
-				// Ran off the bottom of the graph.
-				k1start += 2
-			} else if front {
-				k2_offset := v_offset + delta - k1
-				if k2_offset >= 0 && k2_offset < v_length && v2[k2_offset] != -1 {
-					// Mirror x2 onto top-left coordinate system.
-					x2 := text1_length - v2[k2_offset]
-					if x1 >= x2 {
-						// Overlap detected.
-						return dmp.diffBisectSplit_(text1, text2, x1, y1, deadline)
-					}
-				}
-			}
-		}
-		// Walk the reverse path one step.
-		for k2 := -d + k2start; k2 <= d-k2end; k2 += 2 {
-			k2_offset := v_offset + k2
-			var x2 int
-			if k2 == -d || (k2 != d && v2[k2_offset-1] < v2[k2_offset+1]) {
-				x2 = v2[k2_offset+1]
-			} else {
-				x2 = v2[k2_offset-1] + 1
-			}
-			var y2 = x2 - k2
-			for x2 < text1_length &&
-				y2 < text2_length &&
-				(text1[text1_length-x2-1] == text2[text2_length-y2-1]) {
-				x2++
-				y2++
-			}
-			v2[k2_offset] = x2
-			if x2 > text1_length {
-				// Ran off the left of the graph.
-				k2end += 2
-			} else if y2 > text2_length {
-				// Ran off the top of the graph.
-				k2start += 2
-			} else if !front {
-				k1_offset := v_offset + delta - k2
-				if k1_offset >= 0 && k1_offset < v_length && v1[k1_offset] != -1 {
-					x1 := v1[k1_offset]
-					y1 := v_offset + x1 - k1_offset
-					// Mirror x2 onto top-left coordinate system.
-					x2 = text1_length - x2
-					if x1 >= x2 {
-						// Overlap detected.
-						return dmp.diffBisectSplit_(text1, text2, x1, y1, deadline)
-					}
-				}
-			}
-		}
-	}
-	// Diff took too long and hit the deadline or
-	// number of diffs equals number of characters, no commonality at all.
-	return []Diff{
-		Diff{DiffDelete, text1},
-		Diff{DiffInsert, text2},
+// deadline returns the time at which a diff started now should give up, or
+// the zero Time if DiffTimeout is unlimited.
+func (dmp *DiffMatchPatch) deadline() time.Time {
+	if dmp.DiffTimeout <= 0 {
+		return time.Time{}
 	}
+	return dmp.now().Add(time.Duration(dmp.DiffTimeout * float64(time.Second)))
 }
 
-func (dmp *DiffMatchPatch) diffBisectSplit_(text1 string, text2 string, x int, y int, deadline int32) []Diff {
-	text1a := text1[0:x]
-	text2a := text2[0:y]
-	text1b := text1[x:]
-	text2b := text2[y:]
-
-	// Compute both diffs serially.
-	diffs := dmp.DiffMain(text1a, text2a, false, deadline)
-	diffsb := dmp.DiffMain(text1b, text2b, false, deadline)
+// expired reports whether a diff running against deadline should stop,
+// either because the deadline has passed or because ctx is done.
+func (dmp *DiffMatchPatch) expired(ctx context.Context, deadline time.Time) bool {
+	if ctx.Err() != nil {
+		return true
+	}
+	return !deadline.IsZero() && dmp.now().After(deadline)
+}
 
-	return append(diffs, diffsb...)
+func (dmp *DiffMatchPatch) now() time.Time {
+	if dmp.Clock != nil {
+		return dmp.Clock.Now()
+	}
+	return time.Now()
 }
 
 // DiffLinesToChars split two texts into a list of strings.  Reduces the texts to a string of
 // hashes where each Unicode character represents one line.
+// With a Folder, lines that are equal once folded share a character and
+// the list holds the first of them.  The characters are ones the Folder
+// leaves alone, so the reduced texts diff the same with or without it.
 func (dmp *DiffMatchPatch) DiffLinesToChars(text1 string, text2 string) (string, string, []string) {
+	chars1, chars2, lineArray, _, _ := dmp.diffLinesToChars(text1, text2)
+	return chars1, chars2, lineArray
+}
+
+// diffLinesToChars is DiffLinesToChars also returning the lines of either
+// text, for diffCharsToTokens.
+func (dmp *DiffMatchPatch) diffLinesToChars(text1, text2 string) (string, string, []string, []string, []string) {
 	// '\x00' is a valid character, but various debuggers don't like it.
 	// So we'll insert a junk entry to avoid generating a null character.
 	lineArray := []string{""}    // e.g. lineArray[4] == 'Hello\n'
 	lineHash := map[string]int{} // e.g. lineHash['Hello\n'] == 4
 
-	chars1 := dmp.diffLinesToCharsMunge(text1, &lineArray, lineHash)
-	chars2 := dmp.diffLinesToCharsMunge(text2, &lineArray, lineHash)
+	chars1, lines1 := dmp.diffLinesToCharsMunge(text1, &lineArray, lineHash)
+	chars2, lines2 := dmp.diffLinesToCharsMunge(text2, &lineArray, lineHash)
 
-	return chars1, chars2, lineArray
+	return chars1, chars2, lineArray, lines1, lines2
 }
 
 // diffLinesToCharsMunge splits a text into an array of strings.  Reduces the texts to a string of
-// hashes where each Unicode character represents one line.
+// hashes where each Unicode character represents one line, and returns the lines too.
 // Modifies linearray and linehash through being a closure.
-func (dmp *DiffMatchPatch) diffLinesToCharsMunge(text string, lineArray *[]string, lineHash map[string]int) string {
+func (dmp *DiffMatchPatch) diffLinesToCharsMunge(text string, lineArray *[]string, lineHash map[string]int) (string, []string) {
 	// Walk the text, pulling out a substring for each line.
 	// text.split('\n') would would temporarily double our memory footprint.
 	// Modifying text would create many large strings to garbage collect.
 	lineStart := 0
 	lineEnd := -1
 	runes := []rune{}
+	lines := []string{}
 
 	for lineEnd < len(text)-1 {
 		lineEnd = indexOf(text, "\n", lineStart)
@@ -568,98 +624,116 @@ func (dmp *DiffMatchPatch) diffLinesToCharsMunge(text string, lineArray *[]strin
 
 		line := text[lineStart : lineEnd+1]
 		lineStart = lineEnd + 1
-		lineValue_, ok := lineHash[line]
+		runes = append(runes, dmp.tokenRune(line, lineArray, lineHash))
+		lines = append(lines, line)
+	}
 
-		if ok {
-			runes = append(runes, rune(lineValue_))
-		} else {
-			*lineArray = append(*lineArray, line)
-			lineHash[line] = len(*lineArray) - 1
-			runes = append(runes, rune(len(*lineArray)-1))
-		}
+	return string(runes), lines
+}
+
+// tokenRune returns the character standing for token, adding the token to
+// tokenArray and tokenHash if it is new.  Tokens are compared folded if
+// there is a Folder, and the characters it would change are skipped,
+// holding empty entries of tokenArray.
+func (dmp *DiffMatchPatch) tokenRune(token string, tokenArray *[]string, tokenHash map[string]int) rune {
+	key := dmp.lineKey(token)
+	if i, ok := tokenHash[key]; ok {
+		return lineRune(i)
+	}
+	for dmp.Folder != nil && !dmp.foldStable(lineRune(len(*tokenArray))) {
+		*tokenArray = append(*tokenArray, "")
+	}
+	*tokenArray = append(*tokenArray, token)
+	tokenHash[key] = len(*tokenArray) - 1
+	return lineRune(len(*tokenArray) - 1)
+}
+
+// lineRune returns the character standing for line i of a lineArray.  The
+// surrogate range is skipped since those code points can't be stored in a
+// Go string.
+func lineRune(i int) rune {
+	if i >= 0xD800 {
+		return rune(i + 0x800)
 	}
+	return rune(i)
+}
 
-	return string(runes)
+// lineIndex is the inverse of lineRune.
+func lineIndex(r rune) int {
+	if r >= 0xE000 {
+		return int(r) - 0x800
+	}
+	return int(r)
 }
 
 // DiffCharsToLines rehydrates the text in a diff from a string of line hashes to real lines of
 // text.
 func (dmp *DiffMatchPatch) DiffCharsToLines(diffs []Diff, lineArray []string) []Diff {
-	for _, aDiff := range diffs {
-		chars := aDiff.Text
-		text := make([]string, len(chars))
-
-		for y := 0; y < len(chars); y++ {
-			unicodeCodePoints := []byte(chars)
-			text[y] = lineArray[unicodeCodePoints[y]]
+	for i, aDiff := range diffs {
+		var text bytes.Buffer
+		for _, r := range aDiff.Text {
+			text.WriteString(lineArray[lineIndex(r)])
 		}
+		diffs[i].Text = text.String()
+	}
+	return diffs
+}
 
-		aDiff.Text = strings.Join(text, "")
+// diffCharsToTokens is DiffCharsToLines for the tokens of the texts a diff
+// of their characters was made from, tokens1 and tokens2, in order.  The
+// tokens are taken by position, so those a Folder sees as equal keep their
+// own text: that of text1 for equalities.
+func diffCharsToTokens(diffs []Diff, tokens1, tokens2 []string) []Diff {
+	for i, aDiff := range diffs {
+		n := utf8.RuneCountInString(aDiff.Text)
+		if aDiff.Type == DiffInsert {
+			diffs[i].Text = strings.Join(tokens2[:n], "")
+			tokens2 = tokens2[n:]
+		} else {
+			diffs[i].Text = strings.Join(tokens1[:n], "")
+			tokens1 = tokens1[n:]
+			if aDiff.Type == DiffEqual {
+				tokens2 = tokens2[n:]
+			}
+		}
 	}
 	return diffs
 }
 
 // DiffCommonPrefix determines the common prefix length of two strings.
+// The length is in bytes and never ends inside a multibyte character.
 func (dmp *DiffMatchPatch) DiffCommonPrefix(text1 string, text2 string) int {
 	n := int(math.Min(float64(len(text1)), float64(len(text2))))
 	for i := 0; i < n; i++ {
 		if text1[i] != text2[i] {
+			// Back up to the start of the character that differs.
+			for i > 0 && !utf8.RuneStart(text1[i]) {
+				i--
+			}
 			return i
 		}
 	}
 	return n
-
-	// Binary search.
-	// Performance analysis: http://neil.fraser.name/news/2007/10/09/
-	/*
-	   pointermin := 0
-	   pointermax := math.Min(len(text1), len(text2))
-	   pointermid := pointermax
-	   pointerstart := 0
-	   for pointermin < pointermid {
-	       if text1[pointerstart:pointermid] ==
-	           text2[pointerstart:pointermid] {
-	           pointermin = pointermid
-	           pointerstart = pointermin
-	       } else {
-	           pointermax = pointermid
-	       }
-	       pointermid = math.Floor((pointermax-pointermin)/2 + pointermin)
-	   }
-	   return pointermid
-	*/
 }
 
 // DiffCommonSuffix determines the common suffix length of two strings.
+// The length is in bytes and never starts inside a multibyte character.
 func (dmp *DiffMatchPatch) DiffCommonSuffix(text1 string, text2 string) int {
 	text1_length := len(text1)
 	text2_length := len(text2)
-	n := math.Min(float64(text1_length), float64(text2_length))
-	for i := 1; i <= int(n); i++ {
+	n := int(math.Min(float64(text1_length), float64(text2_length)))
+	for i := 1; i <= n; i++ {
 		if text1[text1_length-i] != text2[text2_length-i] {
-			return i - 1
-		}
-	}
-	return int(n)
-	// Binary search.
-	// Performance analysis: http://neil.fraser.name/news/2007/10/09/
-	/*
-	   pointermin := 0
-	   pointermax := math.Min(len(text1), len(text2))
-	   pointermid := pointermax
-	   pointerend := 0
-	   for pointermin < pointermid {
-	       if text1[len(text1)-pointermid:len(text1)-pointerend] ==
-	           text2[len(text2)-pointermid:len(text2)-pointerend] {
-	           pointermin = pointermid
-	           pointerend = pointermin
-	       } else {
-	           pointermax = pointermid
-	       }
-	       pointermid = math.Floor((pointermax-pointermin)/2 + pointermin)
-	   }
-	   return pointermid
-	*/
+			// Move forward to the start of the character after the one
+			// that differs.
+			i--
+			for i > 0 && !utf8.RuneStart(text1[text1_length-i]) {
+				i--
+			}
+			return i
+		}
+	}
+	return n
 }
 
 // DiffCommonOverlap determines if the suffix of one string is the prefix of another.
@@ -700,117 +774,21 @@ func (dmp *DiffMatchPatch) DiffCommonOverlap(text1 string, text2 string) int {
 			length++
 		}
 	}
-	return 0
 }
 
-// DiffHalfMatch checks whether the two texts share a substring which is at 
+// DiffHalfMatch checks whether the two texts share a substring which is at
 // least half the length of the longer text. This speedup can produce non-minimal diffs.
 func (dmp *DiffMatchPatch) DiffHalfMatch(text1, text2 string) []string {
-	if dmp.DiffTimeout <= 0 {
-		// Don't risk returning a non-optimal diff if we have unlimited time.
+	runeSlices := dmp.runeDiffer(context.Background(), time.Time{}).halfMatch([]rune(text1), []rune(text2))
+	if runeSlices == nil {
 		return nil
 	}
 
-	var longtext, shorttext string
-	if len(text1) > len(text2) {
-		longtext = text1
-		shorttext = text2
-	} else {
-		longtext = text2
-		shorttext = text1
-	}
-
-	//TODO
-	if len(longtext) < 4 || len(shorttext)*2 < len(longtext) {
-		return nil // Pointless.
-	}
-
-	// First check if the second quarter is the seed for a half-match.
-	hm1 := dmp.diffHalfMatchI(longtext, shorttext, int(math.Ceil(float64(len(longtext)/4))))
-
-	// Check again based on the third quarter.
-	hm2 := dmp.diffHalfMatchI(longtext, shorttext, int(math.Ceil(float64(len(longtext)/2))))
-
-	hm := []string{}
-	if hm1 == nil && hm2 == nil {
-		return nil
-	} else if hm2 == nil {
-		hm = hm1
-	} else if hm1 == nil {
-		hm = hm2
-	} else {
-		// Both matched.  Select the longest.
-		if len(hm1[4]) > len(hm2[4]) {
-			hm = hm1
-		} else {
-			hm = hm2
-		}
-	}
-
-	// A half-match was found, sort out the return data.
-	if len(text1) > len(text2) {
-		return hm
-	} else {
-		return []string{hm[2], hm[3], hm[0], hm[1], hm[4]}
-	}
-
-	return nil
-}
-
-/**
- * Does a substring of shorttext exist within longtext such that the substring
- * is at least half the length of longtext?
- * Closure, but does not reference any external variables.
- * @param {string} longtext Longer string.
- * @param {string} shorttext Shorter string.
- * @param {number} i Start index of quarter length substring within longtext.
- * @return {Array.<string>} Five element Array, containing the prefix of
- *     longtext, the suffix of longtext, the prefix of shorttext, the suffix
- *     of shorttext and the common middle.  Or null if there was no match.
- * @private
- */
-func (dmp *DiffMatchPatch) diffHalfMatchI(l string, s string, i int) []string {
-	// Start with a 1/4 length substring at position i as a seed.
-	seed := l[i : i+len(l)/4]
-	j := -1
-	best_common := ""
-	best_longtext_a := ""
-	best_longtext_b := ""
-	best_shorttext_a := ""
-	best_shorttext_b := ""
-
-	if j < len(s) {
-		j = indexOf(s, seed, j+1)
-		for {
-			if j == -1 {
-				break
-			}
-
-			prefixLength := dmp.DiffCommonPrefix(l[i:], s[j:])
-			suffixLength := dmp.DiffCommonSuffix(l[0:i], s[0:j])
-
-			if len(best_common) < suffixLength+prefixLength {
-				best_common = s[j-suffixLength:j] + s[j:j+prefixLength]
-				best_longtext_a = l[0 : i-suffixLength]
-				best_longtext_b = l[i+prefixLength:]
-				best_shorttext_a = s[0 : j-suffixLength]
-				best_shorttext_b = s[j+prefixLength:]
-			}
-
-			j = indexOf(s, seed, j+1)
-		}
-	}
-
-	if len(best_common)*2 >= len(l) {
-		return []string{
-			best_longtext_a,
-			best_longtext_b,
-			best_shorttext_a,
-			best_shorttext_b,
-			best_common,
-		}
+	result := make([]string, len(runeSlices))
+	for i, r := range runeSlices {
+		result[i] = string(r)
 	}
-	return nil
+	return result
 }
 
 // Diff_cleanupSemantic reduces the number of edits by eliminating 
@@ -859,8 +837,11 @@ func (dmp *DiffMatchPatch) DiffCleanupSemantic(diffs []Diff) []Diff {
 				// Throw away the equality we just deleted.
 				equalities.Pop()
 
+				// Throw away the previous equality (it needs to be reevaluated).
 				if equalities.Len() > 0 {
 					equalities.Pop()
+				}
+				if equalities.Len() > 0 {
 					pointer = equalities.Peek().(int)
 				} else {
 					pointer = -1
@@ -918,7 +899,7 @@ func (dmp *DiffMatchPatch) DiffCleanupSemantic(diffs []Diff) []Diff {
 					// Insert an equality and swap and trim the surrounding edits.
 					diffs = append(
 						diffs[:pointer],
-						append([]Diff{Diff{DiffEqual, insertion[0:overlap_length2]}}, diffs[pointer:]...)...)
+						append([]Diff{Diff{DiffEqual, deletion[0:overlap_length2]}}, diffs[pointer:]...)...)
 					// diffs.splice(pointer, 0,
 					//     [DiffEqual, deletion[0 : overlap_length2)]]
 					diffs[pointer-1].Type = DiffInsert
@@ -957,16 +938,15 @@ func (dmp *DiffMatchPatch) DiffCleanupSemanticLossless(diffs []Diff) []Diff {
 			return 6
 		}
 
-		_one := []rune(one)
-		_two := []rune(two)
-
 		// Each port of this function behaves slightly differently due to
 		// subtle differences in each language's definition of things like
 		// 'whitespace'.  Since this function's purpose is largely cosmetic,
 		// the choice has been made to use each language's native features
 		// rather than force total conformity.
-		char1 := string(_one[len(one)-1])
-		char2 := string(_two[0])
+		rune1, _ := utf8.DecodeLastRuneInString(one)
+		rune2, _ := utf8.DecodeRuneInString(two)
+		char1 := string(rune1)
+		char2 := string(rune2)
 
 		nonAlphaNumeric1 := nonAlphaNumericRegex_.MatchString(char1)
 		nonAlphaNumeric2 := nonAlphaNumericRegex_.MatchString(char2)
@@ -1024,10 +1004,14 @@ func (dmp *DiffMatchPatch) DiffCleanupSemanticLossless(diffs []Diff) []Diff {
 			bestScore := diffCleanupSemanticScore_(equality1, edit) +
 				diffCleanupSemanticScore_(edit, equality2)
 
-			for len(edit) != 0 && len(equality2) != 0 && edit[0] == equality2[0] {
-				equality1 += string(edit[0])
-				edit = edit[1:] + string(equality2[0])
-				equality2 = equality2[1:]
+			for len(edit) != 0 && len(equality2) != 0 {
+				_, sz := utf8.DecodeRuneInString(edit)
+				if len(equality2) < sz || edit[:sz] != equality2[:sz] {
+					break
+				}
+				equality1 += edit[:sz]
+				edit = edit[sz:] + equality2[:sz]
+				equality2 = equality2[sz:]
 				score := diffCleanupSemanticScore_(equality1, edit) +
 					diffCleanupSemanticScore_(edit, equality2)
 				// The >= encourages trailing rather than leading whitespace on
@@ -1331,34 +1315,23 @@ func (dmp *DiffMatchPatch) DiffXIndex(diffs []Diff, loc int) int {
 	return last_chars2 + (loc - last_chars1)
 }
 
-/**
- * Convert a Diff list into a pretty HTML report.
- * @param diffs List of Diff objects.
- * @return HTML representation.
- */
-/*
-   func (dmp *DiffMatchPatch) diffPrettyHtml(diffs []Diff) {
-     StringBuilder html = new StringBuilder()
-     foreach (Diff aDiff in diffs) {
-       text := aDiff.text.Replace("&", "&amp;").Replace("<", "&lt;")
-         .Replace(">", "&gt;").Replace("\n", "&para;<br>")
-       switch (aDiff.Type) {
-         case DiffInsert:
-           html.Append("<ins style=\"background:#e6ffe6;\">").Append(text)
-               .Append("</ins>")
-           break
-         case DiffDelete:
-           html.Append("<del style=\"background:#ffe6e6;\">").Append(text)
-               .Append("</del>")
-           break
-         case DiffEqual:
-           html.Append("<span>").Append(text).Append("</span>")
-           break
-       }
-     }
-     return html.ToString()
-   }
-*/
+// DiffPrettyHtml converts a []Diff into a pretty HTML report.
+// It is intended as an example from which to write one's own
+// display functions; see HTMLRenderer for a configurable version.
+func (dmp *DiffMatchPatch) DiffPrettyHtml(diffs []Diff) string {
+	var buff bytes.Buffer
+	NewHTMLRenderer().Render(&buff, diffs)
+	return buff.String()
+}
+
+// DiffPrettyText converts a []Diff into a colored text report, with
+// insertions in green and deletions in red.  See TerminalRenderer for
+// palettes, line layout and detecting whether colour is wanted at all.
+func (dmp *DiffMatchPatch) DiffPrettyText(diffs []Diff) string {
+	var buff bytes.Buffer
+	(&TerminalRenderer{Palette: DefaultPalette}).Render(&buff, diffs)
+	return buff.String()
+}
 
 // Diff_text1 computes and returns the source text (all equalities and deletions).
 func (dmp *DiffMatchPatch) DiffText1(diffs []Diff) string {
@@ -1395,10 +1368,10 @@ func (dmp *DiffMatchPatch) DiffLevenshtein(diffs []Diff) int {
 	for _, aDiff := range diffs {
 		switch aDiff.Type {
 		case DiffInsert:
-			insertions += len(aDiff.Text)
+			insertions += utf8.RuneCountInString(aDiff.Text)
 			break
 		case DiffDelete:
-			deletions += len(aDiff.Text)
+			deletions += utf8.RuneCountInString(aDiff.Text)
 			break
 		case DiffEqual:
 			// A deletion and an insertion is one substitution.
@@ -1417,8 +1390,14 @@ func (dmp *DiffMatchPatch) DiffLevenshtein(diffs []Diff) int {
 // required to transform text1 into text2.
 // E.g. =3\t-2\t+ing  -> Keep 3 chars, delete 2 chars, insert 'ing'.
 // Operations are tab-separated.  Inserted text is escaped using %xx
-// notation.
+// notation.  Lengths are counted in runes.
 func (dmp *DiffMatchPatch) DiffToDelta(diffs []Diff) string {
+	return dmp.DiffToDeltaUnit(diffs, UnitRune)
+}
+
+// DiffToDeltaUnit is like DiffToDelta but counts lengths in unit.  Use
+// UnitUTF16 to produce deltas for the JavaScript, Java and C# ports.
+func (dmp *DiffMatchPatch) DiffToDeltaUnit(diffs []Diff, unit Unit) string {
 	var text bytes.Buffer
 	for _, aDiff := range diffs {
 		switch aDiff.Type {
@@ -1429,12 +1408,12 @@ func (dmp *DiffMatchPatch) DiffToDelta(diffs []Diff) string {
 			break
 		case DiffDelete:
 			text.WriteString("-")
-			text.WriteString(strconv.Itoa(utf8.RuneCountInString(aDiff.Text)))
+			text.WriteString(strconv.Itoa(unitLen(aDiff.Text, unit)))
 			text.WriteString("\t")
 			break
 		case DiffEqual:
 			text.WriteString("=")
-			text.WriteString(strconv.Itoa(utf8.RuneCountInString(aDiff.Text)))
+			text.WriteString(strconv.Itoa(unitLen(aDiff.Text, unit)))
 			text.WriteString("\t")
 			break
 		}
@@ -1442,22 +1421,23 @@ func (dmp *DiffMatchPatch) DiffToDelta(diffs []Diff) string {
 	delta := text.String()
 	if len(delta) != 0 {
 		// Strip off trailing tab character.
-		delta = delta[0 : utf8.RuneCountInString(delta)-1]
+		delta = delta[0 : len(delta)-1]
 		delta = unescaper.Replace(delta)
 	}
 	return delta
 }
 
 // Diff_fromDelta. Given the original text1, and an encoded string which describes the
-// operations required to transform text1 into text2, comAdde the full diff.
+// operations required to transform text1 into text2, compute the full diff.
+// Lengths are counted in runes.
 func (dmp *DiffMatchPatch) DiffFromDelta(text1, delta string) (diffs []Diff, err error) {
-	diffs = []Diff{}
+	return dmp.DiffFromDeltaUnit(text1, delta, UnitRune)
+}
 
-	defer func() {
-		if r := recover(); r != nil {
-			err = r.(error)
-		}
-	}()
+// DiffFromDeltaUnit is like DiffFromDelta but counts lengths in unit.
+// Lengths that end inside a character of text1 are an error.
+func (dmp *DiffMatchPatch) DiffFromDeltaUnit(text1, delta string, unit Unit) (diffs []Diff, err error) {
+	diffs = []Diff{}
 
 	pointer := 0 // Cursor in text1
 	tokens := strings.Split(delta, "\t")
@@ -1476,7 +1456,10 @@ func (dmp *DiffMatchPatch) DiffFromDelta(text1, delta string) (diffs []Diff, err
 		case '+':
 			// decode would Diff all "+" to " "
 			param = strings.Replace(param, "+", "%2b", -1)
-			param, _ = url.QueryUnescape(param)
+			param, err = url.QueryUnescape(param)
+			if err != nil {
+				return diffs, err
+			}
 			diffs = append(diffs, Diff{DiffInsert, param})
 			break
 		case '=', '-':
@@ -1489,8 +1472,12 @@ func (dmp *DiffMatchPatch) DiffFromDelta(text1, delta string) (diffs []Diff, err
 				return diffs, errors.New("Negative number in DiffFromDelta: " + param)
 			}
 
-			text := text1[pointer : pointer+int(n)]
-			pointer += int(n)
+			size, err := unitOffset(text1[pointer:], int(n), unit)
+			if err != nil {
+				return diffs, errors.New("Invalid length in DiffFromDelta: " + err.Error())
+			}
+			text := text1[pointer : pointer+size]
+			pointer += size
 
 			if token[0] == '=' {
 				diffs = append(diffs, Diff{DiffEqual, text})
@@ -1505,17 +1492,17 @@ func (dmp *DiffMatchPatch) DiffFromDelta(text1, delta string) (diffs []Diff, err
 	}
 
 	if pointer != len(text1) {
-		return diffs, errors.New("Delta length (" + string(pointer) + ") smaller than source text length (" + string(len(text1)) + ").")
+		return diffs, errors.New("Delta length (" + strconv.Itoa(pointer) + ") smaller than source text length (" + strconv.Itoa(len(text1)) + ").")
 	}
-	fmt.Println("") //text1, pointer, n)
 
-	return diffs, err
+	return diffs, nil
 }
 
 //  MATCH FUNCTIONS
 
 // MatchMain locates the best instance of 'pattern' in 'text' near 'loc'.
-// Returns -1 if no match found.
+// Returns -1 if no match found.  loc and the location returned are byte
+// offsets; MatchMainUnit counts them in other units.
 func (dmp *DiffMatchPatch) MatchMain(text string, pattern string, loc int) int {
 	// Check for null inputs not needed since null can't be passed in C#.
 
@@ -1534,34 +1521,141 @@ func (dmp *DiffMatchPatch) MatchMain(text string, pattern string, loc int) int {
 	return dmp.MatchBitap(text, pattern, loc)
 }
 
+// MatchMainUnit is MatchMain with loc and the location returned counted in
+// unit.  A loc inside a character counts as the start of that character.
+func (dmp *DiffMatchPatch) MatchMainUnit(text string, pattern string, loc int, unit Unit) int {
+	i := dmp.MatchMain(text, pattern, unitFloor(text, loc, unit))
+	if i == -1 {
+		return -1
+	}
+	return unitLen(text[:i], unit)
+}
+
 // MatchBitap locates the best instance of 'pattern' in 'text' near 'loc' using the
-// Bitap algorithm.  Returns -1 if no match found.
+// Bitap algorithm.  Returns -1 if no match found.  The texts are compared
+// character by character, so that a differing accented letter is a single
+// error, and the match starts at a character; loc and the location
+// returned are byte offsets.
 func (dmp *DiffMatchPatch) MatchBitap(text string, pattern string, loc int) int {
-	// Initialise the alphabet.
-	s := dmp.MatchAlphabet(pattern)
+	keys := map[string]rune{}
+	chars, offsets := dmp.matchChars(text, keys)
+	patternChars, _ := dmp.matchChars(pattern, keys)
+	// charAt returns the index of the character at byte offset i.
+	charAt := func(i int) int {
+		return sort.Search(len(offsets), func(k int) bool { return offsets[k] > i }) - 1
+	}
+	loc = charAt(int(math.Max(0, float64(loc))))
 
 	// Highest score beyond which we give up.
 	var score_threshold float64 = dmp.MatchThreshold
 	// Is there a nearby exact match? (speedup)
 	best_loc := strings.Index(text, pattern)
 	if best_loc != -1 {
-		score_threshold = math.Min(dmp.matchBitapScore(0, best_loc, loc,
-			pattern), score_threshold)
+		score_threshold = math.Min(dmp.matchBitapScore(0, charAt(best_loc), loc,
+			patternChars), score_threshold)
 		// What about in the other direction? (speedup)
 		best_loc = strings.LastIndex(text, pattern)
 		if best_loc != -1 {
-			score_threshold = math.Min(dmp.matchBitapScore(0, best_loc, loc,
-				pattern), score_threshold)
+			score_threshold = math.Min(dmp.matchBitapScore(0, charAt(best_loc), loc,
+				patternChars), score_threshold)
 		}
 	}
 
-	// Initialise the bit arrays.
-	matchmask := 1 << uint((len(pattern) - 1))
-	best_loc = -1
+	best_loc = dmp.matchBitap(chars, patternChars, loc, score_threshold)
+	if best_loc == -1 {
+		return -1
+	} else if best_loc >= len(chars) {
+		// Beyond the last character.
+		return len(text) + best_loc - len(chars)
+	}
+	return offsets[best_loc]
+}
+
+// matchChars splits text into the characters matching compares, runes or
+// in byte mode bytes, and returns them with the byte offset of each and
+// of the end of text.  With a Folder the characters are folded together
+// with the combining marks following them; those that fold to more than a
+// single rune are numbered beyond utf8.MaxRune in keys.
+func (dmp *DiffMatchPatch) matchChars(text string, keys map[string]rune) ([]rune, []int) {
+	chars := make([]rune, 0, len(text))
+	offsets := make([]int, 0, len(text)+1)
+	if dmp.byteMode {
+		for i := 0; i < len(text); i++ {
+			chars = append(chars, rune(text[i]))
+			offsets = append(offsets, i)
+		}
+	} else if dmp.Folder != nil {
+		for i := 0; i < len(text); {
+			end := foldEnd(text, i)
+			key := dmp.Folder.Fold(text[i:end])
+			c, size := utf8.DecodeRuneInString(key)
+			if size != len(key) {
+				var ok bool
+				if c, ok = keys[key]; !ok {
+					c = utf8.MaxRune + 1 + rune(len(keys))
+					keys[key] = c
+				}
+			}
+			chars = append(chars, c)
+			offsets = append(offsets, i)
+			i = end
+		}
+	} else {
+		for i, r := range text {
+			chars = append(chars, r)
+			offsets = append(offsets, i)
+		}
+	}
+	return chars, append(offsets, len(text))
+}
+
+// matchBitap is MatchBitap on characters, giving up on matches that score
+// worse than score_threshold.
+func (dmp *DiffMatchPatch) matchBitap(text, pattern []rune, loc int, score_threshold float64) int {
+	// Initialise the alphabet.
+	s := matchAlphabetWords(pattern)
+	scorer := dmp.matchScorer()
+	subs, weighted := scorer.(SubstitutionScorer)
+	if weighted {
+		// Characters substituted for less than an error match the
+		// characters of the pattern they may replace.
+		alphabet := s
+		s = map[rune][]uint64{}
+		for _, c := range text {
+			if _, ok := s[c]; ok {
+				continue
+			}
+			var mask []uint64
+			for p, m := range alphabet {
+				if subs.SubstitutionCost(p, c) < 1 {
+					if mask == nil {
+						mask = make([]uint64, len(m))
+					}
+					for k := range m {
+						mask[k] |= m[k]
+					}
+				}
+			}
+			s[c] = mask
+		}
+		for c, m := range s {
+			if m == nil {
+				delete(s, c)
+			}
+		}
+	}
+
+	// Initialise the bit arrays.  Each entry takes words words, the lowest
+	// first.
+	words := (len(pattern) + 63) / 64
+	matchword := (len(pattern) - 1) / 64
+	matchmask := uint64(1) << uint((len(pattern)-1)%64)
+	nomatch := make([]uint64, words)
+	best_loc := -1
 
 	var bin_min, bin_mid int
 	bin_max := len(pattern) + len(text)
-	last_rd := []int{}
+	last_rd := []uint64{}
 	for d := 0; d < len(pattern); d++ {
 		// Scan for the best match; each iteration allows for one more error.
 		// Run a binary search to determine how far from 'loc' we can stray at
@@ -1581,29 +1675,49 @@ func (dmp *DiffMatchPatch) MatchBitap(text string, pattern string, loc int) int
 		start := int(math.Max(1, float64(loc-bin_mid+1)))
 		finish := int(math.Min(float64(loc+bin_mid), float64(len(text))) + float64(len(pattern)))
 
-		rd := make([]int, finish+2)
-		rd[finish+1] = (1 << uint(d)) - 1
+		rd := make([]uint64, (finish+2)*words)
+		// rd[finish+1] has the lowest d bits set.
+		for k, n := (finish+1)*words, d; n > 0; k, n = k+1, n-64 {
+			if n >= 64 {
+				rd[k] = ^uint64(0)
+			} else {
+				rd[k] = uint64(1)<<uint(n) - 1
+			}
+		}
 
 		for j := finish; j >= start; j-- {
-			var charMatch int
-			if len(text) <= j-1 {
-				// Out of range.
-				charMatch = 0
-			} else if _, ok := s[text[j-1]]; !ok {
-				charMatch = 0
-			} else {
-				charMatch = s[text[j-1]]
+			charMatch := nomatch
+			if j-1 < len(text) {
+				if m, ok := s[text[j-1]]; ok {
+					charMatch = m
+				}
 			}
 
+			// Shift the words left by one bit, carrying from each word into
+			// the next, and shift a 1 into the lowest.
+			cur, next := rd[j*words:(j+1)*words], rd[(j+1)*words:(j+2)*words]
+			carry := uint64(1)
 			if d == 0 {
 				// First pass: exact match.
-				rd[j] = ((rd[j+1] << 1) | 1) & charMatch
+				for k := range cur {
+					cur[k] = ((next[k] << 1) | carry) & charMatch[k]
+					carry = next[k] >> 63
+				}
 			} else {
 				// Subsequent passes: fuzzy match.
-				rd[j] = ((rd[j+1]<<1)|1)&charMatch | (((last_rd[j+1] | last_rd[j]) << 1) | 1) | last_rd[j+1]
+				last_cur, last_next := last_rd[j*words:(j+1)*words], last_rd[(j+1)*words:(j+2)*words]
+				last_carry := uint64(1)
+				for k := range cur {
+					last := last_next[k] | last_cur[k]
+					cur[k] = ((next[k]<<1)|carry)&charMatch[k] | ((last << 1) | last_carry) | last_next[k]
+					carry, last_carry = next[k]>>63, last>>63
+				}
 			}
-			if (rd[j] & matchmask) != 0 {
+			if (cur[matchword] & matchmask) != 0 {
 				score := dmp.matchBitapScore(d, j-1, loc, pattern)
+				if weighted {
+					score = scorer.Score(weightedErrors(pattern, text[int(math.Min(float64(j-1), float64(len(text)))):], subs), j-1, loc, len(pattern))
+				}
 				// This match will almost certainly be better than any existing
 				// match.  But check anyway.
 				if score <= score_threshold {
@@ -1630,24 +1744,17 @@ func (dmp *DiffMatchPatch) MatchBitap(text string, pattern string, loc int) int
 }
 
 // matchBitapScore computes and returns the score for a match with e errors and x location.
-func (dmp *DiffMatchPatch) matchBitapScore(e, x, loc int, pattern string) float64 {
-	var accuracy float64 = float64(e) / float64(len(pattern))
-	proximity := math.Abs(float64(loc - x))
-	if dmp.MatchDistance == 0 {
-		// Dodge divide by zero error.
-		if proximity == 0 {
-			return accuracy
-		} else {
-			return 1.0
-		}
-	}
-	return accuracy + (proximity / float64(dmp.MatchDistance))
+// Errors and locations count characters.
+func (dmp *DiffMatchPatch) matchBitapScore(e, x, loc int, pattern []rune) float64 {
+	return dmp.matchScorer().Score(float64(e), x, loc, len(pattern))
 }
 
-// MatchAlphabet initialises the alphabet for the Bitap algorithm.
-func (dmp *DiffMatchPatch) MatchAlphabet(pattern string) map[byte]int {
-	s := map[byte]int{}
-	char_pattern := []byte(pattern)
+// MatchAlphabet initialises the alphabet for the Bitap algorithm.  It only
+// has room for patterns of as many characters as an int has bits;
+// MatchBitap uses matchAlphabetWords, which has no such limit.
+func (dmp *DiffMatchPatch) MatchAlphabet(pattern string) map[rune]int {
+	s := map[rune]int{}
+	char_pattern, _ := dmp.matchChars(pattern, map[string]rune{})
 	for _, c := range char_pattern {
 		_, ok := s[c]
 		if !ok {
@@ -1657,13 +1764,30 @@ func (dmp *DiffMatchPatch) MatchAlphabet(pattern string) map[byte]int {
 	i := 0
 
 	for _, c := range char_pattern {
-		value := s[c] | int(uint(1)<<uint((len(pattern)-i-1)))
+		value := s[c] | int(uint(1)<<uint((len(char_pattern)-i-1)))
 		s[c] = value
 		i++
 	}
 	return s
 }
 
+// matchAlphabetWords is MatchAlphabet with the bit masks split into words
+// of 64 bits, the lowest first.
+func matchAlphabetWords(pattern []rune) map[rune][]uint64 {
+	s := map[rune][]uint64{}
+	words := (len(pattern) + 63) / 64
+	for i, c := range pattern {
+		mask, ok := s[c]
+		if !ok {
+			mask = make([]uint64, words)
+			s[c] = mask
+		}
+		bit := len(pattern) - i - 1
+		mask[bit/64] |= uint64(1) << uint(bit%64)
+	}
+	return s
+}
+
 //  PATCH FUNCTIONS
 
 // PatchAddContext increases the context until it is unique,
@@ -1688,13 +1812,21 @@ func (dmp *DiffMatchPatch) PatchAddContext(patch Patch, text string) Patch {
 	// Add one chunk for good luck.
 	padding += dmp.PatchMargin
 
-	// Add the prefix.
-	prefix := text[int(math.Max(0, float64(patch.start2-padding))):int(patch.start2)]
+	// Add the prefix, without splitting a character.
+	prefixStart := int(math.Max(0, float64(patch.start2-padding)))
+	for !dmp.byteMode && !runeBoundary(text, prefixStart) {
+		prefixStart--
+	}
+	prefix := text[prefixStart:patch.start2]
 	if len(prefix) != 0 {
 		patch.diffs = append([]Diff{Diff{DiffEqual, prefix}}, patch.diffs...)
 	}
 	// Add the suffix.
-	suffix := text[patch.start2+patch.length1 : int(math.Min(float64(len(text)), float64(patch.start2+patch.length1+padding)))]
+	suffixEnd := int(math.Min(float64(len(text)), float64(patch.start2+patch.length1+padding)))
+	for !dmp.byteMode && !runeBoundary(text, suffixEnd) {
+		suffixEnd++
+	}
+	suffix := text[patch.start2+patch.length1 : suffixEnd]
 	if len(suffix) != 0 {
 		patch.diffs = append(patch.diffs, Diff{DiffEqual, suffix})
 	}
@@ -1709,31 +1841,71 @@ func (dmp *DiffMatchPatch) PatchAddContext(patch Patch, text string) Patch {
 	return patch
 }
 
+// PatchMake computes a list of patches from either text1 and text2, a
+// diff, text1 and a diff, or text1, text2 and a diff.  Arguments that fit
+// none of these forms yield no patches.
+//
+// Deprecated: use PatchMakeFromTexts, PatchMakeFromDiffs or
+// PatchMakeFromTextAndDiffs, whose arguments are checked at compile time.
 func (dmp *DiffMatchPatch) PatchMake(opt ...interface{}) []Patch {
-	if len(opt) == 1 {
-		diffs, _ := opt[0].([]Diff)
-		text1 := dmp.DiffText1(diffs)
-		return dmp.PatchMake(text1, diffs)
-	} else if len(opt) == 2 {
-		text1 := opt[0].(string)
-		kind := reflect.TypeOf(opt[1]).Name()
-		if kind == "string" {
-			text2 := opt[1].(string)
-			diffs := dmp.DiffMain(text1, text2, true)
-			if len(diffs) > 2 {
-				diffs = dmp.DiffCleanupSemantic(diffs)
-				diffs = dmp.DiffCleanupEfficiency(diffs)
-			}
-			return dmp.PatchMake(text1, diffs)
-		} else if kind == "Diff" {
-			return dmp.patchMake2(text1, opt[1].([]Diff))
+	switch len(opt) {
+	case 1:
+		if diffs, ok := opt[0].([]Diff); ok {
+			return dmp.PatchMakeFromDiffs(diffs)
+		}
+	case 2:
+		text1, ok := opt[0].(string)
+		if !ok {
+			break
+		}
+		switch arg := opt[1].(type) {
+		case string:
+			return dmp.PatchMakeFromTexts(text1, arg)
+		case []Diff:
+			return dmp.PatchMakeFromTextAndDiffs(text1, arg)
+		}
+	case 3:
+		// text2 is not needed, the diffs already describe it.
+		text1, ok1 := opt[0].(string)
+		diffs, ok2 := opt[2].([]Diff)
+		if ok1 && ok2 {
+			return dmp.PatchMakeFromTextAndDiffs(text1, diffs)
 		}
-	} else if len(opt) == 3 {
-		return dmp.PatchMake(opt[0], opt[2])
 	}
 	return []Patch{}
 }
 
+// PatchMakeFromTexts computes a list of patches to turn text1 into text2.
+func (dmp *DiffMatchPatch) PatchMakeFromTexts(text1, text2 string) []Patch {
+	patches, _ := dmp.PatchMakeContext(context.Background(), text1, text2)
+	return patches
+}
+
+// PatchMakeFromDiffs computes a list of patches from a diff; text1 is
+// reconstructed from the diff.
+func (dmp *DiffMatchPatch) PatchMakeFromDiffs(diffs []Diff) []Patch {
+	return dmp.patchMake2(dmp.DiffText1(diffs), diffs)
+}
+
+// PatchMakeFromTextAndDiffs computes a list of patches to turn text1 into
+// the text that diffs describes.  This is the fastest form since neither
+// text needs to be recomputed.
+func (dmp *DiffMatchPatch) PatchMakeFromTextAndDiffs(text1 string, diffs []Diff) []Patch {
+	return dmp.patchMake2(text1, diffs)
+}
+
+// PatchMakeContext computes a list of patches to turn text1 into text2,
+// giving up on the underlying diff when ctx is done.  The patches made from
+// the best-effort diff are returned together with ctx.Err().
+func (dmp *DiffMatchPatch) PatchMakeContext(ctx context.Context, text1, text2 string) ([]Patch, error) {
+	diffs, err := dmp.DiffMainContext(ctx, text1, text2)
+	if len(diffs) > 2 {
+		diffs = dmp.DiffCleanupSemantic(diffs)
+		diffs = dmp.DiffCleanupEfficiency(diffs)
+	}
+	return dmp.patchMake2(text1, diffs), err
+}
+
 // Compute a list of patches to turn text1 into text2.
 // text2 is not provided, diffs are the delta between text1 and text2.
 func (dmp *DiffMatchPatch) patchMake2(text1 string, diffs []Diff) []Patch {
@@ -1752,7 +1924,7 @@ func (dmp *DiffMatchPatch) patchMake2(text1 string, diffs []Diff) []Patch {
 	prepatch_text := text1
 	postpatch_text := text1
 
-	for _, aDiff := range diffs {
+	for x, aDiff := range diffs {
 		if len(patch.diffs) == 0 && aDiff.Type != DiffEqual {
 			// A new patch starts here.
 			patch.start1 = char_count1
@@ -1773,7 +1945,7 @@ func (dmp *DiffMatchPatch) patchMake2(text1 string, diffs []Diff) []Patch {
 			break
 		case DiffEqual:
 			if len(aDiff.Text) <= 2*dmp.PatchMargin &&
-				len(patch.diffs) != 0 && aDiff != diffs[len(diffs)-1] {
+				len(patch.diffs) != 0 && x != len(diffs)-1 {
 				// Small equality inside a patch.
 				patch.diffs = append(patch.diffs, aDiff)
 				patch.length1 += len(aDiff.Text)
@@ -1783,7 +1955,7 @@ func (dmp *DiffMatchPatch) patchMake2(text1 string, diffs []Diff) []Patch {
 			if len(aDiff.Text) >= 2*dmp.PatchMargin {
 				// Time for a new patch.
 				if len(patch.diffs) != 0 {
-					dmp.PatchAddContext(patch, prepatch_text)
+					patch = dmp.PatchAddContext(patch, prepatch_text)
 					patches = append(patches, patch)
 					patch = Patch{}
 					// Unlike Unidiff, our patch lists have a rolling context.
@@ -1807,7 +1979,7 @@ func (dmp *DiffMatchPatch) patchMake2(text1 string, diffs []Diff) []Patch {
 	}
 	// Pick up the leftover patch if not empty.
 	if len(patch.diffs) != 0 {
-		dmp.PatchAddContext(patch, prepatch_text)
+		patch = dmp.PatchAddContext(patch, prepatch_text)
 		patches = append(patches, patch)
 	}
 
@@ -1838,8 +2010,16 @@ func (dmp *DiffMatchPatch) PatchDeepCopy(patches []Patch) []Patch {
 // PatchApply merges a set of patches onto the text.  Returns a patched text, as well
 // as an array of true/false values indicating which patches were applied.
 func (dmp *DiffMatchPatch) PatchApply(patches []Patch, text string) (string, []bool) {
+	text, results, _ := dmp.PatchApplyContext(context.Background(), patches, text)
+	return text, results
+}
+
+// PatchApplyContext is like PatchApply but stops applying patches when ctx
+// is done.  The text with the patches applied so far is returned together
+// with ctx.Err(); the remaining patches are reported as not applied.
+func (dmp *DiffMatchPatch) PatchApplyContext(ctx context.Context, patches []Patch, text string) (string, []bool, error) {
 	if len(patches) == 0 {
-		return text, []bool{}
+		return text, []bool{}, ctx.Err()
 	}
 
 	// Deep copy the patches so that no changes are made to originals.
@@ -1847,7 +2027,7 @@ func (dmp *DiffMatchPatch) PatchApply(patches []Patch, text string) (string, []b
 
 	nullPadding := dmp.PatchAddPadding(patches)
 	text = nullPadding + text + nullPadding
-	dmp.PatchSplitMax(patches)
+	patches = dmp.PatchSplitMax(patches)
 
 	x := 0
 	// delta keeps track of the offset between the expected and actual
@@ -1855,19 +2035,33 @@ func (dmp *DiffMatchPatch) PatchApply(patches []Patch, text string) (string, []b
 	// positions 10 and 20, but the first patch was found at 12, delta is 2
 	// and the second patch has an effective expected position of 22.
 	delta := 0
-	results := []bool{}
+	results := make([]bool, len(patches))
 	for _, aPatch := range patches {
+		if ctx.Err() != nil {
+			// Leave the rest of the patches unapplied.
+			break
+		}
 		expected_loc := aPatch.start2 + delta
 		text1 := dmp.DiffText1(aPatch.diffs)
 		var start_loc int
 		end_loc := -1
+		var tail string
 		if len(text1) > dmp.MatchMaxBits {
 			// PatchSplitMax will only provide an oversized pattern
-			// in the case of a monster delete.
-			start_loc = dmp.MatchMain(text, text1[0:dmp.MatchMaxBits], expected_loc)
+			// in the case of a monster delete.  Match its start and end,
+			// without splitting a character.
+			headEnd := dmp.MatchMaxBits
+			for !dmp.byteMode && !runeBoundary(text1, headEnd) {
+				headEnd++
+			}
+			tailStart := len(text1) - dmp.MatchMaxBits
+			for !dmp.byteMode && !runeBoundary(text1, tailStart) {
+				tailStart--
+			}
+			tail = text1[tailStart:]
+			start_loc = dmp.MatchMain(text, text1[:headEnd], expected_loc)
 			if start_loc != -1 {
-				end_loc = dmp.MatchMain(text,
-					text1[len(text1)-dmp.MatchMaxBits:], expected_loc+len(text1)-dmp.MatchMaxBits)
+				end_loc = dmp.MatchMain(text, tail, expected_loc+tailStart)
 				if end_loc == -1 || start_loc >= end_loc {
 					// Can't find valid trailing context.  Drop this patch.
 					start_loc = -1
@@ -1889,16 +2083,17 @@ func (dmp *DiffMatchPatch) PatchApply(patches []Patch, text string) (string, []b
 			if end_loc == -1 {
 				text2 = text[start_loc:int(math.Min(float64(start_loc+len(text1)), float64(len(text))))]
 			} else {
-				text2 = text[start_loc:int(math.Min(float64(end_loc+dmp.MatchMaxBits), float64(len(text))))]
+				text2 = text[start_loc:int(math.Min(float64(end_loc+len(tail)), float64(len(text))))]
 			}
 			if text1 == text2 {
 				// Perfect match, just shove the Replacement text in.
 				text = text[0:start_loc] + dmp.DiffText2(aPatch.diffs) + text[start_loc+len(text1):]
 			} else {
 				// Imperfect match.  Run a diff to get a framework of equivalent
-				// indices.
-				diffs := dmp.DiffMain(text1, text2, false)
-				if len(text1) > dmp.MatchMaxBits && float64(dmp.DiffLevenshtein(diffs)/len(text1)) > dmp.PatchDeleteThreshold {
+				// indices.  The diff compares the texts as they are, as a
+				// folded equality wouldn't say how long it is in text2.
+				diffs := dmp.unfoldedDMP().diffMain(ctx, text1, text2, false, dmp.deadline())
+				if len(text1) > dmp.MatchMaxBits && float64(dmp.DiffLevenshtein(diffs))/float64(utf8.RuneCountInString(text1)) > dmp.PatchDeleteThreshold {
 					// The end points match, but the content is unacceptably bad.
 					results[x] = false
 				} else {
@@ -1928,7 +2123,7 @@ func (dmp *DiffMatchPatch) PatchApply(patches []Patch, text string) (string, []b
 	}
 	// Strip the padding off.
 	text = text[len(nullPadding) : len(nullPadding)+(len(text)-2*len(nullPadding))]
-	return text, results
+	return text, results, ctx.Err()
 }
 
 // PatchAddPadding adds some padding on text start and end so that edges can match something.
@@ -1937,28 +2132,27 @@ func (dmp *DiffMatchPatch) PatchAddPadding(patches []Patch) string {
 	paddingLength := dmp.PatchMargin
 	nullPadding := ""
 	for x := 1; x <= paddingLength; x++ {
-		nullPadding += strconv.FormatInt(int64(x), 10)
+		nullPadding += string(rune(x))
 	}
 
 	// Bump all the patches forward.
-	for _, aPatch := range patches {
-		aPatch.start1 += paddingLength
-		aPatch.start2 += paddingLength
+	for i := range patches {
+		patches[i].start1 += paddingLength
+		patches[i].start2 += paddingLength
 	}
 
 	// Add some padding on start of first diff.
-	patch := patches[0]
-	diffs := patch.diffs
-	if len(diffs) == 0 || diffs[0].Type != DiffEqual {
+	patch := &patches[0]
+	if len(patch.diffs) == 0 || patch.diffs[0].Type != DiffEqual {
 		// Add nullPadding equality.
-		diffs = append(diffs, Diff{DiffEqual, nullPadding})
+		patch.diffs = append([]Diff{Diff{DiffEqual, nullPadding}}, patch.diffs...)
 		patch.start1 -= paddingLength // Should be 0.
 		patch.start2 -= paddingLength // Should be 0.
 		patch.length1 += paddingLength
 		patch.length2 += paddingLength
-	} else if paddingLength > len(diffs[0].Text) {
+	} else if paddingLength > len(patch.diffs[0].Text) {
 		// Grow first equality.
-		firstDiff := diffs[0]
+		firstDiff := &patch.diffs[0]
 		extraLength := paddingLength - len(firstDiff.Text)
 		firstDiff.Text = nullPadding[len(firstDiff.Text):] + firstDiff.Text
 		patch.start1 -= extraLength
@@ -1968,16 +2162,15 @@ func (dmp *DiffMatchPatch) PatchAddPadding(patches []Patch) string {
 	}
 
 	// Add some padding on end of last diff.
-	patch = patches[len(patches)-1]
-	diffs = patch.diffs
-	if len(diffs) == 0 || diffs[len(diffs)-1].Type != DiffEqual {
+	patch = &patches[len(patches)-1]
+	if len(patch.diffs) == 0 || patch.diffs[len(patch.diffs)-1].Type != DiffEqual {
 		// Add nullPadding equality.
-		diffs = append(diffs, Diff{DiffEqual, nullPadding})
+		patch.diffs = append(patch.diffs, Diff{DiffEqual, nullPadding})
 		patch.length1 += paddingLength
 		patch.length2 += paddingLength
-	} else if paddingLength > len(diffs[len(diffs)-1].Text) {
+	} else if paddingLength > len(patch.diffs[len(patch.diffs)-1].Text) {
 		// Grow last equality.
-		lastDiff := diffs[len(diffs)-1]
+		lastDiff := &patch.diffs[len(patch.diffs)-1]
 		extraLength := paddingLength - len(lastDiff.Text)
 		lastDiff.Text += nullPadding[0:extraLength]
 		patch.length1 += extraLength
@@ -1990,7 +2183,7 @@ func (dmp *DiffMatchPatch) PatchAddPadding(patches []Patch) string {
 // PatchSplitMax looks through the patches and breaks up any which are longer than the
 // maximum limit of the match algorithm.
 // Intended to be called only from within patch_apply.
-func (dmp *DiffMatchPatch) PatchSplitMax(patches []Patch) {
+func (dmp *DiffMatchPatch) PatchSplitMax(patches []Patch) []Patch {
 	patch_size := dmp.MatchMaxBits
 	for x := 0; x < len(patches); x++ {
 		if patches[x].length1 <= patch_size {
@@ -1998,8 +2191,8 @@ func (dmp *DiffMatchPatch) PatchSplitMax(patches []Patch) {
 		}
 		bigpatch := patches[x]
 		// Remove the big old patch.
-		x = x - 1
 		patches = splice_patch(patches, x, 1)
+		x = x - 1
 		start1 := bigpatch.start1
 		start2 := bigpatch.start2
 		precontext := ""
@@ -2022,7 +2215,7 @@ func (dmp *DiffMatchPatch) PatchSplitMax(patches []Patch) {
 					patch.length2 += len(diff_text)
 					start2 += len(diff_text)
 					patch.diffs = append(patch.diffs, bigpatch.diffs[0])
-					bigpatch.diffs = append(bigpatch.diffs[:0], bigpatch.diffs[0:]...)
+					bigpatch.diffs = bigpatch.diffs[1:]
 					empty = false
 				} else if diff_type == DiffDelete && len(patch.diffs) == 1 && patch.diffs[0].Type == DiffEqual && len(diff_text) > 2*patch_size {
 					// This is a large deletion.  Let it pass in one chunk.
@@ -2030,11 +2223,20 @@ func (dmp *DiffMatchPatch) PatchSplitMax(patches []Patch) {
 					start1 += len(diff_text)
 					empty = false
 					patch.diffs = append(patch.diffs, Diff{diff_type, diff_text})
-					bigpatch.diffs = append(bigpatch.diffs[:0], bigpatch.diffs[0:]...)
+					bigpatch.diffs = bigpatch.diffs[1:]
 				} else {
-					// Deletion or equality.  Only take as much as we can stomach.
-					diff_text = diff_text[0:int(math.Min(float64(len(diff_text)),
-						float64(patch_size-patch.length1-dmp.PatchMargin)))]
+					// Deletion or equality.  Only take as much as we can stomach,
+					// without splitting a character.
+					end := int(math.Min(float64(len(diff_text)),
+						float64(patch_size-patch.length1-dmp.PatchMargin)))
+					for !dmp.byteMode && !runeBoundary(diff_text, end) {
+						end--
+					}
+					if end == 0 {
+						// Too little room for a character, take one anyway.
+						_, end = utf8.DecodeRuneInString(diff_text)
+					}
+					diff_text = diff_text[0:end]
 
 					patch.length1 += len(diff_text)
 					start1 += len(diff_text)
@@ -2046,7 +2248,7 @@ func (dmp *DiffMatchPatch) PatchSplitMax(patches []Patch) {
 					}
 					patch.diffs = append(patch.diffs, Diff{diff_type, diff_text})
 					if diff_text == bigpatch.diffs[0].Text {
-						bigpatch.diffs = append(bigpatch.diffs[:0], bigpatch.diffs[0:]...)
+						bigpatch.diffs = bigpatch.diffs[1:]
 					} else {
 						bigpatch.diffs[0].Text =
 							bigpatch.diffs[0].Text[len(diff_text):]
@@ -2055,12 +2257,21 @@ func (dmp *DiffMatchPatch) PatchSplitMax(patches []Patch) {
 			}
 			// Compute the head context for the next patch.
 			precontext = dmp.DiffText2(patch.diffs)
-			precontext = precontext[int(math.Max(0, float64(len(precontext)-dmp.PatchMargin))):]
+			precontextStart := int(math.Max(0, float64(len(precontext)-dmp.PatchMargin)))
+			for !dmp.byteMode && !runeBoundary(precontext, precontextStart) {
+				precontextStart--
+			}
+			precontext = precontext[precontextStart:]
 
 			postcontext := ""
 			// Append the end context for this patch.
 			if len(dmp.DiffText1(bigpatch.diffs)) > dmp.PatchMargin {
-				postcontext = dmp.DiffText1(bigpatch.diffs)[0:dmp.PatchMargin]
+				postcontext = dmp.DiffText1(bigpatch.diffs)
+				postcontextEnd := dmp.PatchMargin
+				for !dmp.byteMode && !runeBoundary(postcontext, postcontextEnd) {
+					postcontextEnd++
+				}
+				postcontext = postcontext[0:postcontextEnd]
 			} else {
 				postcontext = dmp.DiffText1(bigpatch.diffs)
 			}
@@ -2076,10 +2287,11 @@ func (dmp *DiffMatchPatch) PatchSplitMax(patches []Patch) {
 			}
 			if !empty {
 				x = x + 1
-				splice_patch(patches, x, 0, patch)
+				patches = splice_patch(patches, x, 0, patch)
 			}
 		}
 	}
+	return patches
 }
 
 // PatchToText takes a list of patches and returns a textual representation.
@@ -2091,6 +2303,97 @@ func (dmp *DiffMatchPatch) PatchToText(patches []Patch) string {
 	return text.String()
 }
 
+// PatchToTextUnit is like PatchToText but counts the offsets and lengths in
+// the patch headers in unit.  text is the text the patches apply to; it is
+// needed to convert the offsets.
+func (dmp *DiffMatchPatch) PatchToTextUnit(patches []Patch, text string, unit Unit) (string, error) {
+	if unit == UnitByte {
+		return dmp.PatchToText(patches), nil
+	}
+	var out bytes.Buffer
+	var deltas startDelta
+	for i, aPatch := range patches {
+		// Each patch is located at start2 in the text with the patches before
+		// it applied.
+		if aPatch.start2 > len(text) || !runeBoundary(text, aPatch.start2) {
+			return "", errors.New("Patch " + strconv.Itoa(i) + " does not start on a character boundary of the text")
+		}
+		text1 := dmp.DiffText1(aPatch.diffs)
+		text2 := dmp.DiffText2(aPatch.diffs)
+		length1 := unitLen(text1, unit)
+		length2 := unitLen(text2, unit)
+		delta, ok := deltas.convert(aPatch.start1-aPatch.start2,
+			aPatch.length1-aPatch.length2, length1-length2)
+		if !ok {
+			return "", errors.New("Cannot convert start1 of patch " + strconv.Itoa(i))
+		}
+		start2 := unitLen(text[:aPatch.start2], unit)
+		out.WriteString(aPatch.format(start2+delta, length1, start2, length2))
+		text = applyAt(text, aPatch.start2, len(text1), text2)
+	}
+	return out.String(), nil
+}
+
+// startDelta converts start1-start2 of consecutive patches from one unit to
+// another.  The difference is zero for patches made by PatchMake;
+// PatchSplitMax accumulates the net change of the earlier pieces of a split
+// patch in it, which is the only other case that can be converted.
+type startDelta struct {
+	from, to       int // start1-start2 of the previous patch
+	netFrom, netTo int // length1-length2 of the previous patch
+}
+
+func (s *startDelta) convert(delta, netFrom, netTo int) (int, bool) {
+	converted := 0
+	switch delta {
+	case 0:
+	case s.from + s.netFrom:
+		converted = s.to + s.netTo
+	default:
+		return 0, false
+	}
+	*s = startDelta{delta, converted, netFrom, netTo}
+	return converted, true
+}
+
+// applyAt replaces length bytes of text at start with replacement, clamping
+// the replaced range to the text.
+func applyAt(text string, start, length int, replacement string) string {
+	end := int(math.Min(float64(len(text)), float64(start+length)))
+	return text[:start] + replacement + text[end:]
+}
+
+// PatchFromTextUnit is like PatchFromText for patches whose headers count
+// offsets and lengths in unit.  text is the text the patches apply to; it
+// is needed to convert the offsets to bytes.
+func (dmp *DiffMatchPatch) PatchFromTextUnit(textline, text string, unit Unit) ([]Patch, error) {
+	patches, err := dmp.PatchFromText(textline)
+	if err != nil || unit == UnitByte {
+		return patches, err
+	}
+	var deltas startDelta
+	for i := range patches {
+		aPatch := &patches[i]
+		text1 := dmp.DiffText1(aPatch.diffs)
+		text2 := dmp.DiffText2(aPatch.diffs)
+		start2, err := unitOffset(text, aPatch.start2, unit)
+		if err != nil {
+			return patches[:i], errors.New("Invalid start of patch " + strconv.Itoa(i) + ": " + err.Error())
+		}
+		delta, ok := deltas.convert(aPatch.start1-aPatch.start2,
+			unitLen(text1, unit)-unitLen(text2, unit), len(text1)-len(text2))
+		if !ok {
+			return patches[:i], errors.New("Cannot convert start1 of patch " + strconv.Itoa(i))
+		}
+		aPatch.start1 = start2 + delta
+		aPatch.start2 = start2
+		aPatch.length1 = len(text1)
+		aPatch.length2 = len(text2)
+		text = applyAt(text, start2, len(text1), text2)
+	}
+	return patches, nil
+}
+
 // PatchFromText parses a textual representation of patches and returns a List of Patch
 // objects.
 func (dmp *DiffMatchPatch) PatchFromText(textline string) ([]Patch, error) {
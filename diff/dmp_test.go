@@ -1,12 +1,17 @@
 package diffmatchpatch
 
 import (
+	"context"
 	"fmt"
 	"github.com/bmizerany/assert"
+	"math/rand"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
+	"testing/quick"
 	"time"
+	"unicode/utf8"
 )
 
 func softAssert(t *testing.T, cond bool, msg string) {
@@ -69,6 +74,18 @@ func assertSeqEqual(seq1, seq2 interface{}) {
 	}
 }
 
+// stepClock is a Clock that moves forward by step every time it is read.
+type stepClock struct {
+	t    time.Time
+	step time.Duration
+}
+
+func (c *stepClock) Now() time.Time {
+	now := c.t
+	c.t = c.t.Add(c.step)
+	return now
+}
+
 func diffRebuildtexts(diffs []Diff) []string {
 	text := []string{"", ""}
 	for _, myDiff := range diffs {
@@ -93,6 +110,11 @@ func Test_diffCommonPrefix(t *testing.T) {
 
 	// Whole case.
 	assert.Equal(t, 4, dmp.DiffCommonPrefix("1234", "1234xyz"), "")
+
+	// Multibyte characters sharing leading bytes are not split.
+	assert.Equal(t, 1, dmp.DiffCommonPrefix("a\u00e9", "a\u00e8"), "")
+	assert.Equal(t, 4, dmp.DiffCommonPrefix("\U0001F600x", "\U0001F600y"), "")
+	assert.Equal(t, 0, dmp.DiffCommonPrefix("\U0001F600", "\U0001F601"), "")
 }
 
 func Test_diffCommonSuffixTest(t *testing.T) {
@@ -106,6 +128,11 @@ func Test_diffCommonSuffixTest(t *testing.T) {
 
 	// Whole case.
 	assert.Equal(t, 4, dmp.DiffCommonSuffix("1234", "xyz1234"), "")
+
+	// Multibyte characters sharing trailing bytes are not split.
+	assert.Equal(t, 0, dmp.DiffCommonSuffix("\u4e00", "\u4f00"), "")
+	assert.Equal(t, 1, dmp.DiffCommonSuffix("\u00e9a", "\u00c9a"), "")
+	assert.Equal(t, 4, dmp.DiffCommonSuffix("x\U0001F600", "y\U0001F600"), "")
 }
 
 func Test_diffCommonOverlapTest(t *testing.T) {
@@ -212,6 +239,20 @@ func Test_diffLinesToChars(t *testing.T) {
 	*/
 }
 
+func Test_diffLinesToCharsManyLines(t *testing.T) {
+	dmp := createDMP()
+	// More distinct lines than fit below the surrogate range.
+	var lines []string
+	for i := 0; i < 60000; i++ {
+		lines = append(lines, strconv.Itoa(i)+"\n")
+	}
+	text := strings.Join(lines, "")
+	chars1, _, lineArray := dmp.DiffLinesToChars(text, "")
+	assert.Equal(t, 60000, utf8.RuneCountInString(chars1))
+	diffs := dmp.DiffCharsToLines([]Diff{Diff{DiffEqual, chars1}}, lineArray)
+	assert.Equal(t, text, diffs[0].Text)
+}
+
 func Test_diffCharsToLines(t *testing.T) {
 	dmp := createDMP()
 	// Convert chars up to lines.
@@ -617,17 +658,16 @@ func Test_diffCleanupEfficiency(t *testing.T) {
 	dmp.DiffEditCost = 4
 }
 
-/*
 func Test_diffPrettyHtml(t *testing.T) {
-    dmp := createDMP()
-    // Pretty print.
-    diffs := []Diff{
-        Diff{DiffEqual, "a\n"},
-        Diff{DiffDelete, "<B>b</B>"},
-        Diff{DiffInsert, "c&d"}}
-    assert.Equal(t, "<span>a&para;<br></span><del style=\"background:#ffe6e6;\">&lt;B&gt;b&lt;/B&gt;</del><ins style=\"background:#e6ffe6;\">c&amp;d</ins>",
-        dmp.DiffPrettyHtml(diffs))
-}*/
+	dmp := createDMP()
+	// Pretty print.
+	diffs := []Diff{
+		Diff{DiffEqual, "a\n"},
+		Diff{DiffDelete, "<B>b</B>"},
+		Diff{DiffInsert, "c&d"}}
+	assert.Equal(t, "<span>a&para;<br></span><del style=\"background:#ffe6e6;\">&lt;B&gt;b&lt;/B&gt;</del><ins style=\"background:#e6ffe6;\">c&amp;d</ins>",
+		dmp.DiffPrettyHtml(diffs))
+}
 
 func Test_diffText(t *testing.T) {
 	dmp := createDMP()
@@ -680,12 +720,10 @@ func Test_diffDelta(t *testing.T) {
 	}
 
 	// Generates error (%c3%xy invalid Unicode).
-	/*
-		seq, err := dmp.DiffFromDelta("", "+%c3%xy")
-		if err == nil {
-			panic(1) //assert.Fail("diff_fromDelta: Invalid character.");
-		}
-	*/
+	_, err = dmp.DiffFromDelta("", "+%c3%xy")
+	if err == nil {
+		panic(1) //assert.Fail("diff_fromDelta: Invalid character.");
+	}
 
 	// Test deltas with special characters.
 	diffs = []Diff{
@@ -696,7 +734,6 @@ func Test_diffDelta(t *testing.T) {
 	assert.Equal(t, "\u0680 \x00 \t %\u0681 \x01 \n ^", text1)
 
 	delta = dmp.DiffToDelta(diffs)
-	// Lowercase, due to UrlEncode uses lower.
 	assert.Equal(t, "=7\t-7\t+%DA%82 %02 %5C %7C", delta)
 
 	_res1, _ := dmp.DiffFromDelta(text1, delta)
@@ -768,13 +805,14 @@ func Test_diffBisect(t *testing.T) {
 		Diff{DiffDelete, "t"},
 		Diff{DiffInsert, "p"}}
 
-	fmt.Println(dmp.DiffBisect(a, b, int32(time.Date(9999, time.December, 31, 23, 59, 59, 59, time.UTC).Unix())))
+	assert.Equal(t, diffs, dmp.DiffBisect(a, b, time.Date(9999, time.December, 31, 23, 59, 59, 59, time.UTC)))
 
-	assertSeqEqual(diffs, dmp.DiffBisect(a, b, int32(time.Date(9999, time.December, 31, 23, 59, 59, 59, time.UTC).Unix()))) //TODO
+	// No deadline.
+	assert.Equal(t, diffs, dmp.DiffBisect(a, b, time.Time{}))
 
-    // Timeout.
-    diffs = []Diff{Diff{DiffDelete, "cat"}, Diff{DiffInsert, "map"}}
-	assertSeqEqual(diffs, dmp.DiffBisect(a, b, int32(time.Date(0001, time.January, 01, 00, 00, 00, 00, time.UTC).Unix()))) //TODO
+	// Timeout.
+	diffs = []Diff{Diff{DiffDelete, "cat"}, Diff{DiffInsert, "map"}}
+	assert.Equal(t, diffs, dmp.DiffBisect(a, b, time.Now().Add(-time.Minute)))
 }
 
 func Test_diffMain(t *testing.T) {
@@ -842,17 +880,21 @@ func Test_diffMain(t *testing.T) {
 		a = a + a
 		b = b + b
 	}
-	startTime := time.Now().Unix()
-	startTime *= 1000
-	dmp.DiffMain(a, b)
-	endTime := time.Now().Unix()
-	endTime *= 1000
+	// Every reading of the clock advances it by 10ms, so the diff gives up
+	// after a fixed amount of work.
+	clock := &stepClock{time.Date(2012, time.January, 1, 0, 0, 0, 0, time.UTC), 10 * time.Millisecond}
+	dmp.Clock = clock
+	start := clock.t
+	diffs = dmp.DiffMain(a, b)
 	// Test that we took at least the timeout period.
-	softAssert(t, dmp.DiffTimeout*1000 <= float64(endTime-startTime), "")
-	// Test that we didn't take forever (be forgiving).
-	// Theoretically this test could fail very occasionally if the
-	// OS task swaps or locks up for a second at the wrong moment.
-	softAssert(t, dmp.DiffTimeout*1000*2 > float64(endTime-startTime), "")
+	softAssert(t, clock.t.Sub(start) > 100*time.Millisecond, "")
+	// Test that we didn't take forever.
+	softAssert(t, clock.t.Sub(start) < time.Second, "")
+	assert.Equal(t, []string{a, b}, diffRebuildtexts(diffs))
+	// A timeout while bisecting leaves the middle block as a single edit.
+	dmp.Clock = &stepClock{clock.t, time.Second}
+	assert.Equal(t, []Diff{Diff{DiffDelete, "cat"}, Diff{DiffInsert, "map"}}, dmp.DiffMain("cat", "map", false))
+	dmp.Clock = nil
 	dmp.DiffTimeout = 0
 
 	// Test the linemode speedup.
@@ -874,22 +916,224 @@ func Test_diffMain(t *testing.T) {
 	// Test null inputs -- not needed because nulls can't be passed in C#.
 }
 
+func Test_diffMainContext(t *testing.T) {
+	dmp := createDMP()
+	dmp.DiffTimeout = 0
+
+	diffs, err := dmp.DiffMainContext(context.Background(), "cat", "map")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []Diff{
+		Diff{DiffDelete, "c"},
+		Diff{DiffInsert, "m"},
+		Diff{DiffEqual, "a"},
+		Diff{DiffDelete, "t"},
+		Diff{DiffInsert, "p"}}, diffs)
+
+	// A cancelled context still yields a usable, if coarse, diff.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	diffs, err = dmp.DiffMainContext(ctx, "The cat sat.", "The map sat.")
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, []Diff{
+		Diff{DiffEqual, "The "},
+		Diff{DiffDelete, "cat"},
+		Diff{DiffInsert, "map"},
+		Diff{DiffEqual, " sat."}}, diffs)
+
+	// Deadlines set on the context are honoured as well.
+	ctx, cancel = context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	diffs, err = dmp.DiffMainContext(ctx, "cat", "map")
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, []string{"cat", "map"}, diffRebuildtexts(diffs))
+
+	// DiffTimeout is measured against the injected clock.
+	dmp.DiffTimeout = 1
+	dmp.Clock = &stepClock{time.Unix(0, 0), 2 * time.Second}
+	diffs, err = dmp.DiffMainContext(context.Background(), "cat", "map")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []Diff{Diff{DiffDelete, "cat"}, Diff{DiffInsert, "map"}}, diffs)
+}
+
+// unicodeText generates random strings mixing ASCII, multibyte characters
+// that share leading or trailing bytes, combining marks and line breaks.
+type unicodeText string
+
+var unicodeAlphabet = []rune("ab \n\u00e9\u00e8\u0301\u4e00\u4e01\u4f00\U0001F600\U0001F601\U0001F680")
+
+func (unicodeText) Generate(rand *rand.Rand, size int) reflect.Value {
+	if rand.Intn(4) == 0 {
+		// Some long multi-line texts so that line mode kicks in.
+		size *= 20
+	}
+	text := make([]rune, rand.Intn(size+1))
+	for i := range text {
+		text[i] = unicodeAlphabet[rand.Intn(len(unicodeAlphabet))]
+	}
+	return reflect.ValueOf(unicodeText(text))
+}
+
+func Test_diffMainUnicode(t *testing.T) {
+	dmp := createDMP()
+	dmp.DiffTimeout = 0
+
+	valid := func(diffs []Diff) bool {
+		for _, d := range diffs {
+			if len(d.Text) == 0 || !utf8.ValidString(d.Text) {
+				return false
+			}
+		}
+		return true
+	}
+	rebuilds := func(diffs []Diff, text1, text2 string) bool {
+		return dmp.DiffText1(diffs) == text1 && dmp.DiffText2(diffs) == text2
+	}
+
+	property := func(a, b unicodeText) bool {
+		text1, text2 := string(a), string(b)
+		for _, checklines := range []bool{false, true} {
+			diffs := dmp.DiffMain(text1, text2, checklines)
+			if !valid(diffs) || !rebuilds(diffs, text1, text2) {
+				return false
+			}
+			for _, cleaned := range [][]Diff{
+				dmp.DiffCleanupSemantic(append([]Diff(nil), diffs...)),
+				dmp.DiffCleanupSemanticLossless(append([]Diff(nil), diffs...)),
+				dmp.DiffCleanupEfficiency(append([]Diff(nil), diffs...)),
+			} {
+				if !valid(cleaned) || !rebuilds(cleaned, text1, text2) {
+					return false
+				}
+			}
+		}
+		diffs := dmp.DiffBisect(text1, text2, time.Time{})
+		return valid(diffs) && rebuilds(diffs, text1, text2)
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+
+	// Edits next to characters that share bytes stay on rune boundaries.
+	assert.Equal(t, []Diff{
+		Diff{DiffDelete, "\U0001F600"},
+		Diff{DiffInsert, "\U0001F601"}},
+		dmp.DiffMain("\U0001F600", "\U0001F601", false))
+	assert.Equal(t, []Diff{
+		Diff{DiffEqual, "\u4e00"},
+		Diff{DiffInsert, "\u4e01"},
+		Diff{DiffEqual, "\u4e00"}},
+		dmp.DiffMain("\u4e00\u4e00", "\u4e00\u4e01\u4e00", false))
+	dmp.DiffTimeout = 1
+	assert.Equal(t, []string{"\u00e9\u4e00", "\u4e01\u00e9", "\u4e01", "\u4e00", "\U0001F600\U0001F600\U0001F600\U0001F600"},
+		dmp.DiffHalfMatch("\u00e9\u4e00\U0001F600\U0001F600\U0001F600\U0001F600\u4e01\u00e9", "\u4e01\U0001F600\U0001F600\U0001F600\U0001F600\u4e00"))
+	assert.Equal(t, "=1\t-1\t+%F0%9F%98%81", dmp.DiffToDelta(dmp.DiffMain("a\U0001F600", "a\U0001F601", false)))
+	assert.Equal(t, 1, dmp.DiffLevenshtein(dmp.DiffMain("\u4e00", "\u4e01", false)))
+}
+
+func Test_patchContext(t *testing.T) {
+	dmp := createDMP()
+	text1 := "The quick brown fox jumps over the lazy dog."
+	text2 := "That quick brown fox jumped over a lazy dog."
+
+	patches, err := dmp.PatchMakeContext(context.Background(), text1, text2)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, dmp.PatchToText(dmp.PatchMake(text1, text2)), dmp.PatchToText(patches))
+
+	result, applied, err := dmp.PatchApplyContext(context.Background(), patches, text1)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, text2, result)
+	assert.Equal(t, []bool{true, true}, applied)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, applied, err = dmp.PatchApplyContext(ctx, patches, text1)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, text1, result)
+	assert.Equal(t, []bool{false, false}, applied)
+
+	patches, err = dmp.PatchMakeContext(ctx, text1, text2)
+	assert.Equal(t, context.Canceled, err)
+	result, _ = dmp.PatchApply(patches, text1)
+	assert.Equal(t, text2, result)
+}
+
+func Test_diffMainWithOptions(t *testing.T) {
+	dmp := createDMP()
+	dmp.DiffTimeout = 0
+
+	// The zero options are a plain character diff.
+	assert.Equal(t, dmp.DiffMain("Apples are a fruit.", "Bananas are also fruit.", false),
+		dmp.DiffMainWithOptions("Apples are a fruit.", "Bananas are also fruit.", DiffOptions{}))
+
+	// Cleanup modes.
+	assert.Equal(t, []Diff{
+		Diff{DiffDelete, "abc"},
+		Diff{DiffInsert, "12"},
+		Diff{DiffEqual, "d"},
+		Diff{DiffDelete, "e"},
+		Diff{DiffInsert, "34"}}, dmp.DiffMainWithOptions("abcde", "12d34", DiffOptions{}))
+	assert.Equal(t, []Diff{
+		Diff{DiffDelete, "abcde"},
+		Diff{DiffInsert, "12d34"}}, dmp.DiffMainWithOptions("abcde", "12d34", DiffOptions{Cleanup: CleanupSemantic}))
+	assert.Equal(t, []Diff{
+		Diff{DiffEqual, "The "},
+		Diff{DiffInsert, "big "},
+		Diff{DiffEqual, "cat."}}, dmp.DiffMainWithOptions("The cat.", "The big cat.", DiffOptions{Cleanup: CleanupSemanticLossless}))
+	assert.Equal(t, []Diff{
+		Diff{DiffDelete, "abxyzcd"},
+		Diff{DiffInsert, "12xyz34"}}, dmp.DiffMainWithOptions("abxyzcd", "12xyz34", DiffOptions{Cleanup: CleanupEfficiency}))
+
+	// An explicit deadline overrides DiffTimeout.
+	assert.Equal(t, []Diff{Diff{DiffDelete, "cat"}, Diff{DiffInsert, "map"}},
+		dmp.DiffMainWithOptions("cat", "map", DiffOptions{Deadline: time.Now().Add(-time.Minute)}))
+
+	// Arguments of the wrong type no longer panic.
+	assert.Equal(t, []Diff{Diff{DiffEqual, "ab"}, Diff{DiffInsert, "123"}, Diff{DiffEqual, "c"}},
+		dmp.DiffMain("abc", "ab123c", "true", int32(0)))
+}
+
+func Test_patchMakeTyped(t *testing.T) {
+	dmp := createDMP()
+	text1 := "The quick brown fox jumps over the lazy dog."
+	text2 := "That quick brown fox jumped over a lazy dog."
+	expectedPatch := "@@ -1,11 +1,12 @@\n Th\n-e\n+at\n  quick b\n@@ -22,18 +22,17 @@\n jump\n-s\n+ed\n  over \n-the\n+a\n  laz\n"
+
+	assert.Equal(t, expectedPatch, dmp.PatchToText(dmp.PatchMakeFromTexts(text1, text2)))
+
+	diffs := dmp.DiffMain(text1, text2, false)
+	assert.Equal(t, expectedPatch, dmp.PatchToText(dmp.PatchMakeFromDiffs(diffs)))
+	assert.Equal(t, expectedPatch, dmp.PatchToText(dmp.PatchMakeFromTextAndDiffs(text1, diffs)))
+
+	// The deprecated form ignores arguments it doesn't understand.
+	assert.Equal(t, []Patch{}, dmp.PatchMake(42))
+	assert.Equal(t, []Patch{}, dmp.PatchMake(text1, 42))
+	assert.Equal(t, []Patch{}, dmp.PatchMake(text1, text2, text2))
+}
+
 func Test_match_alphabet(t *testing.T) {
 	dmp := createDMP()
 	// Initialise the bitmasks for Bitap.
-	bitmask := map[byte]int{
+	bitmask := map[rune]int{
 		'a': 4,
 		'b': 2,
 		'c': 1,
 	}
-	assertSeqEqual(bitmask, dmp.MatchAlphabet("abc"))
+	assert.Equal(t, bitmask, dmp.MatchAlphabet("abc"))
 
-	bitmask = map[byte]int{
+	bitmask = map[rune]int{
 		'a': 37,
 		'b': 18,
 		'c': 8,
 	}
-	assertSeqEqual(bitmask, dmp.MatchAlphabet("abcaba"))
+	assert.Equal(t, bitmask, dmp.MatchAlphabet("abcaba"))
+
+	// A bit per character, not per byte.
+	bitmask = map[rune]int{
+		'д': 4,
+		'ж': 2,
+		'🐱': 1,
+	}
+	assert.Equal(t, bitmask, dmp.MatchAlphabet("дж🐱"))
 }
 
 func Test_match_bitap(t *testing.T) {
@@ -937,6 +1181,58 @@ func Test_match_bitap(t *testing.T) {
 
 	dmp.MatchDistance = 1000 // Loose location.
 	assert.Equal(t, 0, dmp.MatchBitap("abcdefghijklmnopqrstuvwxyz", "abcdefg", 24), "match_bitap: Distance test #3.")
+
+	// Patterns longer than a word.
+	dmp.MatchDistance = 100
+	dmp.MatchThreshold = 0.5
+	r := rand.New(rand.NewSource(1))
+	letters := make([]byte, 500)
+	for i := range letters {
+		letters[i] = byte('a' + r.Intn(26))
+	}
+	text := string(letters)
+	for _, n := range []int{63, 64, 65, 128, 200} {
+		pattern := []byte(text[150 : 150+n])
+		assert.Equal(t, 150, dmp.MatchBitap(text, string(pattern), 150), "match_bitap: Long exact match.", n)
+		for k := 0; k < n/10; k++ {
+			pattern[r.Intn(n)] = '0'
+		}
+		assert.Equal(t, 150, dmp.MatchBitap(text, string(pattern), 150), "match_bitap: Long fuzzy match.", n)
+		assert.Equal(t, 150, dmp.MatchBitap(text, string(pattern[:n/2])+string(pattern[n/2+2:]), 150), "match_bitap: Long fuzzy match with deletion.", n)
+	}
+	// An error in the first character of a pattern that spans words.
+	assert.Equal(t, 10, dmp.MatchBitap(text, "0"+text[11:100], 10), "match_bitap: Long match with first error.")
+	assert.Equal(t, -1, dmp.MatchBitap(text, strings.Repeat("0", 100), 10), "match_bitap: Long mismatch.")
+
+	// Characters outside ASCII are one error each, and matches start at a
+	// character.
+	dmp.MatchDistance = 100
+	dmp.MatchThreshold = 0.25
+	assert.Equal(t, 3, dmp.MatchBitap("abcdéfghijk", "défxh", 0), "match_bitap: Accented fuzzy match.")
+	assert.Equal(t, 3, dmp.MatchBitap("abcdefghijk", "défgh", 0), "match_bitap: Accented pattern.")
+	assert.Equal(t, 16, dmp.MatchBitap("Съешь же ещё этих мягких французских булок", "ещк этих", 0), "match_bitap: Cyrillic.")
+	assert.Equal(t, 3, dmp.MatchBitap("日本語のテキストです", "本語のテクスト", 3), "match_bitap: Japanese.")
+	assert.Equal(t, 4, dmp.MatchBitap("🐱🐶🐭🐹🐰🦊🐻", "🐶🐭🐹🐻", 5), "match_bitap: Astral plane.")
+	assert.Equal(t, 3, dmp.MatchBitap("aé日", "日", 4), "match_bitap: Location inside a character.")
+}
+
+func Test_MatchMainUnit(t *testing.T) {
+	dmp := createDMP()
+	text := "Ξεσκεπάζω την ψυχοφθόρα 🐱 βδελυγμία"
+	// "ψυχοφθόρα" starts at byte 26 and rune 14, the cat at byte 45, rune 24
+	// and UTF-16 unit 24, and "βδελυγμία" at rune 26 and UTF-16 unit 27.
+	assert.Equal(t, 26, dmp.MatchMainUnit(text, "ψυχοφθώρα", 20, UnitByte))
+	assert.Equal(t, 14, dmp.MatchMainUnit(text, "ψυχοφθώρα", 10, UnitRune))
+	assert.Equal(t, 14, dmp.MatchMainUnit(text, "ψυχοφθώρα", 10, UnitUTF16))
+	assert.Equal(t, 45, dmp.MatchMainUnit(text, "🐱", 40, UnitByte))
+	assert.Equal(t, 24, dmp.MatchMainUnit(text, "🐱 β", 24, UnitRune))
+	assert.Equal(t, 26, dmp.MatchMainUnit(text, "βδελιγμία", 30, UnitRune))
+	assert.Equal(t, 27, dmp.MatchMainUnit(text, "βδελιγμία", 30, UnitUTF16))
+	// Locations beyond the end or inside a character.
+	assert.Equal(t, 26, dmp.MatchMainUnit(text, "βδελιγμία", 1000, UnitRune))
+	assert.Equal(t, 26, dmp.MatchMainUnit(text, "ψυχοφθόρα", 27, UnitByte))
+	assert.Equal(t, 24, dmp.MatchMainUnit(text, "🐱", 25, UnitUTF16))
+	assert.Equal(t, -1, dmp.MatchMainUnit(text, "日本語", 0, UnitRune))
 }
 
 func Test_MatchMain(t *testing.T) {
@@ -981,6 +1277,50 @@ func Test_patch_patchObj(t *testing.T) {
 	assert.Equal(t, strp, p.String(), "Patch: toString.")
 }
 
+func Test_patch_accessors(t *testing.T) {
+	diffs := []Diff{
+		Diff{DiffEqual, "jump"},
+		Diff{DiffDelete, "s"},
+		Diff{DiffInsert, "ed"},
+		Diff{DiffEqual, " over "},
+		Diff{DiffDelete, "the"},
+		Diff{DiffInsert, "a"},
+		Diff{DiffEqual, "\nlaz"}}
+	p := NewPatch(20, 21, diffs)
+	assert.Equal(t, "@@ -21,18 +22,17 @@\n jump\n-s\n+ed\n  over \n-the\n+a\n %0Alaz\n", p.String())
+
+	start, length := p.Range1()
+	assert.Equal(t, []int{20, 18}, []int{start, length})
+	start, length = p.Range2()
+	assert.Equal(t, []int{21, 17}, []int{start, length})
+
+	// Diffs hands out a copy.
+	assert.Equal(t, diffs, p.Diffs())
+	p.Diffs()[0].Text = "leap"
+	assert.Equal(t, "jump", p.Diffs()[0].Text)
+
+	dmp := createDMP()
+	assert.Equal(t, nil, p.Validate())
+	parsed, _ := dmp.PatchFromText(p.String())
+	assert.T(t, p.Equal(parsed[0]))
+	assert.T(t, !p.Equal(NewPatch(20, 22, diffs)))
+	assert.T(t, !p.Equal(NewPatch(20, 21, diffs[1:])))
+
+	p.length1 = 19
+	softAssert(t, p.Validate() != nil, "Validate: length1 mismatch.")
+	p = NewPatch(20, 21, diffs)
+	p.length2 = 3
+	softAssert(t, p.Validate() != nil, "Validate: length2 mismatch.")
+	p = NewPatch(-1, 0, diffs)
+	softAssert(t, p.Validate() != nil, "Validate: negative start.")
+	p = NewPatch(0, 0, []Diff{Diff{7, "x"}})
+	softAssert(t, p.Validate() != nil, "Validate: bad operation.")
+
+	for _, p := range dmp.PatchMakeFromTexts("The quick brown fox jumps over the lazy dog.", "That quick brown fox jumped over a lazy dog.") {
+		assert.Equal(t, nil, p.Validate())
+	}
+}
+
 func Test_patch_fromText(t *testing.T) {
 	dmp := createDMP()
 
@@ -1071,7 +1411,7 @@ func Test_patch_make(t *testing.T) {
 	assert.Equal(t, expectedPatch, dmp.PatchToText(patches), "patch_make: Text1+Text2+Diff inputs (deprecated).")
 
 	patches = dmp.PatchMake("`1234567890-=[]\\;',./", "~!@#$%^&*()_+{}|:\"<>?")
-	assert.Equal(t, "@@ -1,21 +1,21 @@\n-%601234567890-=%5b%5d%5c;',./\n+~!@#$%25%5e&*()_+%7b%7d%7c:%22%3c%3e?\n",
+	assert.Equal(t, "@@ -1,21 +1,21 @@\n-%601234567890-=%5B%5D%5C;',./\n+~!@#$%25%5E&*()_+%7B%7D%7C:%22%3C%3E?\n",
 		dmp.PatchToText(patches),
 		"patch_toText: Character encoding.")
 
@@ -1089,6 +1429,7 @@ func Test_patch_make(t *testing.T) {
 		text1 += "abcdef"
 	}
 	text2 = text1 + "123"
+	dmp.MatchMaxBits = 32
 	expectedPatch = "@@ -573,28 +573,31 @@\n cdefabcdefabcdefabcdefabcdef\n+123\n"
 	patches = dmp.PatchMake(text1, text2)
 	assert.Equal(t, expectedPatch, dmp.PatchToText(patches), "patch_make: Long string with repeats.")
@@ -1097,26 +1438,36 @@ func Test_patch_make(t *testing.T) {
 }
 
 func Test_PatchSplitMax(t *testing.T) {
-	// Assumes that Match_MaxBits is 32.
 	dmp := createDMP()
+	dmp.MatchMaxBits = 32
 	var patches []Patch
 
 	patches = dmp.PatchMake("abcdefghijklmnopqrstuvwxyz01234567890", "XabXcdXefXghXijXklXmnXopXqrXstXuvXwxXyzX01X23X45X67X89X0")
-	dmp.PatchSplitMax(patches)
+	patches = dmp.PatchSplitMax(patches)
 	assert.Equal(t, "@@ -1,32 +1,46 @@\n+X\n ab\n+X\n cd\n+X\n ef\n+X\n gh\n+X\n ij\n+X\n kl\n+X\n mn\n+X\n op\n+X\n qr\n+X\n st\n+X\n uv\n+X\n wx\n+X\n yz\n+X\n 012345\n@@ -25,13 +39,18 @@\n zX01\n+X\n 23\n+X\n 45\n+X\n 67\n+X\n 89\n+X\n 0\n", dmp.PatchToText(patches))
 
 	patches = dmp.PatchMake("abcdef1234567890123456789012345678901234567890123456789012345678901234567890uvwxyz", "abcdefuvwxyz")
 	oldToText := dmp.PatchToText(patches)
-	dmp.PatchSplitMax(patches)
+	patches = dmp.PatchSplitMax(patches)
 	assert.Equal(t, oldToText, dmp.PatchToText(patches))
 
 	patches = dmp.PatchMake("1234567890123456789012345678901234567890123456789012345678901234567890", "abc")
-	dmp.PatchSplitMax(patches)
+	patches = dmp.PatchSplitMax(patches)
 	assert.Equal(t, "@@ -1,32 +1,4 @@\n-1234567890123456789012345678\n 9012\n@@ -29,32 +1,4 @@\n-9012345678901234567890123456\n 7890\n@@ -57,14 +1,3 @@\n-78901234567890\n+abc\n", dmp.PatchToText(patches))
 
 	patches = dmp.PatchMake("abcdefghij , h : 0 , t : 1 abcdefghij , h : 0 , t : 1 abcdefghij , h : 0 , t : 1", "abcdefghij , h : 1 , t : 1 abcdefghij , h : 1 , t : 1 abcdefghij , h : 0 , t : 1")
-	dmp.PatchSplitMax(patches)
+	patches = dmp.PatchSplitMax(patches)
 	assert.Equal(t, "@@ -2,32 +2,32 @@\n bcdefghij , h : \n-0\n+1\n  , t : 1 abcdef\n@@ -29,32 +29,32 @@\n bcdefghij , h : \n-0\n+1\n  , t : 1 abcdef\n", dmp.PatchToText(patches))
+
+	// Patches are split between characters.
+	patches = dmp.PatchMake("xy"+strings.Repeat("在们", 10)+"z", "xy"+strings.Repeat("人", 10)+"z")
+	patches = dmp.PatchSplitMax(patches)
+	assert.T(t, len(patches) > 1)
+	for _, patch := range patches {
+		for _, aDiff := range patch.diffs {
+			assert.T(t, utf8.ValidString(aDiff.Text), aDiff.Text)
+		}
+	}
 }
 
 func Test_PatchAddPadding(t *testing.T) {
@@ -1152,6 +1503,7 @@ func Test_PatchAddPadding(t *testing.T) {
 
 func Test_patchApply(t *testing.T) {
 	dmp := createDMP()
+	dmp.MatchMaxBits = 32
 	dmp.MatchDistance = 1000
 	dmp.MatchThreshold = 0.5
 	dmp.PatchDeleteThreshold = 0.5
@@ -1159,52 +1511,69 @@ func Test_patchApply(t *testing.T) {
 	patches = dmp.PatchMake("", "")
 	results0, results1 := dmp.PatchApply(patches, "Hello world.")
 	boolArray := results1
-	resultStr := results0 + "\t" + string(len(boolArray))
+	resultStr := results0 + "\t" + strconv.Itoa(len(boolArray))
 	assert.Equal(t, "Hello world.\t0", resultStr, "patch_apply: Null case.")
 
 	patches = dmp.PatchMake("The quick brown fox jumps over the lazy dog.", "That quick brown fox jumped over a lazy dog.")
 	results0, results1 = dmp.PatchApply(patches, "The quick brown fox jumps over the lazy dog.")
 	boolArray = results1
 	resultStr = results0 + "\t" + strconv.FormatBool(boolArray[0]) + "\t" + strconv.FormatBool(boolArray[1])
-	assert.Equal(t, "That quick brown fox jumped over a lazy dog.\tTrue\tTrue", resultStr, "patch_apply: Exact match.")
+	assert.Equal(t, "That quick brown fox jumped over a lazy dog.\ttrue\ttrue", resultStr, "patch_apply: Exact match.")
 
 	results0, results1 = dmp.PatchApply(patches, "The quick red rabbit jumps over the tired tiger.")
 	boolArray = results1
 	resultStr = results0 + "\t" + strconv.FormatBool(boolArray[0]) + "\t" + strconv.FormatBool(boolArray[1])
-	assert.Equal(t, "That quick red rabbit jumped over a tired tiger.\tTrue\tTrue", resultStr, "patch_apply: Partial match.")
+	assert.Equal(t, "That quick red rabbit jumped over a tired tiger.\ttrue\ttrue", resultStr, "patch_apply: Partial match.")
 
 	results0, results1 = dmp.PatchApply(patches, "I am the very model of a modern major general.")
 	boolArray = results1
 	resultStr = results0 + "\t" + strconv.FormatBool(boolArray[0]) + "\t" + strconv.FormatBool(boolArray[1])
-	assert.Equal(t, "I am the very model of a modern major general.\tFalse\tFalse", resultStr, "patch_apply: Failed match.")
+	assert.Equal(t, "I am the very model of a modern major general.\tfalse\tfalse", resultStr, "patch_apply: Failed match.")
 
 	patches = dmp.PatchMake("x1234567890123456789012345678901234567890123456789012345678901234567890y", "xabcy")
 	results0, results1 = dmp.PatchApply(patches, "x123456789012345678901234567890-----++++++++++-----123456789012345678901234567890y")
 	boolArray = results1
 	resultStr = results0 + "\t" + strconv.FormatBool(boolArray[0]) + "\t" + strconv.FormatBool(boolArray[1])
-	assert.Equal(t, "xabcy\tTrue\tTrue", resultStr, "patch_apply: Big delete, small Diff.")
+	assert.Equal(t, "xabcy\ttrue\ttrue", resultStr, "patch_apply: Big delete, small Diff.")
 
 	patches = dmp.PatchMake("x1234567890123456789012345678901234567890123456789012345678901234567890y", "xabcy")
 	results0, results1 = dmp.PatchApply(patches, "x12345678901234567890---------------++++++++++---------------12345678901234567890y")
 	boolArray = results1
 	resultStr = results0 + "\t" + strconv.FormatBool(boolArray[0]) + "\t" + strconv.FormatBool(boolArray[1])
-	assert.Equal(t, "xabc12345678901234567890---------------++++++++++---------------12345678901234567890y\tFalse\tTrue", resultStr, "patch_apply: Big delete, big Diff 1.")
+	assert.Equal(t, "xabc12345678901234567890---------------++++++++++---------------12345678901234567890y\tfalse\ttrue", resultStr, "patch_apply: Big delete, big Diff 1.")
 
 	dmp.PatchDeleteThreshold = 0.6
 	patches = dmp.PatchMake("x1234567890123456789012345678901234567890123456789012345678901234567890y", "xabcy")
 	results0, results1 = dmp.PatchApply(patches, "x12345678901234567890---------------++++++++++---------------12345678901234567890y")
 	boolArray = results1
 	resultStr = results0 + "\t" + strconv.FormatBool(boolArray[0]) + "\t" + strconv.FormatBool(boolArray[1])
-	assert.Equal(t, "xabcy\tTrue\tTrue", resultStr, "patch_apply: Big delete, big Diff 2.")
+	assert.Equal(t, "xabcy\ttrue\ttrue", resultStr, "patch_apply: Big delete, big Diff 2.")
 	dmp.PatchDeleteThreshold = 0.5
 
+	dmp.MatchMaxBits = 100
+	patches = dmp.PatchMake("x1234567890123456789012345678901234567890123456789012345678901234567890y", "xabcy")
+	results0, results1 = dmp.PatchApply(patches, "x123456789012345678901234567890-----+++++-----1234567890123456789012345y")
+	assert.Equal(t, "xabcy", results0, "patch_apply: Big delete matched whole.")
+	assert.Equal(t, []bool{true}, results1, "patch_apply: Big delete matched whole.")
+	dmp.MatchMaxBits = 32
+
+	// The start and end of a big delete are cut between characters.
+	text1 := "在在们一上人的个的来为是他有来为人中我以" +
+		"我有不之不人上人为以中在这这人这之上大为是了中在中为来我是一们我这大我们一之以人这在他有上了不了在之之个们的我为个的为了了人" +
+		"不一这是上在为上来是不是有不有我这以的了"
+	text2 := "在在们一上人的个的来为是他有来为人中我以不一这是上在为上来是不是有不有我这以的了"
+	patches = dmp.PatchMake(text1, text2)
+	results0, results1 = dmp.PatchApply(patches, "xy"+text1)
+	assert.Equal(t, "xy"+text2, results0, "patch_apply: Big delete of wide characters.")
+	assert.Equal(t, []bool{true}, results1, "patch_apply: Big delete of wide characters.")
+
 	dmp.MatchThreshold = 0.0
 	dmp.MatchDistance = 0
 	patches = dmp.PatchMake("abcdefghijklmnopqrstuvwxyz--------------------1234567890", "abcXXXXXXXXXXdefghijklmnopqrstuvwxyz--------------------1234567YYYYYYYYYY890")
 	results0, results1 = dmp.PatchApply(patches, "ABCDEFGHIJKLMNOPQRSTUVWXYZ--------------------1234567890")
 	boolArray = results1
 	resultStr = results0 + "\t" + strconv.FormatBool(boolArray[0]) + "\t" + strconv.FormatBool(boolArray[1])
-	assert.Equal(t, "ABCDEFGHIJKLMNOPQRSTUVWXYZ--------------------1234567YYYYYYYYYY890\tFalse\tTrue", resultStr, "patch_apply: Compensate for failed patch.")
+	assert.Equal(t, "ABCDEFGHIJKLMNOPQRSTUVWXYZ--------------------1234567YYYYYYYYYY890\tfalse\ttrue", resultStr, "patch_apply: Compensate for failed patch.")
 	dmp.MatchThreshold = 0.5
 	dmp.MatchDistance = 1000
 
@@ -1222,17 +1591,46 @@ func Test_patchApply(t *testing.T) {
 	results0, results1 = dmp.PatchApply(patches, "")
 	boolArray = results1
 	resultStr = results0 + "\t" + strconv.FormatBool(boolArray[0])
-	assert.Equal(t, "test\tTrue", resultStr, "patch_apply: Edge exact match.")
+	assert.Equal(t, "test\ttrue", resultStr, "patch_apply: Edge exact match.")
 
 	patches = dmp.PatchMake("XY", "XtestY")
 	results0, results1 = dmp.PatchApply(patches, "XY")
 	boolArray = results1
 	resultStr = results0 + "\t" + strconv.FormatBool(boolArray[0])
-	assert.Equal(t, "XtestY\tTrue", resultStr, "patch_apply: Near edge exact match.")
+	assert.Equal(t, "XtestY\ttrue", resultStr, "patch_apply: Near edge exact match.")
 
 	patches = dmp.PatchMake("y", "y123")
 	results0, results1 = dmp.PatchApply(patches, "x")
 	boolArray = results1
 	resultStr = results0 + "\t" + strconv.FormatBool(boolArray[0])
-	assert.Equal(t, "x123\tTrue", resultStr, "patch_apply: Edge partial match.")
+	assert.Equal(t, "x123\ttrue", resultStr, "patch_apply: Edge partial match.")
+}
+
+func Test_patchApplyLong(t *testing.T) {
+	dmp := createDMP()
+	r := rand.New(rand.NewSource(1))
+	letters := func(n int) []byte {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = byte('a' + r.Intn(26))
+		}
+		return b
+	}
+	pre, head, middle, tail, post := letters(50), letters(40), letters(120), letters(40), letters(50)
+	block := string(head) + string(middle) + string(tail)
+	patches := dmp.PatchMake(string(pre)+block+string(post), string(pre)+string(post))
+	assert.Equal(t, 1, len(patches))
+
+	// A copy of the deleted block with the same ends but a different
+	// middle comes first.  Matched by its ends, as with MatchMaxBits of
+	// 32, the patch would delete the copy.
+	for i := 0; i < len(middle); i++ {
+		if r.Intn(10) < 6 {
+			middle[i] = byte('a' + r.Intn(26))
+		}
+	}
+	decoy := string(head) + string(middle) + string(tail)
+	text, results := dmp.PatchApply(patches, string(pre)+decoy+block+string(post))
+	assert.Equal(t, string(pre)+decoy+string(post), text)
+	assert.Equal(t, []bool{true}, results)
 }
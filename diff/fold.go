@@ -0,0 +1,322 @@
+package diffmatchpatch
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Folder maps text to the form in which it is compared, so that texts
+// that differ only in ways that don't matter compare equal.  Fold is
+// given a character at a time together with the combining marks that
+// follow it and the letters it composes with, such as the jamo of a
+// Hangul syllable, so a Folder that normalizes text, such as
+// FolderFunc(norm.NFC.String) from golang.org/x/text/unicode/norm, makes
+// the canonical normalization form irrelevant.
+type Folder interface {
+	Fold(s string) string
+}
+
+// FolderFunc adapts a function to a Folder.
+type FolderFunc func(s string) string
+
+// Fold calls f(s).
+func (f FolderFunc) Fold(s string) string {
+	return f(s)
+}
+
+// Folders returns a Folder that applies folders in turn.
+func Folders(folders ...Folder) Folder {
+	return FolderFunc(func(s string) string {
+		for _, f := range folders {
+			s = f.Fold(s)
+		}
+		return s
+	})
+}
+
+var (
+	// FoldCase ignores case, by Unicode simple case folding.
+	FoldCase Folder = FolderFunc(foldCase)
+	// FoldWidth ignores the difference between the fullwidth and halfwidth
+	// forms of ASCII, of the ideographic space and of katakana.
+	FoldWidth Folder = FolderFunc(foldWidth)
+)
+
+// asciiFolds holds foldCase of each ASCII character, which spares most
+// calls with a single character an allocation.
+var asciiFolds [utf8.RuneSelf]string
+
+func init() {
+	for c := range asciiFolds {
+		asciiFolds[c] = foldCase(string(rune(c)))
+	}
+}
+
+func foldCase(s string) string {
+	if len(s) == 1 && s[0] < utf8.RuneSelf && asciiFolds[s[0]] != "" {
+		return asciiFolds[s[0]]
+	}
+	return strings.Map(func(r rune) rune {
+		// The smallest character of those that fold to each other.
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			if f < r {
+				r = f
+			}
+		}
+		return r
+	}, s)
+}
+
+// halfwidthKatakana holds the fullwidth forms of U+FF61 to U+FF9F.
+var halfwidthKatakana = []rune("。「」、・ヲァィゥェォャュョッーアイウエオカキクケコサシスセソタチツテトナニヌネノハヒフヘホマミムメモヤユヨラリルレロワン゛゜")
+
+func foldWidth(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 0xff01 && r <= 0xff5e:
+			// Fullwidth ASCII.
+			return r - 0xff01 + '!'
+		case r == 0x3000:
+			return ' '
+		case r >= 0xff61 && r <= 0xff9f:
+			return halfwidthKatakana[r-0xff61]
+		}
+		return r
+	}, s)
+}
+
+// foldEnd returns the end of the character of text starting at i together
+// with the combining marks that follow it and the letters it composes
+// with, those of a Hangul syllable as UAX #29 delimits them and the Kirat
+// Rai vowel signs.  A line break takes no marks, so that lines fold the
+// same on their own as within the text.
+func foldEnd(text string, i int) int {
+	if text[i] == '\n' {
+		return i + 1
+	}
+	r, size := utf8.DecodeRuneInString(text[i:])
+	for i += size; i < len(text); i += size {
+		prev := r
+		r, size = utf8.DecodeRuneInString(text[i:])
+		if !unicode.Is(unicode.M, r) && !composes(prev, r) {
+			break
+		}
+	}
+	return i
+}
+
+// Hangul syllable types of UAX #29: leading, vowel and trailing jamo and
+// the precomposed syllables without and with a trailing consonant.
+const (
+	hangulNone = iota
+	hangulL
+	hangulV
+	hangulT
+	hangulLV
+	hangulLVT
+)
+
+// hangulType returns the Hangul syllable type of r.
+func hangulType(r rune) int {
+	switch {
+	case r < 0x1100:
+		return hangulNone
+	case r <= 0x115f, r >= 0xa960 && r <= 0xa97c:
+		return hangulL
+	case r <= 0x11a7, r >= 0xd7b0 && r <= 0xd7c6:
+		return hangulV
+	case r <= 0x11ff, r >= 0xd7cb && r <= 0xd7fb:
+		return hangulT
+	case r >= 0xac00 && r <= 0xd7a3:
+		if (r-0xac00)%28 == 0 {
+			return hangulLV
+		}
+		return hangulLVT
+	}
+	return hangulNone
+}
+
+// composes reports whether the letter r continues the character prev
+// rather than starting one, which is the case for the jamo of a Hangul
+// syllable and for Kirat Rai vowel sign E after the signs it composes
+// with.
+func composes(prev, r rune) bool {
+	if r == 0x16d67 {
+		return prev == 0x16d63 || prev == 0x16d67 || prev == 0x16d69
+	}
+	switch next := hangulType(r); hangulType(prev) {
+	case hangulL:
+		return next != hangulNone && next != hangulT
+	case hangulV, hangulLV:
+		return next == hangulV || next == hangulT
+	case hangulT, hangulLVT:
+		return next == hangulT
+	}
+	return false
+}
+
+// foldText folds text with Folder.
+func (dmp *DiffMatchPatch) foldText(text string) string {
+	var folded strings.Builder
+	for i := 0; i < len(text); {
+		end := foldEnd(text, i)
+		folded.WriteString(dmp.Folder.Fold(text[i:end]))
+		i = end
+	}
+	return folded.String()
+}
+
+// keyHash returns the 64-bit FNV-1a hash of lineKey(text) without making
+// the folded copy.
+func (dmp *DiffMatchPatch) keyHash(text string) uint64 {
+	if dmp.Folder == nil {
+		return fnv64(fnvOffset64, text)
+	}
+	h := uint64(fnvOffset64)
+	for i := 0; i < len(text); {
+		end := foldEnd(text, i)
+		h = fnv64(h, dmp.Folder.Fold(text[i:end]))
+		i = end
+	}
+	return h
+}
+
+// keyEqual reports whether a and b fold the same character by character,
+// or are equal if there is no Folder.  It makes no folded copies.
+func (dmp *DiffMatchPatch) keyEqual(a, b string) bool {
+	if dmp.Folder == nil {
+		return a == b
+	}
+	for len(a) != 0 && len(b) != 0 {
+		endA, endB := foldEnd(a, 0), foldEnd(b, 0)
+		if dmp.Folder.Fold(a[:endA]) != dmp.Folder.Fold(b[:endB]) {
+			return false
+		}
+		a, b = a[endA:], b[endB:]
+	}
+	return len(a) == len(b)
+}
+
+// foldIDs numbers the characters of text, each with the combining marks
+// following it, giving those that fold to the same text the same number.
+// It returns the numbers and the offset of each character and of the end
+// of text.
+func (dmp *DiffMatchPatch) foldIDs(text string, numbers map[string]int) ([]int, []int) {
+	n := utf8.RuneCountInString(text)
+	ids := make([]int, 0, n)
+	offsets := make([]int, 0, n+1)
+	for i := 0; i < len(text); {
+		end := foldEnd(text, i)
+		key := dmp.Folder.Fold(text[i:end])
+		id, ok := numbers[key]
+		if !ok {
+			id = len(numbers)
+			numbers[key] = id
+		}
+		ids = append(ids, id)
+		offsets = append(offsets, i)
+		i = end
+	}
+	return ids, append(offsets, len(text))
+}
+
+// diffFolded finds the differences between two texts as Folder sees them.
+// The texts of the diffs are those of text1 and text2, of text1 for
+// equalities.  Long texts are diffed line by line first if checklines is
+// set, as by diffMainRunes.
+func (dmp *DiffMatchPatch) diffFolded(ctx context.Context, text1, text2 string, checklines bool, deadline time.Time) []Diff {
+	numbers := map[string]int{}
+	ids1, offsets1 := dmp.foldIDs(text1, numbers)
+	ids2, offsets2 := dmp.foldIDs(text2, numbers)
+
+	d := &differ[int]{dmp: dmp, ctx: ctx, deadline: deadline}
+	d.lineMode = func(a, b []int) []Edit[int] {
+		// a and b are slices of ids1 and ids2, so their capacity tells
+		// where they start.
+		i, j := cap(ids1)-cap(a), cap(ids2)-cap(b)
+		end1, end2 := i+len(a), j+len(b)
+		diffs := dmp.diffLineMode(ctx, text1[offsets1[i]:offsets1[end1]], text2[offsets2[j]:offsets2[end2]], deadline)
+		// Convert the diffs back to characters.  Should a cleanup have
+		// cut a character from its marks, so that the diffs disagree with
+		// the characters, diff the characters instead.
+		var edits []Edit[int]
+		for _, aDiff := range diffs {
+			if aDiff.Type == DiffInsert {
+				n := foldCount(offsets2, j, len(aDiff.Text))
+				if n < 0 {
+					return d.compute(a, b, false)
+				}
+				edits = append(edits, Edit[int]{DiffInsert, ids2[j : j+n]})
+				j += n
+				continue
+			}
+			n := foldCount(offsets1, i, len(aDiff.Text))
+			if n < 0 || aDiff.Type == DiffEqual && (j+n > end2 || !sliceEqual(ids1[i:i+n], ids2[j:j+n])) {
+				return d.compute(a, b, false)
+			}
+			edits = append(edits, Edit[int]{aDiff.Type, ids1[i : i+n]})
+			i += n
+			if aDiff.Type == DiffEqual {
+				j += n
+			}
+		}
+		if i != end1 || j != end2 {
+			return d.compute(a, b, false)
+		}
+		return edits
+	}
+	diffs := []Diff{}
+	i, j := 0, 0
+	for _, e := range d.main(ids1, ids2, checklines) {
+		n := len(e.Items)
+		switch e.Type {
+		case DiffInsert:
+			diffs = append(diffs, Diff{DiffInsert, text2[offsets2[j]:offsets2[j+n]]})
+			j += n
+		default:
+			diffs = append(diffs, Diff{e.Type, text1[offsets1[i]:offsets1[i+n]]})
+			i += n
+			if e.Type == DiffEqual {
+				j += n
+			}
+		}
+	}
+	return dmp.DiffCleanupMerge(diffs)
+}
+
+// foldCount returns how many of the characters at offsets, starting with
+// the i-th, make up the next n bytes, or -1 if they end inside one.
+func foldCount(offsets []int, i, n int) int {
+	k := sort.SearchInts(offsets, offsets[i]+n)
+	if k == len(offsets) || offsets[k] != offsets[i]+n {
+		return -1
+	}
+	return k - i
+}
+
+// foldStable reports whether r is a character of its own that Folder leaves
+// as it is, so that text made of such characters folds to itself.
+func (dmp *DiffMatchPatch) foldStable(r rune) bool {
+	return !unicode.Is(unicode.M, r) && hangulType(r) == hangulNone && (r < 0x16d63 || r > 0x16d69) &&
+		dmp.Folder.Fold(string(r)) == string(r)
+}
+
+// lineKey returns a line or other token as it is compared, folded if there
+// is a Folder.
+func (dmp *DiffMatchPatch) lineKey(line string) string {
+	if dmp.Folder != nil {
+		return dmp.foldText(line)
+	}
+	return line
+}
+
+// unfoldedDMP returns a copy of dmp that compares text as is.
+func (dmp *DiffMatchPatch) unfoldedDMP() *DiffMatchPatch {
+	u := *dmp
+	u.Folder = nil
+	return &u
+}
@@ -0,0 +1,184 @@
+package diffmatchpatch
+
+import (
+	"context"
+	"github.com/bmizerany/assert"
+	"golang.org/x/text/unicode/norm"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// composeFolder composes the decomposed accented letters the tests use, as
+// a normalizing Folder would all of them.
+var composeFolder = FolderFunc(strings.NewReplacer("e\u0301", "\u00e9", "o\u0308", "\u00f6").Replace)
+
+func Test_folders(t *testing.T) {
+	assert.Equal(t, FoldCase.Fold("hello wörld σς"), FoldCase.Fold("HELLO WÖRLD ΣΣ"))
+	assert.Equal(t, FoldCase.Fold("kelvin"), FoldCase.Fold("KELVIN"))
+	assert.NotEqual(t, FoldCase.Fold("hello"), FoldCase.Fold("help"))
+
+	assert.Equal(t, "ABC 123 カタカナ゛!", FoldWidth.Fold("ＡＢＣ　１２３ ｶﾀｶﾅﾞ！"))
+	assert.Equal(t, "abc", FoldWidth.Fold("abc"))
+
+	both := Folders(FoldCase, FoldWidth)
+	assert.Equal(t, both.Fold("abc"), both.Fold("ＡＢＣ"))
+	assert.Equal(t, "x", Folders().Fold("x"))
+}
+
+func Test_foldEnd(t *testing.T) {
+	text := "ae\u0301\u0302b\xff"
+	assert.Equal(t, 1, foldEnd(text, 0))
+	assert.Equal(t, 6, foldEnd(text, 1))
+	assert.Equal(t, 7, foldEnd(text, 6))
+	assert.Equal(t, 8, foldEnd(text, 7))
+
+	// Hangul jamo make up syllables, with their marks.
+	text = "\u1112\u1161\u11ab\u1112\uac00\u11a8\u0301\u11a8\u1100\u0301\u1161"
+	assert.Equal(t, 9, foldEnd(text, 0))
+	assert.Equal(t, 20, foldEnd(text, 9))
+	assert.Equal(t, 23, foldEnd(text, 20))
+	assert.Equal(t, 28, foldEnd(text, 23))
+	assert.Equal(t, 31, foldEnd(text, 28))
+}
+
+func Test_foldNormalization(t *testing.T) {
+	dmp := createDMP()
+	dmp.Folder = FolderFunc(norm.NFC.String)
+	// Each character folds the same decomposed as composed.
+	for r := rune(0); r <= utf8.MaxRune; r++ {
+		s := string(r)
+		if decomposed := norm.NFD.String(s); decomposed != s {
+			assert.Equal(t, norm.NFC.String(s), dmp.foldText(decomposed), r)
+		}
+	}
+
+	text1 := "한국어 텍스트와 café"
+	text2 := norm.NFD.String("한국어 텍스트는 café")
+	diffs := dmp.DiffMain(text1, text2, false)
+	assert.Equal(t, []Diff{
+		Diff{DiffEqual, "한국어 텍스트"},
+		Diff{DiffDelete, "와"},
+		Diff{DiffInsert, norm.NFD.String("는")},
+		Diff{DiffEqual, " café"}}, diffs)
+	assert.Equal(t, []Diff{Diff{DiffEqual, text1}}, dmp.DiffMain(text1, norm.NFD.String(text1), false))
+}
+
+func Test_diffFolded(t *testing.T) {
+	dmp := createDMP()
+	dmp.Folder = FoldCase
+	// Texts keep their case, equalities that of text1.
+	assert.Equal(t, []Diff{
+		Diff{DiffEqual, "Hello World"},
+		Diff{DiffInsert, "!"}}, dmp.DiffMain("Hello World", "hello world!", false))
+	assert.Equal(t, []Diff{
+		Diff{DiffEqual, "Hel"},
+		Diff{DiffDelete, "lo"},
+		Diff{DiffInsert, "P"}}, dmp.DiffMain("Hello", "HELP", false))
+
+	dmp.Folder = FoldWidth
+	assert.Equal(t, []Diff{
+		Diff{DiffEqual, "ＡＢＣ"},
+		Diff{DiffInsert, "D"}}, dmp.DiffMain("ＡＢＣ", "ABCD", false))
+
+	// Composed and decomposed characters, with the marks kept with their
+	// letters.
+	dmp.Folder = composeFolder
+	assert.Equal(t, []Diff{
+		Diff{DiffEqual, "caf\u00e9"},
+		Diff{DiffInsert, " noir"}}, dmp.DiffMain("caf\u00e9", "cafe\u0301 noir", false))
+	assert.Equal(t, []Diff{
+		Diff{DiffEqual, "caf"},
+		Diff{DiffDelete, "\u00e9"},
+		Diff{DiffInsert, "e\u0302"}}, dmp.DiffMain("caf\u00e9", "cafe\u0302", false))
+
+	// Lines fold in line mode as in streams.
+	dmp.Folder = FoldCase
+	var lines1, lines2 []string
+	for i := 0; i < 200; i++ {
+		lines1 = append(lines1, "Line "+strconv.Itoa(i)+"\n")
+		lines2 = append(lines2, "LINE "+strconv.Itoa(i)+"\n")
+	}
+	text1, text2 := strings.Join(lines1, ""), strings.Join(lines2, "")
+	assert.Equal(t, []Diff{Diff{DiffEqual, text1}}, dmp.DiffMain(text1, text2, true))
+	var streamed []Diff
+	err := dmp.DiffReaders(context.Background(), strings.NewReader(text1), strings.NewReader(text2+"x\n"), DiffWriterFunc(func(d Diff) error {
+		streamed = append(streamed, d)
+		return nil
+	}))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, text1, dmp.DiffText1(streamed))
+	assert.Equal(t, Diff{DiffInsert, "x\n"}, streamed[len(streamed)-1])
+	for _, d := range streamed[:len(streamed)-1] {
+		assert.Equal(t, int8(DiffEqual), d.Type)
+	}
+
+	// Line mode takes the same course as without a Folder.
+	text1 = strings.Repeat("alpha\nbeta\n", 15)
+	text2 = strings.Repeat("alpha\ngamma\nx\nbeta\n\n", 6)
+	assert.NotEqual(t, dmp.unfoldedDMP().DiffMain(text1, text2, false), dmp.unfoldedDMP().DiffMain(text1, text2, true))
+	assert.Equal(t, dmp.unfoldedDMP().DiffMain(text1, text2, true), dmp.DiffMain(text1, text2, true))
+}
+
+func Test_diffFoldedTokens(t *testing.T) {
+	dmp := createDMP()
+	dmp.Folder = FoldCase
+	var lines1, lines2 []string
+	for i := 0; i < 200; i++ {
+		lines1 = append(lines1, "Line "+strconv.Itoa(i)+"\n")
+		lines2 = append(lines2, "LINE "+strconv.Itoa(i)+"\n")
+	}
+	text1, text2 := strings.Join(lines1, ""), strings.Join(lines2, "")
+
+	// Lines that fold the same share a character, and with enough lines
+	// the characters skip those the Folder would change, such as 'a'.
+	chars1, chars2, lineArray := dmp.DiffLinesToChars(text1, text2)
+	assert.Equal(t, chars1, chars2)
+	assert.Equal(t, chars1, FoldCase.Fold(chars1))
+	assert.Equal(t, "", lineArray['a'])
+	assert.Equal(t, text1, dmp.DiffCharsToLines([]Diff{Diff{DiffEqual, chars2}}, lineArray)[0].Text)
+
+	unified := dmp.unfoldedDMP().UnifiedDiff(text1, text1+"x\n", UnifiedOptions{})
+	assert.Equal(t, unified, dmp.UnifiedDiff(text1, text2+"x\n", UnifiedOptions{}))
+
+	assert.Equal(t, []Diff{
+		Diff{DiffEqual, "The Cat sat"},
+		Diff{DiffInsert, " on"}}, dmp.DiffWords("The Cat sat", "the cat SAT on", nil))
+}
+
+func Test_matchFolded(t *testing.T) {
+	dmp := createDMP()
+	dmp.Folder = FoldCase
+	assert.Equal(t, 4, dmp.MatchMain("THE QUICK BROWN FOX", "quick", 0))
+	assert.Equal(t, 4, dmp.MatchMain("THE QUICK BROWN FOX", "quack", 0))
+	assert.Equal(t, []Match{
+		Match{0, 3, 0, 0},
+		Match{12, 15, 0, 0}}, dmp.MatchAll("The cat and the dog", "THE", MatchOptions{}))
+	assert.Equal(t, map[rune]int{'A': 2, 'B': 1}, dmp.MatchAlphabet("ab"))
+
+	// Locations count the original text.
+	dmp.Folder = composeFolder
+	assert.Equal(t, 10, dmp.MatchMain("un cafe\u0301 noir", "noir", 0))
+	assert.Equal(t, 3, dmp.MatchMain("un cafe\u0301 noir", "caf\u00e9", 0))
+	assert.Equal(t, 3, dmp.MatchMain("un caf\u00e9 noir", "cafe\u0301", 0))
+	assert.Equal(t, []Match{
+		Match{3, 8, 0, 0}}, dmp.MatchAll("un cafe\u0301 noir", "caf\u00e9", MatchOptions{Unit: UnitRune}))
+	assert.Equal(t, []Match{
+		Match{3, 9, 0, 0}}, dmp.MatchAll("un cafe\u0301 noir", "caf\u00e9", MatchOptions{}))
+}
+
+func Test_patchFolded(t *testing.T) {
+	dmp := createDMP()
+	dmp.Folder = FoldCase
+	patches := dmp.PatchMake("The quick brown fox jumps", "The quick red fox jumps")
+	patched, results := dmp.PatchApply(patches, "THE QUICK BROWN FOX JUMPS")
+	assert.Equal(t, []bool{true}, results)
+	assert.Equal(t, "THE QUICK red FOX JUMPS", patched)
+
+	dmp.Folder = composeFolder
+	patches = dmp.PatchMake("Ein sch\u00f6ner Tag", "Ein sehr sch\u00f6ner Tag")
+	patched, results = dmp.PatchApply(patches, "Ein scho\u0308ner Tag")
+	assert.Equal(t, []bool{true}, results)
+	assert.Equal(t, "Ein sehr scho\u0308ner Tag", patched)
+}
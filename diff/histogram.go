@@ -0,0 +1,70 @@
+package diffmatchpatch
+
+// histogramMaxChain is the number of times an item may occur in the first
+// slice and still be considered as the start of a common run; JGit uses the
+// same limit.
+const histogramMaxChain = 64
+
+// histogram finds the differences between a and b, which have no common
+// prefix or suffix, by histogram diff as in JGit and git diff --histogram:
+// of the runs of items common to both it takes the one whose items occur
+// least often in a, the longest if there are several, as equal and diffs
+// the parts before and after it in turn.  If every common item is too
+// frequent it falls back on bisect.
+func (d *differ[T]) histogram(a, b []T) []Edit[T] {
+	i, j, n := histogramRun(a, b)
+	if n == 0 {
+		return d.bisect(a, b)
+	}
+	edits := d.main(a[:i], b[:j], false)
+	edits = append(edits, Edit[T]{DiffEqual, a[i : i+n]})
+	return append(edits, d.main(a[i+n:], b[j+n:], false)...)
+}
+
+// histogramRun returns the start in a, the start in b and the length of the
+// common run histogram picks, or a zero length if there is none.
+func histogramRun[T comparable](a, b []T) (int, int, int) {
+	positions := make(map[T][]int, len(a))
+	for i, x := range a {
+		positions[x] = append(positions[x], i)
+	}
+
+	bestI, bestJ, bestN := 0, 0, 0
+	bestCount := histogramMaxChain
+	for j := 0; j < len(b); {
+		next := j + 1
+		candidates := positions[b[j]]
+		if len(candidates) == 0 || len(candidates) > bestCount {
+			j = next
+			continue
+		}
+		for _, i := range candidates {
+			// Extend the match in both directions.
+			start1, start2 := i, j
+			for start1 > 0 && start2 > 0 && a[start1-1] == b[start2-1] {
+				start1--
+				start2--
+			}
+			end1, end2 := i+1, j+1
+			for end1 < len(a) && end2 < len(b) && a[end1] == b[end2] {
+				end1++
+				end2++
+			}
+			// The rarest item of the run decides.
+			count := len(candidates)
+			for _, x := range a[start1:end1] {
+				if c := len(positions[x]); c < count {
+					count = c
+				}
+			}
+			if count < bestCount || (count == bestCount && end1-start1 > bestN) {
+				bestI, bestJ, bestN, bestCount = start1, start2, end1-start1, count
+			}
+			if end2 > next {
+				next = end2
+			}
+		}
+		j = next
+	}
+	return bestI, bestJ, bestN
+}
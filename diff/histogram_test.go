@@ -0,0 +1,87 @@
+package diffmatchpatch
+
+import (
+	"github.com/bmizerany/assert"
+	"strings"
+	"testing"
+)
+
+func Test_histogramRun(t *testing.T) {
+	i, j, n := histogramRun([]rune("xaybz"), []rune("abq"))
+	assert.Equal(t, []int{1, 0, 1}, []int{i, j, n})
+
+	// A rarer run wins over a longer one.
+	i, j, n = histogramRun([]rune("abcabcQ"), []rune("Qabc"))
+	assert.Equal(t, []int{6, 0, 1}, []int{i, j, n})
+	// Runs extend past more frequent items.
+	i, j, n = histogramRun([]rune("aa{}b{}"), []rune("{}ab{}"))
+	assert.Equal(t, []int{4, 3, 3}, []int{i, j, n})
+
+	_, _, n = histogramRun([]rune("abc"), []rune("xyz"))
+	assert.Equal(t, 0, n)
+
+	// Items occurring more than histogramMaxChain times are skipped.
+	frequent := []rune(strings.Repeat("a", histogramMaxChain))
+	_, _, n = histogramRun(frequent, []rune("a"))
+	assert.Equal(t, 1, n)
+	_, _, n = histogramRun(append(frequent, 'a'), []rune("a"))
+	assert.Equal(t, 0, n)
+}
+
+func Test_diffHistogram(t *testing.T) {
+	dmp := createDMP()
+	dmp.DiffAlgorithm = AlgorithmHistogram
+	text1 := "int a()\n{\n    x();\n}\n\nint b()\n{\n    y();\n}\n"
+	text2 := "int b()\n{\n    y();\n}\n\nint a()\n{\n    z();\n}\n"
+	chars1, chars2, lineArray := dmp.DiffLinesToChars(text1, text2)
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(chars1, chars2, false), lineArray)
+	assert.Equal(t, []Diff{
+		Diff{DiffDelete, "int a()\n{\n    x();\n}\n\n"},
+		Diff{DiffEqual, "int b()\n{\n    y();\n}\n"},
+		Diff{DiffInsert, "\nint a()\n{\n    z();\n}\n"}}, diffs)
+}
+
+// Test_diffAlgorithms runs the texts of Test_diffMain through every
+// algorithm.  Patience and histogram diffs must restore the texts, agree
+// with Myers where the answer is obvious and never be shorter than the
+// minimal diff Myers finds.
+func Test_diffAlgorithms(t *testing.T) {
+	corpus := []struct {
+		text1, text2 string
+		obvious      bool
+	}{
+		{"", "", true},
+		{"abc", "abc", true},
+		{"abc", "ab123c", true},
+		{"a123bc", "abc", true},
+		{"abc", "a123b456c", true},
+		{"a123b456c", "abc", true},
+		{"a", "b", true},
+		{"Apples are a fruit.", "Bananas are also fruit.", false},
+		{"ax\t", "ڀx\u0000", true},
+		{"1ayb2", "abxab", false},
+		{"abcy", "xaxcxabc", false},
+		{"ABCDa=bcd=efghijklmnopqrsEFGHIJKLMNOefg", "a-bcd-efghijklmnopqrs", false},
+		{"a [[Pennsylvania]] and [[New", " and [[Pennsylvania]]", false},
+		{"`Twas brillig, and the slithy toves\nDid gyre and gimble in the wabe:\n",
+			"I am the very model of a modern major general,\nI've information vegetable, animal, and mineral,\n", false},
+	}
+	myers := createDMP()
+	myers.DiffTimeout = 0
+	for _, algorithm := range []Algorithm{AlgorithmPatience, AlgorithmHistogram} {
+		dmp := createDMP()
+		dmp.DiffTimeout = 0
+		dmp.DiffAlgorithm = algorithm
+		for _, test := range corpus {
+			for _, checklines := range []bool{false, true} {
+				diffs := dmp.DiffMain(test.text1, test.text2, checklines)
+				expected := myers.DiffMain(test.text1, test.text2, checklines)
+				assert.Equal(t, []string{test.text1, test.text2}, diffRebuildtexts(diffs))
+				if test.obvious {
+					assert.Equal(t, expected, diffs)
+				}
+				assert.T(t, dmp.DiffLevenshtein(diffs) >= dmp.DiffLevenshtein(expected), test.text1)
+			}
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package diffmatchpatch
+
+import (
+	"html"
+	"io"
+	"strings"
+)
+
+// HTMLRenderer writes diffs as HTML.  The zero value writes bare, escaped
+// text; NewHTMLRenderer returns one that produces the same markup as
+// DiffPrettyHtml.
+type HTMLRenderer struct {
+	// Elements wrapped around insertions, deletions and equalities.  An
+	// empty tag writes the text without an element.
+	InsertTag, DeleteTag, EqualTag string
+	// CSS classes given to those elements (empty for none).
+	InsertClass, DeleteClass, EqualClass string
+	// Inline styles given to those elements (empty for none).
+	InsertStyle, DeleteStyle, EqualStyle string
+	// Newline is written in place of each line break of the inline layout
+	// (empty to keep the line breaks).
+	Newline string
+	// SideBySide lays the diff out as a two column table, the source text
+	// on the left and the destination text on the right, one line per row.
+	SideBySide bool
+	// TableClass is the CSS class of the side-by-side table.
+	TableClass string
+}
+
+// NewHTMLRenderer returns a renderer with the tags and styles of
+// DiffPrettyHtml.
+func NewHTMLRenderer() *HTMLRenderer {
+	return &HTMLRenderer{
+		InsertTag:   "ins",
+		DeleteTag:   "del",
+		EqualTag:    "span",
+		InsertStyle: "background:#e6ffe6;",
+		DeleteStyle: "background:#ffe6e6;",
+		Newline:     "&para;<br>",
+	}
+}
+
+var htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// Render writes diffs to w.  It stops at the first write error and returns
+// it.
+func (r *HTMLRenderer) Render(w io.Writer, diffs []Diff) error {
+	ew := &errWriter{w: w}
+	if r.SideBySide {
+		// Lines are paired up at each equality; where one side has more
+		// changed lines than the other the shorter side is padded with
+		// empty cells.
+		r.writeTable(ew, pairLines(diffs))
+	} else {
+		for _, aDiff := range diffs {
+			text := htmlEscaper.Replace(aDiff.Text)
+			if len(r.Newline) != 0 {
+				text = strings.Replace(text, "\n", r.Newline, -1)
+			}
+			r.writeElement(ew, aDiff.Type, text)
+		}
+	}
+	return ew.err
+}
+
+// writeTable writes pairs as a two column table.
+func (r *HTMLRenderer) writeTable(w *errWriter, pairs []LinePair) {
+	w.WriteString("<table")
+	writeAttr(w, "class", r.TableClass)
+	w.WriteString(">\n")
+	for _, p := range pairs {
+		if p.Folded != 0 {
+			w.WriteString("<tr><td colspan=\"2\">" + foldMessage(p.Folded) + "</td></tr>\n")
+			continue
+		}
+		w.WriteString("<tr><td>")
+		for _, aDiff := range p.Left {
+			r.writeElement(w, aDiff.Type, htmlEscaper.Replace(aDiff.Text))
+		}
+		w.WriteString("</td><td>")
+		for _, aDiff := range p.Right {
+			r.writeElement(w, aDiff.Type, htmlEscaper.Replace(aDiff.Text))
+		}
+		w.WriteString("</td></tr>\n")
+	}
+	w.WriteString("</table>\n")
+}
+
+func (r *HTMLRenderer) writeElement(w *errWriter, op int8, text string) {
+	var tag, class, style string
+	switch op {
+	case DiffInsert:
+		tag, class, style = r.InsertTag, r.InsertClass, r.InsertStyle
+	case DiffDelete:
+		tag, class, style = r.DeleteTag, r.DeleteClass, r.DeleteStyle
+	default:
+		tag, class, style = r.EqualTag, r.EqualClass, r.EqualStyle
+	}
+	if len(tag) == 0 {
+		w.WriteString(text)
+		return
+	}
+	w.WriteString("<" + tag)
+	writeAttr(w, "class", class)
+	writeAttr(w, "style", style)
+	w.WriteString(">" + text + "</" + tag + ">")
+}
+
+func writeAttr(w *errWriter, name, value string) {
+	if len(value) != 0 {
+		w.WriteString(" " + name + "=\"" + html.EscapeString(value) + "\"")
+	}
+}
+
+// errWriter remembers the first error returned by w and ignores all
+// writes after it.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) WriteString(s string) {
+	if ew.err == nil {
+		_, ew.err = io.WriteString(ew.w, s)
+	}
+}
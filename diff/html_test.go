@@ -0,0 +1,77 @@
+package diffmatchpatch
+
+import (
+	"bytes"
+	"errors"
+	"github.com/bmizerany/assert"
+	"testing"
+)
+
+func Test_htmlRenderer(t *testing.T) {
+	diffs := []Diff{
+		Diff{DiffEqual, "a\n"},
+		Diff{DiffDelete, "<B>b</B>"},
+		Diff{DiffInsert, "c&d"}}
+
+	var buff bytes.Buffer
+	r := &HTMLRenderer{
+		InsertTag:   "ins",
+		DeleteTag:   "del",
+		InsertClass: "added",
+		DeleteClass: "removed",
+		EqualStyle:  "color:\"gray\"",
+		Newline:     "<br>",
+	}
+	assert.Equal(t, nil, r.Render(&buff, diffs))
+	assert.Equal(t, "a<br><del class=\"removed\">&lt;B&gt;b&lt;/B&gt;</del><ins class=\"added\">c&amp;d</ins>",
+		buff.String())
+
+	// The zero value writes escaped text only.
+	buff.Reset()
+	assert.Equal(t, nil, (&HTMLRenderer{}).Render(&buff, diffs))
+	assert.Equal(t, "a\n&lt;B&gt;b&lt;/B&gt;c&amp;d", buff.String())
+}
+
+func Test_htmlRendererSideBySide(t *testing.T) {
+	diffs := []Diff{
+		Diff{DiffEqual, "one\ntw"},
+		Diff{DiffDelete, "o\nthree"},
+		Diff{DiffInsert, "in"},
+		Diff{DiffEqual, "\nfour\n"},
+		Diff{DiffInsert, "five\n"}}
+
+	var buff bytes.Buffer
+	r := NewHTMLRenderer()
+	r.SideBySide = true
+	r.TableClass = "diff"
+	assert.Equal(t, nil, r.Render(&buff, diffs))
+	assert.Equal(t, "<table class=\"diff\">\n"+
+		"<tr><td><span>one</span></td><td><span>one</span></td></tr>\n"+
+		"<tr><td><span>tw</span><del style=\"background:#ffe6e6;\">o</del></td>"+
+		"<td><span>tw</span><ins style=\"background:#e6ffe6;\">in</ins></td></tr>\n"+
+		"<tr><td><del style=\"background:#ffe6e6;\">three</del></td><td></td></tr>\n"+
+		"<tr><td><span>four</span></td><td><span>four</span></td></tr>\n"+
+		"<tr><td></td><td><ins style=\"background:#e6ffe6;\">five</ins></td></tr>\n"+
+		"</table>\n", buff.String())
+}
+
+type failingWriter struct {
+	n int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.n == 0 {
+		return 0, errors.New("disk full")
+	}
+	w.n--
+	return len(p), nil
+}
+
+func Test_htmlRendererWriteError(t *testing.T) {
+	w := &failingWriter{n: 2}
+	err := NewHTMLRenderer().Render(w, []Diff{
+		Diff{DiffEqual, "a"},
+		Diff{DiffInsert, "b"},
+		Diff{DiffDelete, "c"}})
+	assert.Equal(t, errors.New("disk full"), err)
+}
@@ -0,0 +1,573 @@
+package diffmatchpatch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The renderers and parsers in this file deal with the traditional output
+// formats of diff.  The renderers expect line-mode diffs, whose texts
+// consist of whole lines, as made by DiffLinesToChars, DiffMain and
+// DiffCharsToLines.  The parsers need the text the diff was made from,
+// since the formats give positions as line numbers and, in the case of ed
+// scripts, leave out the deleted lines.  They check the diff against that
+// text and return patches made from it with PatchMake, ready for
+// PatchApply.
+
+// contextTimeLayout is the timestamp format of diff -c.
+const contextTimeLayout = "Mon Jan _2 15:04:05 2006"
+
+// lineChange is a run of changed lines: the lines of text1 it deletes and
+// the lines of text2 it inserts.  start1 and start2 are the number of lines
+// of either text that precede it.
+type lineChange struct {
+	start1, start2 int
+	deleted        []string
+	inserted       []string
+}
+
+// lineChanges collects the changes between runs of equal lines.
+func lineChanges(lines []unifiedLine) []lineChange {
+	var changes []lineChange
+	line1, line2 := 0, 0
+	for i := 0; i < len(lines); {
+		if lines[i].op == DiffEqual {
+			line1++
+			line2++
+			i++
+			continue
+		}
+		change := lineChange{start1: line1, start2: line2}
+		for ; i < len(lines) && lines[i].op != DiffEqual; i++ {
+			if lines[i].op == DiffDelete {
+				change.deleted = append(change.deleted, lines[i].text)
+			} else {
+				change.inserted = append(change.inserted, lines[i].text)
+			}
+		}
+		line1 += len(change.deleted)
+		line2 += len(change.inserted)
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// DiffToContextDiff renders line-mode diffs in the context format of
+// diff -c.  It returns the empty string if there are no changes.
+func (dmp *DiffMatchPatch) DiffToContextDiff(diffs []Diff, opts UnifiedOptions) string {
+	hunks := groupHunks(splitDiffLines(diffs), opts.context())
+	if len(hunks) == 0 {
+		return ""
+	}
+	var out bytes.Buffer
+	out.WriteString(fileHeader("***", opts.FromFile, "a", opts.FromTime, contextTimeLayout))
+	out.WriteString(fileHeader("---", opts.ToFile, "b", opts.ToTime, contextTimeLayout))
+	for _, h := range hunks {
+		// A line that belongs to a change with both deletions and
+		// insertions is marked "!" on either side.
+		marks := make([]string, len(h.lines))
+		var deletes, inserts bool
+		for i := 0; i <= len(h.lines); i++ {
+			if i < len(h.lines) && h.lines[i].op != DiffEqual {
+				deletes = deletes || h.lines[i].op == DiffDelete
+				inserts = inserts || h.lines[i].op == DiffInsert
+				continue
+			}
+			for j := i - 1; j >= 0 && h.lines[j].op != DiffEqual; j-- {
+				switch {
+				case deletes && inserts:
+					marks[j] = "! "
+				case deletes:
+					marks[j] = "- "
+				default:
+					marks[j] = "+ "
+				}
+			}
+			deletes, inserts = false, false
+		}
+
+		out.WriteString("***************\n")
+		length1, length2 := 0, 0
+		changed1, changed2 := false, false
+		for _, l := range h.lines {
+			if l.op != DiffInsert {
+				length1++
+				changed1 = changed1 || l.op == DiffDelete
+			}
+			if l.op != DiffDelete {
+				length2++
+				changed2 = changed2 || l.op == DiffInsert
+			}
+		}
+		out.WriteString("*** " + contextRange(h.start1, length1) + " ****\n")
+		if changed1 {
+			for i, l := range h.lines {
+				if l.op == DiffEqual {
+					writeLine(&out, "  ", l.text)
+				} else if l.op == DiffDelete {
+					writeLine(&out, marks[i], l.text)
+				}
+			}
+		}
+		out.WriteString("--- " + contextRange(h.start2, length2) + " ----\n")
+		if changed2 {
+			for i, l := range h.lines {
+				if l.op == DiffEqual {
+					writeLine(&out, "  ", l.text)
+				} else if l.op == DiffInsert {
+					writeLine(&out, marks[i], l.text)
+				}
+			}
+		}
+	}
+	return out.String()
+}
+
+// contextRange formats a hunk range of a context diff: an empty range is
+// given by the line before it, a single line by its number alone.
+func contextRange(start, length int) string {
+	switch length {
+	case 0:
+		return strconv.Itoa(start)
+	case 1:
+		return strconv.Itoa(start + 1)
+	}
+	return strconv.Itoa(start+1) + "," + strconv.Itoa(start+length)
+}
+
+// normalRange formats the lines from and to, counted from 1.
+func normalRange(from, to int) string {
+	if from == to {
+		return strconv.Itoa(from)
+	}
+	return strconv.Itoa(from) + "," + strconv.Itoa(to)
+}
+
+// DiffToNormalDiff renders line-mode diffs in the default format of diff,
+// with commands such as "3c3" and "5a6,7".
+func (dmp *DiffMatchPatch) DiffToNormalDiff(diffs []Diff) string {
+	var out bytes.Buffer
+	for _, c := range lineChanges(splitDiffLines(diffs)) {
+		del, ins := len(c.deleted), len(c.inserted)
+		switch {
+		case del != 0 && ins != 0:
+			out.WriteString(normalRange(c.start1+1, c.start1+del) + "c" + normalRange(c.start2+1, c.start2+ins) + "\n")
+		case del != 0:
+			out.WriteString(normalRange(c.start1+1, c.start1+del) + "d" + strconv.Itoa(c.start2) + "\n")
+		default:
+			out.WriteString(strconv.Itoa(c.start1) + "a" + normalRange(c.start2+1, c.start2+ins) + "\n")
+		}
+		for _, line := range c.deleted {
+			writeLine(&out, "< ", line)
+		}
+		if del != 0 && ins != 0 {
+			out.WriteString("---\n")
+		}
+		for _, line := range c.inserted {
+			writeLine(&out, "> ", line)
+		}
+	}
+	return out.String()
+}
+
+// DiffToEdScript renders line-mode diffs as a script for the ed editor, as
+// written by diff -e.  The commands come last change first so that the
+// line numbers stay valid as the script runs.  Ed cannot express a missing
+// line break at the end of the text; such a last line is inserted with one.
+func (dmp *DiffMatchPatch) DiffToEdScript(diffs []Diff) string {
+	var out bytes.Buffer
+	changes := lineChanges(splitDiffLines(diffs))
+	for x := len(changes) - 1; x >= 0; x-- {
+		c := changes[x]
+		del, ins := len(c.deleted), len(c.inserted)
+		switch {
+		case del != 0 && ins != 0:
+			out.WriteString(normalRange(c.start1+1, c.start1+del) + "c\n")
+		case del != 0:
+			out.WriteString(normalRange(c.start1+1, c.start1+del) + "d\n")
+			continue
+		default:
+			out.WriteString(strconv.Itoa(c.start1) + "a\n")
+		}
+		// A line holding a single dot would end the insertion.  It is
+		// written doubled, the insertion ended and the extra dot removed
+		// before the insertion continues.
+		inserting := true
+		for _, line := range c.inserted {
+			if !inserting {
+				out.WriteString("a\n")
+				inserting = true
+			}
+			line = strings.TrimSuffix(line, "\n")
+			if line == "." {
+				out.WriteString("..\n.\ns/.//\n")
+				inserting = false
+			} else {
+				out.WriteString(line + "\n")
+			}
+		}
+		if inserting {
+			out.WriteString(".\n")
+		}
+	}
+	return out.String()
+}
+
+// patchesFromHunks checks hunks against text1 and makes patches from them.
+// The hunks have to be in order and must not overlap.
+func (dmp *DiffMatchPatch) patchesFromHunks(text1 string, hunks []lineHunk) ([]Patch, error) {
+	lines1 := splitLines(text1)
+	var diffs []Diff
+	add := func(op int8, text string) {
+		if len(text) == 0 {
+			return
+		}
+		if n := len(diffs); n > 0 && diffs[n-1].Type == op {
+			diffs[n-1].Text += text
+		} else {
+			diffs = append(diffs, Diff{op, text})
+		}
+	}
+	next := 0 // The first line of text1 not yet covered.
+	for _, h := range hunks {
+		if h.start1 < next {
+			return nil, fmt.Errorf("Change at line %d overlaps the change before it", h.start1+1)
+		}
+		if h.start1 > len(lines1) {
+			return nil, fmt.Errorf("Change at line %d is beyond the end of the text", h.start1+1)
+		}
+		add(DiffEqual, strings.Join(lines1[next:h.start1], ""))
+		next = h.start1
+		for _, l := range h.lines {
+			if l.op != DiffInsert {
+				if next == len(lines1) || lines1[next] != l.text {
+					return nil, fmt.Errorf("Line %d does not match the diff", next+1)
+				}
+				next++
+			}
+			add(l.op, l.text)
+		}
+	}
+	add(DiffEqual, strings.Join(lines1[next:], ""))
+	return dmp.PatchMake(text1, diffs), nil
+}
+
+var (
+	contextOldRange = regexp.MustCompile(`^\*\*\* (\d+)(?:,(\d+))? \*\*\*\*$`)
+	contextNewRange = regexp.MustCompile(`^--- (\d+)(?:,(\d+))? ----$`)
+	normalCommand   = regexp.MustCompile(`^(\d+)(?:,(\d+))?([acd])(\d+)(?:,(\d+))?$`)
+	edCommand       = regexp.MustCompile(`^(\d+)(?:,(\d+))?([acd])$`)
+)
+
+// diffLineReader walks the lines of a diff.
+type diffLineReader struct {
+	lines []string
+	i     int
+}
+
+func newDiffLineReader(text string) *diffLineReader {
+	return &diffLineReader{lines: splitLines(text)}
+}
+
+func (r *diffLineReader) more() bool {
+	return r.i < len(r.lines)
+}
+
+// peek returns the current line without its line break.
+func (r *diffLineReader) peek() string {
+	if !r.more() {
+		return ""
+	}
+	return strings.TrimRight(r.lines[r.i], "\r\n")
+}
+
+// text returns the current line with prefix removed and consumes it,
+// together with a following "\ No newline" marker.
+func (r *diffLineReader) text(prefix string) string {
+	line := r.lines[r.i][len(prefix):]
+	r.i++
+	if r.more() && strings.HasPrefix(r.lines[r.i], "\\") {
+		r.i++
+		return strings.TrimRight(line, "\r\n")
+	}
+	if !strings.HasSuffix(line, "\n") {
+		line += "\n"
+	}
+	return line
+}
+
+func (r *diffLineReader) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("Line %d: "+format, append([]interface{}{r.i + 1}, args...)...)
+}
+
+// lineRange parses the numbers of a range; a missing end equals the start.
+func lineRange(from, to string) (int, int) {
+	start, _ := strconv.Atoi(from)
+	end := start
+	if len(to) != 0 {
+		end, _ = strconv.Atoi(to)
+	}
+	return start, end
+}
+
+// PatchFromContextDiff parses a context diff of text1, as written by
+// diff -c, into patches.
+func (dmp *DiffMatchPatch) PatchFromContextDiff(text1, diff string) ([]Patch, error) {
+	r := newDiffLineReader(diff)
+	var hunks []lineHunk
+	for r.more() {
+		if r.peek() != "***************" {
+			r.i++ // File names and anything else between the hunks.
+			continue
+		}
+		r.i++
+		m := contextOldRange.FindStringSubmatch(r.peek())
+		if m == nil {
+			return nil, r.errorf("invalid hunk range: %s", r.peek())
+		}
+		from1, to1 := lineRange(m[1], m[2])
+		pair1 := len(m[2]) != 0
+		r.i++
+		var side1, side2 []unifiedLine
+		for r.more() && !contextNewRange.MatchString(r.peek()) {
+			line, err := contextLine(r, DiffDelete)
+			if err != nil {
+				return nil, err
+			}
+			side1 = append(side1, line)
+		}
+		m = contextNewRange.FindStringSubmatch(r.peek())
+		if m == nil {
+			return nil, r.errorf("missing the second half of the hunk")
+		}
+		from2, to2 := lineRange(m[1], m[2])
+		r.i++
+		for r.more() && r.peek() != "***************" && len(r.peek()) > 1 &&
+			strings.ContainsRune(" +!", rune(r.peek()[0])) && r.peek()[1] == ' ' {
+			line, err := contextLine(r, DiffInsert)
+			if err != nil {
+				return nil, err
+			}
+			side2 = append(side2, line)
+		}
+
+		// A side without changes is left out; it consists of the context
+		// lines of the other side.
+		if len(side1) == 0 {
+			side1 = contextLines(side2)
+		}
+		if len(side2) == 0 {
+			side2 = contextLines(side1)
+		}
+		lines, err := mergeContextSides(side1, side2)
+		if err != nil {
+			return nil, r.errorf("%v", err)
+		}
+		h := lineHunk{lines: lines}
+		length1, length2 := 0, 0
+		for _, l := range lines {
+			if l.op != DiffInsert {
+				length1++
+			}
+			if l.op != DiffDelete {
+				length2++
+			}
+		}
+		var ok1, ok2 bool
+		h.start1, ok1 = contextStart(from1, to1, pair1, length1)
+		h.start2, ok2 = contextStart(from2, to2, len(m[2]) != 0, length2)
+		if !ok1 || !ok2 {
+			return nil, r.errorf("hunk doesn't match its ranges")
+		}
+		hunks = append(hunks, h)
+	}
+	return dmp.patchesFromHunks(text1, hunks)
+}
+
+// contextStart returns the number of lines before a range of a context
+// diff holding length lines.
+func contextStart(from, to int, pair bool, length int) (int, bool) {
+	if !pair {
+		// A single number gives either the one line or the line before
+		// an empty range.
+		switch length {
+		case 0:
+			return from, true
+		case 1:
+			return from - 1, from > 0
+		}
+		return 0, false
+	}
+	return from - 1, from > 0 && to-from+1 == length
+}
+
+// contextLine reads one line of a side of a context hunk; changes are
+// taken as op.
+func contextLine(r *diffLineReader, op int8) (unifiedLine, error) {
+	line := r.peek()
+	if len(line) < 2 || line[1] != ' ' {
+		return unifiedLine{}, r.errorf("unexpected line in hunk: %s", line)
+	}
+	switch line[0] {
+	case ' ':
+		return unifiedLine{DiffEqual, r.text("  ")}, nil
+	case '!':
+		return unifiedLine{op, r.text("! ")}, nil
+	case '-':
+		if op == DiffDelete {
+			return unifiedLine{op, r.text("- ")}, nil
+		}
+	case '+':
+		if op == DiffInsert {
+			return unifiedLine{op, r.text("+ ")}, nil
+		}
+	}
+	return unifiedLine{}, r.errorf("unexpected line in hunk: %s", line)
+}
+
+func contextLines(lines []unifiedLine) []unifiedLine {
+	var equal []unifiedLine
+	for _, l := range lines {
+		if l.op == DiffEqual {
+			equal = append(equal, l)
+		}
+	}
+	return equal
+}
+
+// mergeContextSides interleaves the two sides of a context hunk, pairing up
+// their context lines.
+func mergeContextSides(side1, side2 []unifiedLine) ([]unifiedLine, error) {
+	var lines []unifiedLine
+	i, j := 0, 0
+	for i < len(side1) || j < len(side2) {
+		for ; i < len(side1) && side1[i].op != DiffEqual; i++ {
+			lines = append(lines, side1[i])
+		}
+		for ; j < len(side2) && side2[j].op != DiffEqual; j++ {
+			lines = append(lines, side2[j])
+		}
+		if i == len(side1) && j == len(side2) {
+			break
+		}
+		if i == len(side1) || j == len(side2) || side1[i].text != side2[j].text {
+			return nil, errors.New("the context of the two halves of the hunk differs")
+		}
+		lines = append(lines, side1[i])
+		i++
+		j++
+	}
+	return lines, nil
+}
+
+// PatchFromNormalDiff parses the default output format of diff, made from
+// text1, into patches.
+func (dmp *DiffMatchPatch) PatchFromNormalDiff(text1, diff string) ([]Patch, error) {
+	r := newDiffLineReader(diff)
+	var hunks []lineHunk
+	for r.more() {
+		m := normalCommand.FindStringSubmatch(r.peek())
+		if m == nil {
+			return nil, r.errorf("invalid command: %s", r.peek())
+		}
+		r.i++
+		from1, to1 := lineRange(m[1], m[2])
+		from2, to2 := lineRange(m[4], m[5])
+		var h lineHunk
+		deleted, inserted := 0, 0
+		switch m[3] {
+		case "a":
+			h.start1, inserted = from1, to2-from2+1
+		case "d":
+			h.start1, deleted = from1-1, to1-from1+1
+		case "c":
+			h.start1, deleted, inserted = from1-1, to1-from1+1, to2-from2+1
+		}
+		if h.start1 < 0 || deleted < 0 || inserted < 0 || (m[3] == "a" && len(m[2]) != 0) ||
+			(m[3] == "d" && len(m[5]) != 0) {
+			return nil, r.errorf("invalid command: %s", r.lines[r.i-1])
+		}
+		for ; deleted > 0; deleted-- {
+			if !strings.HasPrefix(r.peek(), "< ") {
+				return nil, r.errorf("expected a deleted line")
+			}
+			h.lines = append(h.lines, unifiedLine{DiffDelete, r.text("< ")})
+		}
+		if m[3] == "c" {
+			if r.peek() != "---" {
+				return nil, r.errorf("expected ---")
+			}
+			r.i++
+		}
+		for ; inserted > 0; inserted-- {
+			if !strings.HasPrefix(r.peek(), "> ") {
+				return nil, r.errorf("expected an inserted line")
+			}
+			h.lines = append(h.lines, unifiedLine{DiffInsert, r.text("> ")})
+		}
+		hunks = append(hunks, h)
+	}
+	return dmp.patchesFromHunks(text1, hunks)
+}
+
+// PatchFromEdScript parses an ed script for text1, as written by diff -e,
+// into patches.  Only the commands diff uses are understood: a, c and d
+// with line numbers, in descending order.
+func (dmp *DiffMatchPatch) PatchFromEdScript(text1, script string) ([]Patch, error) {
+	lines1 := splitLines(text1)
+	r := newDiffLineReader(script)
+	var hunks []lineHunk
+	for r.more() {
+		m := edCommand.FindStringSubmatch(r.peek())
+		if m == nil {
+			return nil, r.errorf("invalid command: %s", r.peek())
+		}
+		r.i++
+		from, to := lineRange(m[1], m[2])
+		var h lineHunk
+		h.start1 = from
+		if m[3] != "a" {
+			h.start1 = from - 1
+			if from == 0 || to < from || to > len(lines1) {
+				return nil, r.errorf("invalid range: %s", m[1]+","+m[2])
+			}
+			for _, line := range lines1[from-1 : to] {
+				h.lines = append(h.lines, unifiedLine{DiffDelete, line})
+			}
+		} else if len(m[2]) != 0 {
+			return nil, r.errorf("invalid command: %s", r.lines[r.i-1])
+		}
+		for m[3] != "d" {
+			if !r.more() {
+				return nil, r.errorf("unterminated insertion")
+			}
+			if r.peek() != "." {
+				h.lines = append(h.lines, unifiedLine{DiffInsert, r.text("")})
+				continue
+			}
+			r.i++
+			if r.peek() != "s/.//" {
+				break
+			}
+			// The last line inserted was a doubled dot.
+			if n := len(h.lines); n == 0 || h.lines[n-1].text != "..\n" {
+				return nil, r.errorf("unexpected s/.//")
+			}
+			h.lines[len(h.lines)-1].text = ".\n"
+			r.i++
+			if r.peek() != "a" {
+				break
+			}
+			r.i++
+		}
+		hunks = append(hunks, h)
+	}
+	for i, j := 0, len(hunks)-1; i < j; i, j = i+1, j-1 {
+		hunks[i], hunks[j] = hunks[j], hunks[i]
+	}
+	return dmp.patchesFromHunks(text1, hunks)
+}
@@ -0,0 +1,205 @@
+package diffmatchpatch
+
+import (
+	"github.com/bmizerany/assert"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+const (
+	lineText1 = "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven\ntwelve\n"
+	lineText2 = "zero\none\n2\nthree\nfour\nfive\nsix\nseven\neight\nnine\neleven\ntwelve\nthirteen"
+)
+
+func lineDiffs(dmp *DiffMatchPatch, text1, text2 string) []Diff {
+	chars1, chars2, lineArray := dmp.DiffLinesToChars(text1, text2)
+	return dmp.DiffCharsToLines(dmp.DiffMain(chars1, chars2, false), lineArray)
+}
+
+func Test_contextDiff(t *testing.T) {
+	dmp := createDMP()
+	diffs := lineDiffs(&dmp, lineText1, lineText2)
+
+	// The output of GNU diff -C1.
+	context := "*** a\n" +
+		"--- b\n" +
+		"***************\n" +
+		"*** 1,3 ****\n" +
+		"  one\n" +
+		"! two\n" +
+		"  three\n" +
+		"--- 1,4 ----\n" +
+		"+ zero\n" +
+		"  one\n" +
+		"! 2\n" +
+		"  three\n" +
+		"***************\n" +
+		"*** 9,12 ****\n" +
+		"  nine\n" +
+		"- ten\n" +
+		"  eleven\n" +
+		"  twelve\n" +
+		"--- 10,13 ----\n" +
+		"  nine\n" +
+		"  eleven\n" +
+		"  twelve\n" +
+		"+ thirteen\n" +
+		"\\ No newline at end of file\n"
+	assert.Equal(t, context, dmp.DiffToContextDiff(diffs, UnifiedOptions{Context: 1}))
+	assert.Equal(t, "", dmp.DiffToContextDiff(lineDiffs(&dmp, lineText1, lineText1), UnifiedOptions{}))
+
+	patches, err := dmp.PatchFromContextDiff(lineText1, context)
+	assert.Equal(t, nil, err)
+	text, _ := dmp.PatchApply(patches, lineText1)
+	assert.Equal(t, lineText2, text)
+
+	// Creating and emptying a file.
+	created := "*** a\n--- b\n***************\n*** 0 ****\n--- 1,2 ----\n+ x\n+ y\n"
+	assert.Equal(t, created, dmp.DiffToContextDiff([]Diff{Diff{DiffInsert, "x\ny\n"}}, UnifiedOptions{}))
+	patches, err = dmp.PatchFromContextDiff("", created)
+	assert.Equal(t, nil, err)
+	text, _ = dmp.PatchApply(patches, "")
+	assert.Equal(t, "x\ny\n", text)
+	emptied := "*** a\n--- b\n***************\n*** 1 ****\n- x\n--- 0 ----\n"
+	assert.Equal(t, emptied, dmp.DiffToContextDiff([]Diff{Diff{DiffDelete, "x\n"}}, UnifiedOptions{}))
+	patches, err = dmp.PatchFromContextDiff("x\n", emptied)
+	assert.Equal(t, nil, err)
+	text, _ = dmp.PatchApply(patches, "x\n")
+	assert.Equal(t, "", text)
+
+	for _, diff := range []string{
+		strings.Replace(context, "*** 9,12", "*** 9,13", 1),
+		strings.Replace(context, "--- 10,13 ----\n  nine", "--- 10,13 ----\n  NINE", 1),
+		strings.Replace(context, "! two", "? two", 1),
+		strings.Replace(context, "--- 1,4 ----\n", "", 1),
+		strings.Replace(context, "*** 1,3 ****", "*** 1,3", 1),
+	} {
+		_, err := dmp.PatchFromContextDiff(lineText1, diff)
+		assert.NotEqual(t, nil, err, diff)
+	}
+	// The diff has to match the text.
+	_, err = dmp.PatchFromContextDiff(strings.Replace(lineText1, "nine", "9", 1), context)
+	assert.NotEqual(t, nil, err)
+}
+
+func Test_normalDiff(t *testing.T) {
+	dmp := createDMP()
+	diffs := lineDiffs(&dmp, lineText1, lineText2)
+
+	// The output of GNU diff.
+	normal := "0a1\n" +
+		"> zero\n" +
+		"2c3\n" +
+		"< two\n" +
+		"---\n" +
+		"> 2\n" +
+		"10d10\n" +
+		"< ten\n" +
+		"12a13\n" +
+		"> thirteen\n" +
+		"\\ No newline at end of file\n"
+	assert.Equal(t, normal, dmp.DiffToNormalDiff(diffs))
+	assert.Equal(t, "", dmp.DiffToNormalDiff(lineDiffs(&dmp, lineText1, lineText1)))
+	assert.Equal(t, "1,2c1\n< a\n< b\n---\n> c\n\\ No newline at end of file\n",
+		dmp.DiffToNormalDiff([]Diff{Diff{DiffDelete, "a\nb\n"}, Diff{DiffInsert, "c"}}))
+
+	patches, err := dmp.PatchFromNormalDiff(lineText1, normal)
+	assert.Equal(t, nil, err)
+	text, _ := dmp.PatchApply(patches, lineText1)
+	assert.Equal(t, lineText2, text)
+
+	for _, diff := range []string{
+		strings.Replace(normal, "2c3", "2x3", 1),
+		strings.Replace(normal, "---\n", "", 1),
+		strings.Replace(normal, "10d10", "10,11d10", 1),
+		strings.Replace(normal, "0a1", "0,1a1", 1),
+		strings.Replace(normal, "< two", "< 2", 1),
+		strings.Replace(normal, "12a13", "13a14", 1),
+		"2c3\n< two\n---\n> 2\n0a1\n> zero\n",
+	} {
+		_, err := dmp.PatchFromNormalDiff(lineText1, diff)
+		assert.NotEqual(t, nil, err, diff)
+	}
+}
+
+func Test_edScript(t *testing.T) {
+	dmp := createDMP()
+	diffs := lineDiffs(&dmp, lineText1, lineText2)
+
+	// The output of GNU diff -e.
+	script := "12a\n" +
+		"thirteen\n" +
+		".\n" +
+		"10d\n" +
+		"2c\n" +
+		"2\n" +
+		".\n" +
+		"0a\n" +
+		"zero\n" +
+		".\n"
+	assert.Equal(t, script, dmp.DiffToEdScript(diffs))
+	assert.Equal(t, "", dmp.DiffToEdScript(lineDiffs(&dmp, lineText1, lineText1)))
+
+	patches, err := dmp.PatchFromEdScript(lineText1, script)
+	assert.Equal(t, nil, err)
+	text, _ := dmp.PatchApply(patches, lineText1)
+	assert.Equal(t, lineText2+"\n", text)
+
+	// Lines holding a single dot.
+	text1 := "a\nb\nc\nd\n"
+	text2 := "a\n.\nx\n.\nc\nd\n"
+	script = "2c\n..\n.\ns/.//\na\nx\n..\n.\ns/.//\n"
+	assert.Equal(t, script, dmp.DiffToEdScript(lineDiffs(&dmp, text1, text2)))
+	patches, err = dmp.PatchFromEdScript(text1, script)
+	assert.Equal(t, nil, err)
+	text, _ = dmp.PatchApply(patches, text1)
+	assert.Equal(t, text2, text)
+
+	for _, script := range []string{
+		"2x\n",
+		"2c\nx\n",
+		"5,6d\n",
+		"0d\n",
+		"2,3a\nx\n.\n",
+		"1a\nx\n.\ns/.//\n",
+		"1d\n2d\n",
+	} {
+		_, err := dmp.PatchFromEdScript(text1, script)
+		assert.NotEqual(t, nil, err, script)
+	}
+}
+
+func Test_lineFormatsApply(t *testing.T) {
+	dmp := createDMP()
+	r := rand.New(rand.NewSource(1))
+	randomLines := func() string {
+		lines := make([]string, r.Intn(15))
+		for i := range lines {
+			lines[i] = string(rune('a'+r.Intn(4))) + "\n"
+		}
+		return strings.Join(lines, "")
+	}
+	// Equalities repeating the last one still belong to their patch.
+	texts := [][2]string{{"a\nb\nc\nd\nc\nc\na\nc\nd\nb\na\nc\n", "d\na\nc\nc\nb\nc\nb\nc\nc\n"}}
+	for i := 0; i < 500; i++ {
+		texts = append(texts, [2]string{randomLines(), randomLines()})
+	}
+	for _, pair := range texts {
+		a, b := pair[0], pair[1]
+		diffs := lineDiffs(&dmp, a, b)
+		for _, format := range []struct {
+			render func([]Diff) string
+			parse  func(string, string) ([]Patch, error)
+		}{
+			{func(diffs []Diff) string { return dmp.DiffToContextDiff(diffs, UnifiedOptions{}) }, dmp.PatchFromContextDiff},
+			{dmp.DiffToNormalDiff, dmp.PatchFromNormalDiff},
+			{dmp.DiffToEdScript, dmp.PatchFromEdScript},
+		} {
+			patches, err := format.parse(a, format.render(diffs))
+			assert.Equal(t, nil, err, a, b)
+			text, _ := dmp.PatchApply(patches, a)
+			assert.Equal(t, b, text, a, b)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package diffmatchpatch
+
+import (
+	"context"
+	"sort"
+)
+
+// Match is an approximate occurrence of a pattern in a text.
+type Match struct {
+	// Start and End delimit the match in the text.
+	Start, End int
+	// Distance is the number of characters inserted, deleted or
+	// substituted to turn the match into the pattern.
+	Distance int
+	// Score is the bitap score of the match, from 0.0 for an exact match up
+	// to MatchThreshold.
+	Score float64
+}
+
+// MatchOrder selects the order of the matches MatchAll returns.
+type MatchOrder int
+
+const (
+	// MatchByPosition orders matches from the start of the text.
+	MatchByPosition MatchOrder = iota
+	// MatchByScore orders matches from the best, and equally good ones
+	// from the start of the text.
+	MatchByScore
+)
+
+// MatchOptions controls a single call to MatchAll.
+type MatchOptions struct {
+	// Order is the order of the matches.
+	Order MatchOrder
+	// Limit is the most matches returned, the first in Order.  Zero means
+	// no limit.
+	Limit int
+	// Unit counts Start and End of the matches.
+	Unit Unit
+}
+
+// MatchAll finds all the approximate occurrences of pattern in text that
+// score no worse than MatchThreshold.  Where occurrences overlap only the
+// best is kept.  Unlike MatchMain it doesn't favour any location.
+func (dmp *DiffMatchPatch) MatchAll(text, pattern string, opts MatchOptions) []Match {
+	matches, _ := dmp.MatchAllContext(context.Background(), text, pattern, opts)
+	return matches
+}
+
+// MatchAllContext is like MatchAll but gives up when ctx is done, returning
+// the matches found so far together with ctx.Err().
+func (dmp *DiffMatchPatch) MatchAllContext(ctx context.Context, text, pattern string, opts MatchOptions) ([]Match, error) {
+	keys := map[string]rune{}
+	chars, offsets := dmp.matchChars(text, keys)
+	patternChars, _ := dmp.matchChars(pattern, keys)
+	if len(patternChars) == 0 {
+		return nil, ctx.Err()
+	}
+
+	// Every end of an occurrence in a single pass over the text: column i
+	// holds the fewest errors of the first i characters of the pattern
+	// against the text ending at the current character, and where in the
+	// text that alignment starts.
+	m := len(patternChars)
+	dist, start := make([]int, m+1), make([]int, m+1)
+	for i := range dist {
+		dist[i] = i
+	}
+	var found []Match
+	var err error
+	for j, c := range chars {
+		if j%1024 == 0 {
+			if err = ctx.Err(); err != nil {
+				break
+			}
+		}
+		// diag is the previous column's entry for i-1.
+		diag, diagStart := dist[0], start[0]
+		start[0] = j + 1
+		for i := 1; i <= m; i++ {
+			d, s := diag, diagStart
+			if patternChars[i-1] != c {
+				d++
+			}
+			// Prefer the later start, for the shorter match.
+			if dist[i]+1 < d || dist[i]+1 == d && start[i] > s {
+				d, s = dist[i]+1, start[i]
+			}
+			if dist[i-1]+1 < d || dist[i-1]+1 == d && start[i-1] > s {
+				d, s = dist[i-1]+1, start[i-1]
+			}
+			diag, diagStart = dist[i], start[i]
+			dist[i], start[i] = d, s
+		}
+		if start[m] <= j && dmp.matchBitapScore(dist[m], 0, 0, patternChars) <= dmp.MatchThreshold {
+			found = append(found, Match{Start: start[m], End: j + 1, Distance: dist[m]})
+		}
+	}
+
+	// Keep the best of overlapping occurrences, the shortest of equally
+	// good ones.
+	sort.SliceStable(found, func(a, b int) bool {
+		if found[a].Distance != found[b].Distance {
+			return found[a].Distance < found[b].Distance
+		}
+		return found[a].End-found[a].Start < found[b].End-found[b].Start
+	})
+	var matches []Match
+	for _, f := range found {
+		k := sort.Search(len(matches), func(k int) bool { return matches[k].Start >= f.End })
+		if k > 0 && matches[k-1].End > f.Start {
+			continue
+		}
+		matches = append(matches, Match{})
+		copy(matches[k+1:], matches[k:])
+		matches[k] = f
+	}
+
+	if opts.Order == MatchByScore {
+		sort.SliceStable(matches, func(a, b int) bool { return matches[a].Distance < matches[b].Distance })
+	}
+	if opts.Limit > 0 && len(matches) > opts.Limit {
+		matches = matches[:opts.Limit]
+	}
+
+	// The locations count characters so far.
+	units := offsets
+	if opts.Unit != UnitByte {
+		units = make([]int, len(offsets))
+		for k := 1; k < len(offsets); k++ {
+			units[k] = units[k-1] + unitLen(text[offsets[k-1]:offsets[k]], opts.Unit)
+		}
+	}
+	for k := range matches {
+		match := &matches[k]
+		match.Score = dmp.matchBitapScore(match.Distance, 0, 0, patternChars)
+		match.Start, match.End = units[match.Start], units[match.End]
+	}
+	return matches, err
+}
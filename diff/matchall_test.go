@@ -0,0 +1,94 @@
+package diffmatchpatch
+
+import (
+	"context"
+	"github.com/bmizerany/assert"
+	"strings"
+	"testing"
+)
+
+func Test_matchAll(t *testing.T) {
+	dmp := createDMP()
+	dmp.MatchThreshold = 0.4
+	text := "the quick brown fox jumps over the lazy dog; the quack brawn fix"
+
+	assert.Equal(t, []Match{
+		Match{0, 3, 0, 0},
+		Match{31, 34, 0, 0},
+		Match{45, 48, 0, 0}}, dmp.MatchAll(text, "the", MatchOptions{}))
+
+	third := 1.0 / 3
+	assert.Equal(t, []Match{
+		Match{16, 19, 0, 0},
+		Match{61, 64, 1, third}}, dmp.MatchAll(text, "fox", MatchOptions{}))
+	assert.Equal(t, []Match{
+		Match{4, 9, 0, 0},
+		Match{49, 54, 1, 0.2}}, dmp.MatchAll(text, "quick", MatchOptions{}))
+
+	// Insertions and deletions.
+	assert.Equal(t, []Match{
+		Match{10, 15, 1, 0.25},
+		Match{55, 60, 1, 0.25}}, dmp.MatchAll(text, "brwn", MatchOptions{}))
+	assert.Equal(t, []Match{
+		Match{20, 25, 1, 1.0 / 6}}, dmp.MatchAll(text, "jumpes", MatchOptions{}))
+
+	// Ordered by score and limited.
+	assert.Equal(t, []Match{
+		Match{4, 9, 0, 0},
+		Match{49, 54, 1, 0.2}}, dmp.MatchAll(text, "quick", MatchOptions{Order: MatchByScore}))
+	assert.Equal(t, []Match{
+		Match{10, 15, 1, 0.2},
+		Match{55, 60, 0, 0}}, dmp.MatchAll(text, "brawn", MatchOptions{Order: MatchByPosition}))
+	assert.Equal(t, []Match{
+		Match{55, 60, 0, 0},
+		Match{10, 15, 1, 0.2}}, dmp.MatchAll(text, "brawn", MatchOptions{Order: MatchByScore}))
+	assert.Equal(t, []Match{
+		Match{55, 60, 0, 0}}, dmp.MatchAll(text, "brawn", MatchOptions{Order: MatchByScore, Limit: 1}))
+
+	// Overlapping occurrences keep the best.
+	assert.Equal(t, []Match{
+		Match{0, 4, 0, 0},
+		Match{4, 8, 0, 0}}, dmp.MatchAll("abababab", "abab", MatchOptions{}))
+	assert.Equal(t, []Match{
+		Match{2, 6, 0, 0}}, dmp.MatchAll("abxabyab", "xaby", MatchOptions{}))
+
+	// No matches.
+	assert.Equal(t, 0, len(dmp.MatchAll(text, "xylophone", MatchOptions{})))
+	assert.Equal(t, 0, len(dmp.MatchAll(text, "", MatchOptions{})))
+	assert.Equal(t, 0, len(dmp.MatchAll("", "fox", MatchOptions{})))
+	dmp.MatchThreshold = 0
+	assert.Equal(t, []Match{
+		Match{16, 19, 0, 0}}, dmp.MatchAll(text, "fox", MatchOptions{}))
+}
+
+func Test_matchAllUnits(t *testing.T) {
+	dmp := createDMP()
+	dmp.MatchThreshold = 0.3
+	text := "Ξεσκεπάζω την ψυχοφθόρα 🐱 βδελυγμία, ψυχοφθορα"
+	assert.Equal(t, []Match{
+		Match{26, 44, 1, 1.0 / 9},
+		Match{70, 88, 1, 1.0 / 9}}, dmp.MatchAll(text, "ψυχοφθώρα", MatchOptions{}))
+	assert.Equal(t, []Match{
+		Match{14, 23, 1, 1.0 / 9},
+		Match{37, 46, 1, 1.0 / 9}}, dmp.MatchAll(text, "ψυχοφθώρα", MatchOptions{Unit: UnitRune}))
+	assert.Equal(t, []Match{
+		Match{14, 23, 1, 1.0 / 9},
+		Match{38, 47, 1, 1.0 / 9}}, dmp.MatchAll(text, "ψυχοφθώρα", MatchOptions{Unit: UnitUTF16}))
+	assert.Equal(t, []Match{
+		Match{24, 26, 0, 0}}, dmp.MatchAll(text, "🐱", MatchOptions{Unit: UnitUTF16}))
+}
+
+func Test_matchAllContext(t *testing.T) {
+	dmp := createDMP()
+	text := strings.Repeat("the quick brown fox jumps over the lazy dog ", 100)
+
+	matches, err := dmp.MatchAllContext(context.Background(), text, "fox", MatchOptions{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 100, len(matches))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	matches, err = dmp.MatchAllContext(ctx, text, "fox", MatchOptions{})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 0, len(matches))
+}
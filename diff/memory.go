@@ -0,0 +1,243 @@
+package diffmatchpatch
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// runeSize is the size of an item of a character diff.
+	runeSize = 4
+	// foldedSize is the size of an item of a character diff with a
+	// Folder: its number and its offset.
+	foldedSize = 8 + 8
+	// tokenSize is the size of an item of a line or chunk diff: its
+	// number and its text.
+	tokenSize = 8 + 16
+	// numberSize is the size of an entry of the map that numbers distinct
+	// lines or chunks, allowing for its spare capacity, and of the line or
+	// chunk kept for it.
+	numberSize = 64
+	// bisectItemSize is the size of the two vectors of bisect per item.
+	bisectItemSize = 2 * 8
+)
+
+// fits reports whether a diff of n items against m items of itemSize bytes
+// stays within DiffMaxMemory.
+func (dmp *DiffMatchPatch) fits(n, m, itemSize int) bool {
+	return dmp.DiffMaxMemory <= 0 || (n+m)*(itemSize+bisectItemSize) <= dmp.DiffMaxMemory
+}
+
+// charSize returns the size of an item of a character diff.
+func (dmp *DiffMatchPatch) charSize() int {
+	if dmp.Folder != nil {
+		return foldedSize
+	}
+	return runeSize
+}
+
+// diffBounded finds the differences between two texts too large for a
+// character diff within DiffMaxMemory.  After trimming the common prefix
+// and suffix it diffs lines, or chunks of lines if there are too many, and
+// rediffs the changes that fit character by character.  The texts of the
+// result share memory with text1 and text2 where possible.
+func (dmp *DiffMatchPatch) diffBounded(ctx context.Context, text1, text2 string, checklines bool, deadline time.Time) []Diff {
+	whole1, whole2 := text1, text2
+	// Trim off the common prefix and suffix, which costs no memory.
+	n := dmp.DiffCommonPrefix(text1, text2)
+	prefix := text1[:n]
+	text1, text2 = text1[n:], text2[n:]
+	n = dmp.DiffCommonSuffix(text1, text2)
+	suffix := text1[len(text1)-n:]
+	text1, text2 = text1[:len(text1)-n], text2[:len(text2)-n]
+
+	var diffs []Diff
+	if dmp.fits(utf8.RuneCountInString(text1), utf8.RuneCountInString(text2), dmp.charSize()) {
+		diffs = dmp.diffChars(ctx, text1, text2, checklines, deadline)
+	} else {
+		diffs = dmp.diffRediff(ctx, dmp.diffChunks(ctx, text1, text2, deadline), deadline)
+	}
+	if len(prefix) != 0 {
+		diffs = append([]Diff{Diff{DiffEqual, prefix}}, diffs...)
+	}
+	if len(suffix) != 0 {
+		diffs = append(diffs, Diff{DiffEqual, suffix})
+	}
+	return dmp.mergeDiffs(diffs, whole1, whole2)
+}
+
+// mergeDiffs drops empty diffs and merges neighbours of the same type,
+// putting the deletions of a run of changes before its insertions, like
+// DiffCleanupMerge but without copying: the texts of the result are slices
+// of text1 and text2, the texts diffs is a diff of.  With a Folder an
+// equality doesn't tell how long it is in text2, so the insertions of a
+// run are joined instead.
+func (dmp *DiffMatchPatch) mergeDiffs(diffs []Diff, text1, text2 string) []Diff {
+	merged := []Diff{}
+	// The offsets in text1 and text2 and the lengths not yet added.
+	i, j := 0, 0
+	equal, deleted, inserted := 0, 0, 0
+	var insertions []string
+	addEqual := func() {
+		if equal != 0 {
+			merged = append(merged, Diff{DiffEqual, text1[i : i+equal]})
+			i, j = i+equal, j+equal
+			equal = 0
+		}
+	}
+	addChanges := func() {
+		if deleted != 0 {
+			merged = append(merged, Diff{DiffDelete, text1[i : i+deleted]})
+			i += deleted
+			deleted = 0
+		}
+		if inserted != 0 {
+			if dmp.Folder != nil {
+				merged = append(merged, Diff{DiffInsert, strings.Join(insertions, "")})
+			} else {
+				merged = append(merged, Diff{DiffInsert, text2[j : j+inserted]})
+			}
+			j += inserted
+			inserted = 0
+			insertions = insertions[:0]
+		}
+	}
+	for _, aDiff := range diffs {
+		switch aDiff.Type {
+		case DiffDelete:
+			addEqual()
+			deleted += len(aDiff.Text)
+		case DiffInsert:
+			addEqual()
+			inserted += len(aDiff.Text)
+			if dmp.Folder != nil {
+				insertions = append(insertions, aDiff.Text)
+			}
+		case DiffEqual:
+			addChanges()
+			equal += len(aDiff.Text)
+		}
+	}
+	addEqual()
+	addChanges()
+	return merged
+}
+
+// diffChunks diffs two texts line by line, or in chunks of lines if there
+// are too many lines for DiffMaxMemory.  Chunks end after the lines whose
+// hash is a multiple of the chunk size so that an edit only changes the
+// chunks it touches.
+func (dmp *DiffMatchPatch) diffChunks(ctx context.Context, text1, text2 string, deadline time.Time) []Diff {
+	lines1 := strings.Count(text1, "\n") + 1
+	lines2 := strings.Count(text2, "\n") + 1
+	size := 1
+	for !dmp.fits(lines1/size, lines2/size, tokenSize+numberSize) {
+		size *= 2
+	}
+
+	// Number the chunks so that equal chunks, once folded if there is a
+	// Folder, get the same number.  The map holds hashes, as folded keys
+	// would be copies of the texts, and the first chunk of each number
+	// settles collisions: a chunk whose hash is taken by a different one
+	// gets a number of its own.  Allocating for the expected number of
+	// chunks up front spares the copies growing would make.
+	numbers := make(map[uint64]int, (lines1+lines2)/size)
+	firsts := make([]string, 0, (lines1+lines2)/size)
+	number := func(text string, lines int) ([]int, []string) {
+		ids := make([]int, 0, lines/size+1)
+		chunks := make([]string, 0, lines/size+1)
+		for len(text) != 0 {
+			end := dmp.chunkEnd(text, size)
+			chunk := text[:end]
+			h := dmp.keyHash(chunk)
+			id, ok := numbers[h]
+			if !ok || !dmp.keyEqual(firsts[id], chunk) {
+				id = len(firsts)
+				firsts = append(firsts, chunk)
+				if !ok {
+					numbers[h] = id
+				}
+			}
+			ids = append(ids, id)
+			chunks = append(chunks, chunk)
+			text = text[end:]
+		}
+		return ids, chunks
+	}
+	ids1, chunks1 := number(text1, lines1)
+	ids2, chunks2 := number(text2, lines2)
+	numbers, firsts = nil, nil // Free them for the diff.
+
+	d := &differ[int]{dmp: dmp, ctx: ctx, deadline: deadline}
+	diffs := []Diff{}
+	i, j := 0, 0
+	offset1, offset2 := 0, 0
+	for _, e := range d.main(ids1, ids2, false) {
+		// Chunks are consecutive, so each edit is a single slice of a text.
+		n := 0
+		switch e.Type {
+		case DiffInsert:
+			for _, chunk := range chunks2[j : j+len(e.Items)] {
+				n += len(chunk)
+			}
+			diffs = append(diffs, Diff{DiffInsert, text2[offset2 : offset2+n]})
+			j += len(e.Items)
+			offset2 += n
+		default:
+			for _, chunk := range chunks1[i : i+len(e.Items)] {
+				n += len(chunk)
+			}
+			diffs = append(diffs, Diff{e.Type, text1[offset1 : offset1+n]})
+			i += len(e.Items)
+			offset1 += n
+			if e.Type == DiffEqual {
+				j += len(e.Items)
+				offset2 += n
+			}
+		}
+	}
+	return diffs
+}
+
+// chunkEnd returns the length of the first chunk of text: its first line,
+// or if size is more than one, its lines up to one whose hash, once folded
+// if there is a Folder, is a multiple of size.
+func (dmp *DiffMatchPatch) chunkEnd(text string, size int) int {
+	end := 0
+	for end < len(text) {
+		n := strings.IndexByte(text[end:], '\n')
+		if n == -1 {
+			return len(text)
+		}
+		line := text[end : end+n+1]
+		end += n + 1
+		if size == 1 || dmp.keyHash(line)%uint64(size) == 0 {
+			break
+		}
+	}
+	return end
+}
+
+// fnvOffset64 is the 64-bit FNV-1a hash of nothing.
+const fnvOffset64 = 14695981039346656037
+
+// fnv64 continues the 64-bit FNV-1a hash h with s.
+func fnv64(h uint64, s string) uint64 {
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= 1099511628211
+	}
+	return h
+}
+
+// fnv32 returns the 32-bit FNV-1a hash of s.
+func fnv32(s string) uint32 {
+	h := uint32(2166136261)
+	for i := 0; i < len(s); i++ {
+		h ^= uint32(s[i])
+		h *= 16777619
+	}
+	return h
+}
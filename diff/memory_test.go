@@ -0,0 +1,157 @@
+package diffmatchpatch
+
+import (
+	"fmt"
+	"github.com/bmizerany/assert"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"testing"
+	"time"
+)
+
+// logText returns n lines of a log, with every step-th line changed if
+// edited is set.
+func logText(n, step int, edited bool) string {
+	var text strings.Builder
+	for i := 0; i < n; i++ {
+		if edited && i%step == 0 {
+			fmt.Fprintf(&text, "%06d WARN request %d was slow\n", i, i*7)
+		} else {
+			fmt.Fprintf(&text, "%06d INFO request %d served\n", i, i*7)
+		}
+	}
+	return text.String()
+}
+
+func Test_diffMaxMemory(t *testing.T) {
+	dmp := createDMP()
+	text1 := logText(200, 50, false)
+	text2 := logText(200, 50, true)
+	expected := dmp.DiffMain(text1, text2)
+
+	// Lines fit but the characters don't.  The changed lines are small
+	// enough to be rediffed character by character.
+	dmp.DiffMaxMemory = 20000
+	diffs := dmp.DiffMain(text1, text2)
+	assert.Equal(t, []string{text1, text2}, diffRebuildtexts(diffs))
+	assert.Equal(t, expected, diffs)
+
+	// Whole lines or chunks of lines.
+	dmp.DiffMaxMemory = 1000
+	diffs = dmp.DiffMain(text1, text2)
+	assert.Equal(t, []string{text1, text2}, diffRebuildtexts(diffs))
+	// The first chunk is lines 0 to 5.
+	assert.Equal(t, []Diff{
+		Diff{DiffEqual, "000000 "},
+		Diff{DiffDelete, text1[7:strings.Index(text1, "000006")]},
+		Diff{DiffInsert, text2[7:strings.Index(text2, "000006")]}}, diffs[:3])
+
+	// Chunks are numbered as folded, so changes of case alone are no
+	// changes.
+	dmp.Folder = FoldCase
+	assert.Equal(t, []Diff{Diff{DiffEqual, text1}}, dmp.DiffMain(text1, strings.ToUpper(text1)))
+	diffs = dmp.DiffMain(text1, strings.ToUpper(text2))
+	assert.Equal(t, text1, dmp.DiffText1(diffs))
+	assert.Equal(t, FoldCase.Fold(text2), FoldCase.Fold(dmp.DiffText2(diffs)))
+	dmp.Folder = nil
+
+	// No budget at all, only the common prefix and suffix are found.
+	dmp.DiffMaxMemory = 1
+	diffs = dmp.DiffMain(text1, text2)
+	assert.Equal(t, []string{text1, text2}, diffRebuildtexts(diffs))
+	assert.Equal(t, 4, len(diffs))
+}
+
+func Test_chunkEnd(t *testing.T) {
+	dmp := createDMP()
+	assert.Equal(t, 2, dmp.chunkEnd("a\nb\n", 1))
+	assert.Equal(t, 3, dmp.chunkEnd("abc", 1))
+	assert.Equal(t, 0, dmp.chunkEnd("", 4))
+	// Chunks end after a line whose hash is a multiple of the size.
+	text := logText(100, 1, false)
+	end := dmp.chunkEnd(text, 8)
+	assert.Equal(t, uint64(0), fnv64(fnvOffset64, text[strings.LastIndex(text[:end-1], "\n")+1:end])%8)
+	assert.Equal(t, end, dmp.chunkEnd(text[:end]+"x\ny\n", 8))
+}
+
+// peakHeap returns roughly how far the heap in use grows above where it
+// starts while f runs.  It samples the heap from another goroutine with the
+// collector running nearly all the time, so that the heap holds little
+// more than what f keeps alive; peaks between samples may be missed.
+func peakHeap(f func()) uint64 {
+	defer debug.SetGCPercent(debug.SetGCPercent(1))
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	base, peak := stats.HeapInuse, stats.HeapInuse
+	done := make(chan bool)
+	sampled := make(chan bool)
+	go func() {
+		var stats runtime.MemStats
+		for {
+			runtime.ReadMemStats(&stats)
+			if stats.HeapInuse > peak {
+				peak = stats.HeapInuse
+			}
+			select {
+			case <-done:
+				close(sampled)
+				return
+			case <-time.After(50 * time.Microsecond):
+			}
+		}
+	}()
+	f()
+	close(done)
+	<-sampled
+	return peak - base
+}
+
+func Test_diffMaxMemoryPeak(t *testing.T) {
+	if testing.Short() {
+		t.Skip("measures the heap of large diffs")
+	}
+	text1 := logText(50000, 1000, false)
+	text2 := logText(50000, 1000, true)
+	for _, maxMemory := range []int{1 << 20, 4 << 20} {
+		dmp := createDMP()
+		dmp.DiffMaxMemory = maxMemory
+		var diffs []Diff
+		peak := peakHeap(func() { diffs = dmp.DiffMain(text1, text2) })
+		assert.Equal(t, []string{text1, text2}, diffRebuildtexts(diffs))
+		assert.T(t, peak <= uint64(maxMemory), maxMemory, peak)
+	}
+
+	// Folded diffs keep to the budget too.
+	dmp := createDMP()
+	dmp.DiffMaxMemory = 1 << 20
+	dmp.Folder = FoldCase
+	upper := strings.ToUpper(text2)
+	var diffs []Diff
+	peak := peakHeap(func() { diffs = dmp.DiffMain(text1, upper) })
+	assert.Equal(t, text1, dmp.DiffText1(diffs))
+	assert.Equal(t, FoldCase.Fold(upper), FoldCase.Fold(dmp.DiffText2(diffs)))
+	assert.T(t, peak <= uint64(dmp.DiffMaxMemory), dmp.DiffMaxMemory, peak)
+}
+
+func benchmarkDiffMaxMemory(b *testing.B, maxMemory int) {
+	text1 := logText(50000, 1000, false)
+	text2 := logText(50000, 1000, true)
+	dmp := createDMP()
+	dmp.DiffMaxMemory = maxMemory
+	var peak uint64
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if p := peakHeap(func() { dmp.DiffMain(text1, text2) }); p > peak {
+			peak = p
+		}
+	}
+	b.ReportMetric(float64(peak), "peak-B")
+}
+
+// Diffs of two logs of 1.5 MB each.  The peak heap in use stays within
+// DiffMaxMemory, and the smaller it is the faster and coarser the diff.
+func BenchmarkDiffMaxMemoryUnlimited(b *testing.B) { benchmarkDiffMaxMemory(b, 0) }
+func BenchmarkDiffMaxMemory16M(b *testing.B)       { benchmarkDiffMaxMemory(b, 16<<20) }
+func BenchmarkDiffMaxMemory1M(b *testing.B)        { benchmarkDiffMaxMemory(b, 1<<20) }
@@ -0,0 +1,242 @@
+package diffmatchpatch
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// Option configures a DiffMatchPatch created by New.
+type Option func(*DiffMatchPatch) error
+
+// ConfigError reports a DiffMatchPatch setting that is out of range.
+type ConfigError struct {
+	Field  string
+	Value  interface{}
+	Reason string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("diffmatchpatch: invalid %s %v: %s", e.Field, e.Value, e.Reason)
+}
+
+// UnknownPresetError is returned when WithPreset names a preset that was
+// never registered.
+type UnknownPresetError struct {
+	Name string
+}
+
+func (e *UnknownPresetError) Error() string {
+	return "diffmatchpatch: unknown preset " + strconv.Quote(e.Name)
+}
+
+// New returns a DiffMatchPatch with the default settings, overridden by
+// opts in order. The resulting configuration is validated before it is
+// returned.
+func New(opts ...Option) (*DiffMatchPatch, error) {
+	dmp := createDMP()
+	for _, opt := range opts {
+		if err := opt(&dmp); err != nil {
+			return nil, err
+		}
+	}
+	if err := dmp.Validate(); err != nil {
+		return nil, err
+	}
+	return &dmp, nil
+}
+
+// Validate checks that every setting is within the range the algorithms
+// can handle and returns a *ConfigError describing the first one that
+// isn't.
+func (dmp *DiffMatchPatch) Validate() error {
+	switch {
+	case dmp.DiffTimeout < 0:
+		return &ConfigError{"DiffTimeout", dmp.DiffTimeout, "must not be negative"}
+	case dmp.DiffEditCost < 0:
+		return &ConfigError{"DiffEditCost", dmp.DiffEditCost, "must not be negative"}
+	case dmp.MatchThreshold < 0 || dmp.MatchThreshold > 1:
+		return &ConfigError{"MatchThreshold", dmp.MatchThreshold, "must be between 0 and 1"}
+	case dmp.MatchDistance < 0:
+		return &ConfigError{"MatchDistance", dmp.MatchDistance, "must not be negative"}
+	case dmp.PatchDeleteThreshold < 0 || dmp.PatchDeleteThreshold > 1:
+		return &ConfigError{"PatchDeleteThreshold", dmp.PatchDeleteThreshold, "must be between 0 and 1"}
+	case dmp.PatchMargin < 0:
+		return &ConfigError{"PatchMargin", dmp.PatchMargin, "must not be negative"}
+	case dmp.MatchMaxBits <= 0:
+		return &ConfigError{"MatchMaxBits", dmp.MatchMaxBits, "must be positive"}
+	case dmp.DiffMaxMemory < 0:
+		return &ConfigError{"DiffMaxMemory", dmp.DiffMaxMemory, "must not be negative"}
+	case dmp.DiffAlgorithm < AlgorithmMyers || dmp.DiffAlgorithm > AlgorithmHistogram:
+		return &ConfigError{"DiffAlgorithm", dmp.DiffAlgorithm, "unknown algorithm"}
+	case 2*dmp.PatchMargin >= dmp.MatchMaxBits:
+		return &ConfigError{"PatchMargin", dmp.PatchMargin, "must leave room for a pattern within MatchMaxBits"}
+	}
+	return nil
+}
+
+// WithDiffTimeout sets the number of seconds to map a diff before giving up
+// (0 for infinity).
+func WithDiffTimeout(seconds float64) Option {
+	return func(dmp *DiffMatchPatch) error {
+		dmp.DiffTimeout = seconds
+		return nil
+	}
+}
+
+// WithDiffEditCost sets the cost of an empty edit operation in terms of
+// edit characters.
+func WithDiffEditCost(cost int) Option {
+	return func(dmp *DiffMatchPatch) error {
+		dmp.DiffEditCost = cost
+		return nil
+	}
+}
+
+// WithDiffAlgorithm sets the algorithm that finds the differences.
+func WithDiffAlgorithm(algorithm Algorithm) Option {
+	return func(dmp *DiffMatchPatch) error {
+		dmp.DiffAlgorithm = algorithm
+		return nil
+	}
+}
+
+// WithDiffMaxMemory sets a rough limit in bytes on the working memory of a
+// diff (0 for no limit).
+func WithDiffMaxMemory(bytes int) Option {
+	return func(dmp *DiffMatchPatch) error {
+		dmp.DiffMaxMemory = bytes
+		return nil
+	}
+}
+
+// WithMatchThreshold sets at what point no match is declared
+// (0.0 = perfection, 1.0 = very loose).
+func WithMatchThreshold(threshold float64) Option {
+	return func(dmp *DiffMatchPatch) error {
+		dmp.MatchThreshold = threshold
+		return nil
+	}
+}
+
+// WithMatchDistance sets how far to search for a match
+// (0 = exact location, 1000+ = broad match).
+func WithMatchDistance(distance int) Option {
+	return func(dmp *DiffMatchPatch) error {
+		dmp.MatchDistance = distance
+		return nil
+	}
+}
+
+// WithMatchScorer sets how candidate matches are scored (nil for
+// ProximityScorer with MatchDistance).
+func WithMatchScorer(scorer MatchScorer) Option {
+	return func(dmp *DiffMatchPatch) error {
+		dmp.MatchScorer = scorer
+		return nil
+	}
+}
+
+// WithFolder sets how texts are folded before they are compared (nil to
+// compare them as they are).
+func WithFolder(folder Folder) Option {
+	return func(dmp *DiffMatchPatch) error {
+		dmp.Folder = folder
+		return nil
+	}
+}
+
+// WithPatchDeleteThreshold sets how closely the contents of a large deleted
+// block have to match (0.0 = perfection, 1.0 = very loose).
+func WithPatchDeleteThreshold(threshold float64) Option {
+	return func(dmp *DiffMatchPatch) error {
+		dmp.PatchDeleteThreshold = threshold
+		return nil
+	}
+}
+
+// WithPatchMargin sets the chunk size for context length.
+func WithPatchMargin(margin int) Option {
+	return func(dmp *DiffMatchPatch) error {
+		dmp.PatchMargin = margin
+		return nil
+	}
+}
+
+// WithMatchMaxBits sets the longest text a patch is located by as a whole.
+func WithMatchMaxBits(bits int) Option {
+	return func(dmp *DiffMatchPatch) error {
+		dmp.MatchMaxBits = bits
+		return nil
+	}
+}
+
+// Names of the presets that are registered by default.
+const (
+	// PresetStrict only accepts near-exact matches close to the expected
+	// location and spends as long as it takes to find a minimal diff.
+	PresetStrict = "strict"
+	// PresetFuzzy tolerates more errors and drift when matching and
+	// patching.
+	PresetFuzzy = "fuzzy"
+	// PresetFast trades diff quality for speed.
+	PresetFast = "fast"
+)
+
+var (
+	presetsMu sync.RWMutex
+	presets   = map[string][]Option{
+		PresetStrict: {
+			WithDiffTimeout(0),
+			WithMatchThreshold(0.1),
+			WithMatchDistance(100),
+			WithPatchDeleteThreshold(0.1),
+		},
+		PresetFuzzy: {
+			WithMatchThreshold(0.8),
+			WithMatchDistance(5000),
+			WithPatchDeleteThreshold(0.8),
+			WithPatchMargin(8),
+		},
+		PresetFast: {
+			WithDiffTimeout(0.1),
+			WithDiffEditCost(8),
+		},
+	}
+)
+
+// RegisterPreset makes opts available under name to WithPreset, replacing
+// any preset already registered under that name. It is safe to call from
+// several goroutines, typically from a package's init function.
+func RegisterPreset(name string, opts ...Option) {
+	presetsMu.Lock()
+	defer presetsMu.Unlock()
+	presets[name] = append([]Option(nil), opts...)
+}
+
+// WithPreset applies the options registered under name. Options that follow
+// it in the call to New override the preset.
+func WithPreset(name string) Option {
+	return func(dmp *DiffMatchPatch) error {
+		presetsMu.RLock()
+		opts, ok := presets[name]
+		presetsMu.RUnlock()
+		if !ok {
+			return &UnknownPresetError{name}
+		}
+		for _, opt := range opts {
+			if err := opt(dmp); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// WithClock sets the clock that DiffTimeout is measured against.
+func WithClock(clock Clock) Option {
+	return func(dmp *DiffMatchPatch) error {
+		dmp.Clock = clock
+		return nil
+	}
+}
@@ -0,0 +1,97 @@
+package diffmatchpatch
+
+import (
+	"github.com/bmizerany/assert"
+	"testing"
+)
+
+func Test_newDefaults(t *testing.T) {
+	dmp, err := New()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, createDMP(), *dmp)
+}
+
+func Test_newOptions(t *testing.T) {
+	dmp, err := New(
+		WithDiffTimeout(2.5),
+		WithDiffEditCost(6),
+		WithMatchThreshold(0.25),
+		WithMatchDistance(50),
+		WithPatchDeleteThreshold(0.75),
+		WithPatchMargin(2),
+		WithMatchMaxBits(16),
+		WithDiffAlgorithm(AlgorithmPatience),
+		WithDiffMaxMemory(1<<20),
+		WithMatchScorer(EditDistanceScorer{}),
+		WithFolder(FoldCase))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2.5, dmp.DiffTimeout)
+	assert.Equal(t, 6, dmp.DiffEditCost)
+	assert.Equal(t, 0.25, dmp.MatchThreshold)
+	assert.Equal(t, 50, dmp.MatchDistance)
+	assert.Equal(t, 0.75, dmp.PatchDeleteThreshold)
+	assert.Equal(t, 2, dmp.PatchMargin)
+	assert.Equal(t, 16, dmp.MatchMaxBits)
+	assert.Equal(t, AlgorithmPatience, dmp.DiffAlgorithm)
+	assert.Equal(t, 1<<20, dmp.DiffMaxMemory)
+	assert.Equal(t, EditDistanceScorer{}, dmp.MatchScorer)
+	assert.Equal(t, FoldCase.Fold("A"), dmp.Folder.Fold("A"))
+}
+
+func Test_newValidation(t *testing.T) {
+	tests := []struct {
+		opt   Option
+		field string
+	}{
+		{WithDiffTimeout(-1), "DiffTimeout"},
+		{WithDiffEditCost(-1), "DiffEditCost"},
+		{WithMatchThreshold(-0.5), "MatchThreshold"},
+		{WithMatchThreshold(1.5), "MatchThreshold"},
+		{WithMatchDistance(-10), "MatchDistance"},
+		{WithPatchDeleteThreshold(2), "PatchDeleteThreshold"},
+		{WithPatchMargin(-1), "PatchMargin"},
+		{WithPatchMargin(500), "PatchMargin"},
+		{WithMatchMaxBits(0), "MatchMaxBits"},
+		{WithMatchMaxBits(-1), "MatchMaxBits"},
+		{WithDiffAlgorithm(-1), "DiffAlgorithm"},
+		{WithDiffMaxMemory(-1), "DiffMaxMemory"},
+	}
+	for _, test := range tests {
+		dmp, err := New(test.opt)
+		assert.Equal(t, (*DiffMatchPatch)(nil), dmp)
+		cerr, ok := err.(*ConfigError)
+		assert.T(t, ok, test.field)
+		assert.Equal(t, test.field, cerr.Field)
+	}
+}
+
+func Test_newPresets(t *testing.T) {
+	dmp, err := New(WithPreset(PresetStrict))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0.0, dmp.DiffTimeout)
+	assert.Equal(t, 0.1, dmp.MatchThreshold)
+
+	// Later options override the preset.
+	dmp, err = New(WithPreset(PresetFuzzy), WithMatchThreshold(0.6))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0.6, dmp.MatchThreshold)
+	assert.Equal(t, 5000, dmp.MatchDistance)
+
+	dmp, err = New(WithPreset(PresetFast))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 8, dmp.DiffEditCost)
+
+	_, err = New(WithPreset("no-such-preset"))
+	assert.Equal(t, &UnknownPresetError{"no-such-preset"}, err)
+
+	RegisterPreset("test-service", WithMatchDistance(42), WithPatchMargin(3))
+	dmp, err = New(WithPreset("test-service"))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 42, dmp.MatchDistance)
+	assert.Equal(t, 3, dmp.PatchMargin)
+
+	RegisterPreset("broken", WithMatchThreshold(7))
+	_, err = New(WithPreset("broken"))
+	_, ok := err.(*ConfigError)
+	assert.T(t, ok)
+}
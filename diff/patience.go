@@ -0,0 +1,85 @@
+package diffmatchpatch
+
+import (
+	"sort"
+)
+
+// patience finds the differences between a and b, which have no common
+// prefix or suffix, by patience diff: the items that occur exactly once in
+// each become anchors, the longest run of anchors in the same order in
+// both is taken as equal and the gaps between them are diffed in turn.
+// Without anchors it falls back on bisect.
+func (d *differ[T]) patience(a, b []T) []Edit[T] {
+	anchors := patienceAnchors(a, b)
+	if len(anchors) == 0 {
+		return d.bisect(a, b)
+	}
+	edits := []Edit[T]{}
+	i, j := 0, 0
+	for _, anchor := range anchors {
+		edits = append(edits, d.main(a[i:anchor[0]], b[j:anchor[1]], false)...)
+		edits = append(edits, Edit[T]{DiffEqual, a[anchor[0] : anchor[0]+1]})
+		i, j = anchor[0]+1, anchor[1]+1
+	}
+	return append(edits, d.main(a[i:], b[j:], false)...)
+}
+
+// patienceAnchors returns the positions in a and b of the items unique to
+// both, keeping the longest sequence that is ascending in both.
+func patienceAnchors[T comparable](a, b []T) [][2]int {
+	type count struct{ inA, inB, indexB int }
+	counts := make(map[T]*count, len(a))
+	for _, x := range a {
+		c := counts[x]
+		if c == nil {
+			c = &count{}
+			counts[x] = c
+		}
+		c.inA++
+	}
+	for j, y := range b {
+		if c := counts[y]; c != nil {
+			c.inB++
+			c.indexB = j
+		}
+	}
+	var unique [][2]int
+	for i, x := range a {
+		if c := counts[x]; c.inA == 1 && c.inB == 1 {
+			unique = append(unique, [2]int{i, c.indexB})
+		}
+	}
+	return longestAscending(unique)
+}
+
+// longestAscending returns the longest subsequence of pairs, which are
+// ascending in their first element, that is ascending in the second too.
+// It deals the pairs into piles as in the card game patience.
+func longestAscending(pairs [][2]int) [][2]int {
+	// tops holds the index of the pair on top of each pile, prev the pair
+	// on top of the pile to the left when each pair was dealt.
+	var tops []int
+	prev := make([]int, len(pairs))
+	for n, pair := range pairs {
+		pile := sort.Search(len(tops), func(k int) bool {
+			return pairs[tops[k]][1] > pair[1]
+		})
+		prev[n] = -1
+		if pile > 0 {
+			prev[n] = tops[pile-1]
+		}
+		if pile == len(tops) {
+			tops = append(tops, n)
+		} else {
+			tops[pile] = n
+		}
+	}
+	if len(tops) == 0 {
+		return nil
+	}
+	result := make([][2]int, len(tops))
+	for k, n := len(tops)-1, tops[len(tops)-1]; k >= 0; k, n = k-1, prev[n] {
+		result[k] = pairs[n]
+	}
+	return result
+}
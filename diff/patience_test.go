@@ -0,0 +1,34 @@
+package diffmatchpatch
+
+import (
+	"github.com/bmizerany/assert"
+	"testing"
+)
+
+func Test_longestAscending(t *testing.T) {
+	assert.Equal(t, [][2]int(nil), longestAscending(nil))
+	assert.Equal(t, [][2]int{{1, 0}, {3, 2}, {4, 4}},
+		longestAscending([][2]int{{0, 1}, {1, 0}, {2, 3}, {3, 2}, {4, 4}}))
+	assert.Equal(t, [][2]int{{1, 0}}, longestAscending([][2]int{{0, 2}, {1, 0}}))
+}
+
+func Test_diffPatience(t *testing.T) {
+	dmp := createDMP()
+	dmp.DiffAlgorithm = AlgorithmPatience
+	text1 := "int a()\n{\n    x();\n}\n\nint b()\n{\n    y();\n}\n"
+	text2 := "int b()\n{\n    y();\n}\n\nint a()\n{\n    z();\n}\n"
+	chars1, chars2, lineArray := dmp.DiffLinesToChars(text1, text2)
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(chars1, chars2, false), lineArray)
+	// Myers matches up the braces of different functions.
+	assert.Equal(t, []Diff{
+		Diff{DiffDelete, "int a()\n{\n    x();\n}\n\n"},
+		Diff{DiffEqual, "int b()\n{\n    y();\n}\n"},
+		Diff{DiffInsert, "\nint a()\n{\n    z();\n}\n"}}, diffs)
+
+	// Character by character, where few items are unique.
+	text1 = "The quick brown fox jumps over the lazy dog.\n"
+	text2 = "That quick brown fox jumped over a lazy dog!\n"
+	diffs = dmp.DiffMain(text1, text2, false)
+	assert.Equal(t, text1, dmp.DiffText1(diffs))
+	assert.Equal(t, text2, dmp.DiffText2(diffs))
+}
@@ -0,0 +1,162 @@
+package diffmatchpatch
+
+import "math"
+
+// MatchScorer scores the candidate matches of MatchBitap, and so of
+// MatchMain and PatchApply.  Lower scores are better; candidates scoring
+// above MatchThreshold are rejected.
+type MatchScorer interface {
+	// Score returns the score of a match of a pattern of patternLen
+	// characters with errors errors, starting at character x of the text
+	// when it was expected at character loc.  It must not decrease as
+	// errors or the distance between x and loc grow.
+	Score(errors float64, x, loc, patternLen int) float64
+}
+
+// SubstitutionScorer is implemented by MatchScorers that make some
+// substitutions cheaper than others.  Characters a pattern character may
+// be substituted by for less than a whole error count as matches while
+// searching, and the errors of a candidate are then counted with these
+// costs.
+type SubstitutionScorer interface {
+	MatchScorer
+	// SubstitutionCost returns the cost, between 0 and 1, of the text
+	// having t where the pattern has p.
+	SubstitutionCost(p, t rune) float64
+}
+
+// ProximityScorer adds to the share of the pattern in error 1.0 for every
+// Distance characters between the match and its expected location.  With
+// Distance 0 only matches at the expected location score below 1.0.  It is
+// the default scorer, with MatchDistance as Distance.
+type ProximityScorer struct {
+	Distance int
+}
+
+// Score implements MatchScorer.
+func (s ProximityScorer) Score(errors float64, x, loc, patternLen int) float64 {
+	accuracy := errors / float64(patternLen)
+	proximity := math.Abs(float64(loc - x))
+	if s.Distance == 0 {
+		// Dodge divide by zero error.
+		if proximity == 0 {
+			return accuracy
+		}
+		return 1.0
+	}
+	return accuracy + proximity/float64(s.Distance)
+}
+
+// EditDistanceScorer scores matches by the share of the pattern in error
+// alone, wherever they are.
+type EditDistanceScorer struct{}
+
+// Score implements MatchScorer.
+func (EditDistanceScorer) Score(errors float64, x, loc, patternLen int) float64 {
+	return errors / float64(patternLen)
+}
+
+// GaussianScorer adds to the share of the pattern in error a penalty for
+// the distance from the expected location that follows an inverted
+// Gaussian curve of standard deviation Sigma characters: it grows slowly
+// near the expected location, fastest at Sigma, and levels off towards
+// 1.0 far away.
+type GaussianScorer struct {
+	Sigma float64
+}
+
+// Score implements MatchScorer.
+func (s GaussianScorer) Score(errors float64, x, loc, patternLen int) float64 {
+	accuracy := errors / float64(patternLen)
+	proximity := float64(loc - x)
+	if s.Sigma <= 0 {
+		if proximity == 0 {
+			return accuracy
+		}
+		return accuracy + 1.0
+	}
+	return accuracy + 1 - math.Exp(-proximity*proximity/(2*s.Sigma*s.Sigma))
+}
+
+// WeightedScorer scores matches with Scorer, but counts the substitutions
+// in Costs as less than a whole error, such as of characters OCR confuses.
+// Costs apply to substitutions either way round.  A nil Scorer is
+// EditDistanceScorer.
+type WeightedScorer struct {
+	Scorer MatchScorer
+	Costs  map[[2]rune]float64
+}
+
+// Score implements MatchScorer.
+func (s WeightedScorer) Score(errors float64, x, loc, patternLen int) float64 {
+	if s.Scorer == nil {
+		return EditDistanceScorer{}.Score(errors, x, loc, patternLen)
+	}
+	return s.Scorer.Score(errors, x, loc, patternLen)
+}
+
+// SubstitutionCost implements SubstitutionScorer.
+func (s WeightedScorer) SubstitutionCost(p, t rune) float64 {
+	if p == t {
+		return 0
+	}
+	if cost, ok := s.Costs[[2]rune{p, t}]; ok {
+		return cost
+	}
+	if cost, ok := s.Costs[[2]rune{t, p}]; ok {
+		return cost
+	}
+	return 1
+}
+
+// OCRConfusions are characters OCR commonly mistakes for each other, at a
+// cost of a quarter of an error, for use as the Costs of a WeightedScorer.
+var OCRConfusions = map[[2]rune]float64{
+	{'0', 'O'}: 0.25,
+	{'0', 'o'}: 0.25,
+	{'O', 'o'}: 0.25,
+	{'1', 'l'}: 0.25,
+	{'1', 'I'}: 0.25,
+	{'l', 'I'}: 0.25,
+	{'5', 'S'}: 0.25,
+	{'8', 'B'}: 0.25,
+	{'2', 'Z'}: 0.25,
+	{'6', 'b'}: 0.25,
+	{'c', 'e'}: 0.25,
+	{'u', 'v'}: 0.25,
+}
+
+// matchScorer returns the scorer MatchBitap uses.
+func (dmp *DiffMatchPatch) matchScorer() MatchScorer {
+	if dmp.MatchScorer != nil {
+		return dmp.MatchScorer
+	}
+	return ProximityScorer{dmp.MatchDistance}
+}
+
+// weightedErrors counts the errors of the best match of pattern at the
+// start of text, with substitutions costing as s says.
+func weightedErrors(pattern, text []rune, s SubstitutionScorer) float64 {
+	// Matches taking more than twice the pattern's length of text cost
+	// more than deleting the whole pattern.
+	if len(text) > 2*len(pattern) {
+		text = text[:2*len(pattern)]
+	}
+	// row[i] is the cost of matching the first i characters of the
+	// pattern with the text so far.
+	row := make([]float64, len(pattern)+1)
+	for i := range row {
+		row[i] = float64(i)
+	}
+	best := row[len(pattern)]
+	for _, t := range text {
+		diag := row[0]
+		row[0]++
+		for i, p := range pattern {
+			cost := math.Min(diag+s.SubstitutionCost(p, t), math.Min(row[i+1], row[i])+1)
+			diag, row[i+1] = row[i+1], cost
+		}
+		best = math.Min(best, row[len(pattern)])
+	}
+	return best
+}
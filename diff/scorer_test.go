@@ -0,0 +1,80 @@
+package diffmatchpatch
+
+import (
+	"github.com/bmizerany/assert"
+	"math"
+	"testing"
+)
+
+func Test_matchScorers(t *testing.T) {
+	assert.Equal(t, 0.5, ProximityScorer{100}.Score(2, 10, 10, 4))
+	assert.Equal(t, 0.75, ProximityScorer{100}.Score(2, 35, 10, 4))
+	assert.Equal(t, 0.5, ProximityScorer{0}.Score(2, 10, 10, 4))
+	assert.Equal(t, 1.0, ProximityScorer{0}.Score(2, 11, 10, 4))
+
+	assert.Equal(t, 0.5, EditDistanceScorer{}.Score(2, 1000, 10, 4))
+
+	assert.Equal(t, 0.5, GaussianScorer{10}.Score(2, 10, 10, 4))
+	assert.Equal(t, 0.5+1-math.Exp(-0.5), GaussianScorer{10}.Score(2, 20, 10, 4))
+	assert.T(t, GaussianScorer{10}.Score(2, 1000, 10, 4) > 1.49)
+	assert.Equal(t, 1.5, GaussianScorer{}.Score(2, 11, 10, 4))
+
+	weighted := WeightedScorer{Costs: OCRConfusions}
+	assert.Equal(t, 0.5, weighted.Score(2, 1000, 10, 4))
+	assert.Equal(t, 0.25, weighted.SubstitutionCost('0', 'O'))
+	assert.Equal(t, 0.25, weighted.SubstitutionCost('O', '0'))
+	assert.Equal(t, 1.0, weighted.SubstitutionCost('O', 'Q'))
+	assert.Equal(t, 0.0, weighted.SubstitutionCost('Q', 'Q'))
+	weighted.Scorer = ProximityScorer{100}
+	assert.Equal(t, 0.75, weighted.Score(2, 35, 10, 4))
+}
+
+func Test_weightedErrors(t *testing.T) {
+	s := WeightedScorer{Costs: OCRConfusions}
+	assert.Equal(t, 0.0, weightedErrors([]rune("Hello"), []rune("Hello world"), s))
+	assert.Equal(t, 0.25, weightedErrors([]rune("Hello"), []rune("He1lo w0rld"), s))
+	assert.Equal(t, 0.5, weightedErrors([]rune("Hello"), []rune("He1l0 world"), s))
+	assert.Equal(t, 1.25, weightedErrors([]rune("Hello"), []rune("He1o w0rld"), s))
+	assert.Equal(t, 1.0, weightedErrors([]rune("Hello"), []rune("Hexllo"), s))
+	assert.Equal(t, 5.0, weightedErrors([]rune("Hello"), []rune(""), s))
+}
+
+func Test_matchBitapScorer(t *testing.T) {
+	dmp := createDMP()
+	dmp.MatchThreshold = 0.3
+	dmp.MatchDistance = 10
+	text := "abcdefghijklmnopqrstuvwxyz" + "0123456789" + "abcdefghijkLmnopqrstuvwxyz"
+
+	// Location is irrelevant to the edit distance, so the exact match far
+	// from loc beats the one with an error nearby.
+	assert.Equal(t, 8, dmp.MatchBitap(text, "ijkLmn", 8))
+	dmp.MatchScorer = EditDistanceScorer{}
+	assert.Equal(t, 44, dmp.MatchBitap(text, "ijkLmn", 8))
+
+	// A narrow Gaussian only accepts matches close to loc.
+	dmp.MatchScorer = GaussianScorer{Sigma: 2}
+	assert.Equal(t, 44, dmp.MatchBitap(text, "ijkLmn", 43))
+	assert.Equal(t, -1, dmp.MatchBitap(text, "ijkLmn", 30))
+
+	// OCR confusions cost a quarter of an error.
+	dmp.MatchScorer = nil
+	dmp.MatchDistance = 1000
+	dmp.MatchThreshold = 0.1
+	ocr := "The 0ld man and the 5ea, Ernest Hemingway, 1952"
+	assert.Equal(t, -1, dmp.MatchBitap(ocr, "Old man and the Sea", 0))
+	dmp.MatchScorer = WeightedScorer{ProximityScorer{dmp.MatchDistance}, OCRConfusions}
+	assert.Equal(t, 4, dmp.MatchBitap(ocr, "Old man and the Sea", 0))
+	assert.Equal(t, 4, dmp.MatchBitap(ocr, "Old man and tie Sea", 0))
+	assert.Equal(t, -1, dmp.MatchBitap(ocr, "Old man end tie Sun", 0))
+
+	// PatchApply consults the scorer too.
+	patches := dmp.PatchMake("Sale of 150 lbs of soil at 10 cents a pound", "Sale of 150 lbs of soil at 10 dollars a pound")
+	scanned := "Sale of 150 lbs of so1l at lO cents a p0und"
+	dmp.MatchScorer = nil
+	_, results := dmp.PatchApply(patches, scanned)
+	assert.Equal(t, []bool{false}, results)
+	dmp.MatchScorer = WeightedScorer{ProximityScorer{dmp.MatchDistance}, OCRConfusions}
+	patched, results := dmp.PatchApply(patches, scanned)
+	assert.Equal(t, []bool{true}, results)
+	assert.Equal(t, "Sale of 150 lbs of so1l at lO dollars a p0und", patched)
+}
@@ -0,0 +1,438 @@
+package diffmatchpatch
+
+import (
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// LinePair is a row of a side-by-side diff: a line of the source text and
+// the line of the destination text shown next to it.
+type LinePair struct {
+	// Left and Right hold the two lines without their line breaks, split
+	// into the parts that are equal and the parts that changed.  Left has
+	// no insertions, Right no deletions.
+	Left, Right []Diff
+	// Line1 and Line2 are the line numbers, counted from 1, or 0 for a side
+	// left empty.
+	Line1, Line2 int
+	// Folded, if not zero, makes the row a marker for that many unchanged
+	// lines that are not shown, starting at Line1 and Line2.
+	Folded int
+}
+
+// changed reports whether the row shows a change.
+func (p *LinePair) changed() bool {
+	if p.Line1 == 0 || p.Line2 == 0 {
+		return true
+	}
+	for _, aDiff := range p.Left {
+		if aDiff.Type != DiffEqual {
+			return true
+		}
+	}
+	for _, aDiff := range p.Right {
+		if aDiff.Type != DiffEqual {
+			return true
+		}
+	}
+	return false
+}
+
+// marker returns the character diff -y puts between the columns.
+func (p *LinePair) marker() string {
+	switch {
+	case p.Line1 == 0:
+		return ">"
+	case p.Line2 == 0:
+		return "<"
+	case p.changed():
+		return "|"
+	}
+	return " "
+}
+
+// AlignLines lines up the two texts diffs describes for side-by-side
+// display.  The texts are diffed again line by line, with
+// DiffCleanupSemantic merging changes that are close together.  Within
+// each run of changed lines the deleted lines are paired with the inserted
+// lines in order and the pairs are diffed character by character; lines
+// left over get a row of their own.
+func (dmp *DiffMatchPatch) AlignLines(diffs []Diff) []LinePair {
+	chars1, chars2, _, lines1, lines2 := dmp.diffLinesToChars(dmp.DiffText1(diffs), dmp.DiffText2(diffs))
+	lineDiffs := dmp.DiffCleanupSemantic(dmp.DiffMain(chars1, chars2, false))
+	lines := splitDiffLines(diffCharsToTokens(lineDiffs, lines1, lines2))
+
+	var pairs []LinePair
+	line1, line2 := 0, 0
+	for i := 0; i < len(lines); {
+		if lines[i].op == DiffEqual {
+			line1++
+			line2++
+			text := lineSegment(DiffEqual, lines[i].text)
+			pairs = append(pairs, LinePair{Left: text, Right: text, Line1: line1, Line2: line2})
+			i++
+			continue
+		}
+		var deleted, inserted []string
+		for ; i < len(lines) && lines[i].op != DiffEqual; i++ {
+			if lines[i].op == DiffDelete {
+				deleted = append(deleted, lines[i].text)
+			} else {
+				inserted = append(inserted, lines[i].text)
+			}
+		}
+		for k := 0; k < len(deleted) || k < len(inserted); k++ {
+			var p LinePair
+			if k < len(deleted) && k < len(inserted) {
+				p.Left, p.Right = dmp.diffLinePair(deleted[k], inserted[k])
+			} else if k < len(deleted) {
+				p.Left = lineSegment(DiffDelete, deleted[k])
+			} else {
+				p.Right = lineSegment(DiffInsert, inserted[k])
+			}
+			if k < len(deleted) {
+				line1++
+				p.Line1 = line1
+			}
+			if k < len(inserted) {
+				line2++
+				p.Line2 = line2
+			}
+			pairs = append(pairs, p)
+		}
+	}
+	return pairs
+}
+
+// lineSegment returns line without its line break as a single Diff.
+func lineSegment(op int8, line string) []Diff {
+	line = strings.TrimSuffix(line, "\n")
+	if len(line) == 0 {
+		return nil
+	}
+	return []Diff{Diff{op, line}}
+}
+
+// diffLinePair diffs two lines and splits the result into its sides.
+func (dmp *DiffMatchPatch) diffLinePair(line1, line2 string) (left, right []Diff) {
+	diffs := dmp.DiffMain(strings.TrimSuffix(line1, "\n"), strings.TrimSuffix(line2, "\n"), false)
+	for _, aDiff := range dmp.DiffCleanupSemantic(diffs) {
+		if aDiff.Type != DiffInsert {
+			left = append(left, aDiff)
+		}
+		if aDiff.Type != DiffDelete {
+			right = append(right, aDiff)
+		}
+	}
+	return left, right
+}
+
+// pairLines pairs up the lines of diffs without realigning them: lines
+// are paired at each line break of an equality, any surplus on one side
+// getting rows of their own.
+func pairLines(diffs []Diff) []LinePair {
+	var pairs []LinePair
+	var left, right [][]Diff
+	var curLeft, curRight []Diff
+	line1, line2 := 0, 0
+	flush := func() {
+		for i := 0; i < len(left) || i < len(right); i++ {
+			var p LinePair
+			if i < len(left) {
+				line1++
+				p.Left, p.Line1 = left[i], line1
+			}
+			if i < len(right) {
+				line2++
+				p.Right, p.Line2 = right[i], line2
+			}
+			pairs = append(pairs, p)
+		}
+		left, right = nil, nil
+	}
+	for _, aDiff := range diffs {
+		for i, line := range strings.Split(aDiff.Text, "\n") {
+			if i > 0 {
+				if aDiff.Type != DiffInsert {
+					left = append(left, curLeft)
+					curLeft = nil
+				}
+				if aDiff.Type != DiffDelete {
+					right = append(right, curRight)
+					curRight = nil
+				}
+				if aDiff.Type == DiffEqual {
+					flush()
+				}
+			}
+			if len(line) == 0 {
+				continue
+			}
+			if aDiff.Type != DiffInsert {
+				curLeft = append(curLeft, Diff{aDiff.Type, line})
+			}
+			if aDiff.Type != DiffDelete {
+				curRight = append(curRight, Diff{aDiff.Type, line})
+			}
+		}
+	}
+	if len(curLeft) != 0 {
+		left = append(left, curLeft)
+	}
+	if len(curRight) != 0 {
+		right = append(right, curRight)
+	}
+	flush()
+	return pairs
+}
+
+// foldPairs replaces the unchanged rows farther than context rows from a
+// change by markers.
+func foldPairs(pairs []LinePair, context int) []LinePair {
+	var folded []LinePair
+	for i := 0; i < len(pairs); {
+		if pairs[i].changed() {
+			folded = append(folded, pairs[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(pairs) && !pairs[j].changed() {
+			j++
+		}
+		before, after := context, context
+		if i == 0 {
+			before = 0
+		}
+		if j == len(pairs) {
+			after = 0
+		}
+		if j-i > before+after {
+			folded = append(folded, pairs[i:i+before]...)
+			first := pairs[i+before]
+			folded = append(folded, LinePair{Line1: first.Line1, Line2: first.Line2, Folded: j - i - before - after})
+			folded = append(folded, pairs[j-after:j]...)
+		} else {
+			folded = append(folded, pairs[i:j]...)
+		}
+		i = j
+	}
+	return folded
+}
+
+func foldMessage(n int) string {
+	if n == 1 {
+		return "⋯ 1 unchanged line"
+	}
+	return "⋯ " + strconv.Itoa(n) + " unchanged lines"
+}
+
+// SideBySide renders diffs in two columns, the source text on the left and
+// the destination text on the right, in the manner of diff -y.  The zero
+// value is ready to use; it aligns lines with the default settings of New
+// and writes no colour.
+type SideBySide struct {
+	// Width is the width of text output in columns, 130 if zero.  Each
+	// column gets half of it less the gutter; longer lines are cut off.
+	Width int
+	// TabWidth is the distance between tab stops, 8 if zero.
+	TabWidth int
+	// Fold replaces unchanged lines farther than Context lines from a
+	// change by a marker giving their number.
+	Fold bool
+	// Context is the number of unchanged lines kept around changes when
+	// folding.  Zero means 3, a negative value means none.
+	Context int
+	// Palette and NoColor are used as by TerminalRenderer.
+	Palette Palette
+	NoColor bool
+	// HTML supplies the tags and classes of RenderHTML; nil uses those of
+	// NewHTMLRenderer.
+	HTML *HTMLRenderer
+
+	dmp *DiffMatchPatch
+}
+
+// NewSideBySide returns a renderer that aligns lines using the settings of
+// dmp and, like NewTerminalRenderer, writes colour only if w is a terminal.
+func (dmp *DiffMatchPatch) NewSideBySide(w io.Writer) *SideBySide {
+	return &SideBySide{Palette: DefaultPalette, NoColor: !ColorEnabled(w), dmp: dmp}
+}
+
+// Pairs returns the rows the renderer shows for diffs.
+func (s *SideBySide) Pairs(diffs []Diff) []LinePair {
+	dmp := s.dmp
+	if dmp == nil {
+		defaults := createDMP()
+		dmp = &defaults
+	}
+	pairs := dmp.AlignLines(diffs)
+	if s.Fold {
+		context := s.Context
+		if context == 0 {
+			context = 3
+		} else if context < 0 {
+			context = 0
+		}
+		pairs = foldPairs(pairs, context)
+	}
+	return pairs
+}
+
+// Render writes diffs as text to w.  It stops at the first write error and
+// returns it.
+func (s *SideBySide) Render(w io.Writer, diffs []Diff) error {
+	width := s.Width
+	if width <= 0 {
+		width = 130
+	}
+	column := (width - 3) / 2
+	if column < 1 {
+		column = 1
+	}
+	ew := &errWriter{w: w}
+	for _, p := range s.Pairs(diffs) {
+		if p.Folded != 0 {
+			s.writeColumn(ew, []Diff{Diff{DiffEqual, foldMessage(p.Folded)}},
+				s.Palette.Whitespace, s.Palette.Whitespace, width, false)
+			ew.WriteString("\n")
+			continue
+		}
+		marker := p.marker()
+		style, highlight := s.Palette.Equal, s.Palette.Equal
+		switch marker {
+		case "|":
+			style, highlight = s.Palette.Delete, s.Palette.DeleteHighlight
+		case "<":
+			style, highlight = s.Palette.Delete, s.Palette.Delete
+		}
+		s.writeColumn(ew, p.Left, style, highlight, column, true)
+		ew.WriteString(" " + marker)
+		if len(p.Right) != 0 {
+			switch marker {
+			case "|":
+				style, highlight = s.Palette.Insert, s.Palette.InsertHighlight
+			case ">":
+				style, highlight = s.Palette.Insert, s.Palette.Insert
+			}
+			ew.WriteString(" ")
+			s.writeColumn(ew, p.Right, style, highlight, column, false)
+		}
+		ew.WriteString("\n")
+	}
+	return ew.err
+}
+
+// writeColumn writes the parts of a line into a column width cells wide,
+// changed parts in highlight and the rest in style.  Tabs are expanded,
+// other control characters shown by their control pictures.  If pad is
+// set the column is filled up with spaces.
+func (s *SideBySide) writeColumn(w *errWriter, line []Diff, style, highlight Style, width int, pad bool) {
+	tabWidth := s.TabWidth
+	if tabWidth <= 0 {
+		tabWidth = 8
+	}
+	used := 0
+	full := false
+	for _, aDiff := range line {
+		var text strings.Builder
+		for _, r := range aDiff.Text {
+			n := runeWidth(r)
+			switch {
+			case r == '\t':
+				n = tabWidth - used%tabWidth
+				if used+n > width {
+					n = width - used
+				}
+				r = ' '
+			case r < 0x20:
+				r += 0x2400
+			case r == 0x7f:
+				r = 0x2421
+			}
+			if used+n > width {
+				full = true
+				break
+			}
+			if r == ' ' {
+				text.WriteString(strings.Repeat(" ", n))
+			} else {
+				text.WriteRune(r)
+			}
+			used += n
+		}
+		if aDiff.Type == DiffEqual {
+			s.writeStyled(w, style, text.String())
+		} else {
+			s.writeStyled(w, highlight, text.String())
+		}
+		if full {
+			break
+		}
+	}
+	if pad && used < width {
+		w.WriteString(strings.Repeat(" ", width-used))
+	}
+}
+
+func (s *SideBySide) writeStyled(w *errWriter, style Style, text string) {
+	if len(text) == 0 {
+		return
+	}
+	start := ""
+	if !s.NoColor {
+		start = style.sequence()
+	}
+	w.WriteString(start + text)
+	if len(start) != 0 {
+		w.WriteString("\x1b[0m")
+	}
+}
+
+// RenderHTML writes diffs to w as an HTML table with a row per pair of
+// lines.  It stops at the first write error and returns it.
+func (s *SideBySide) RenderHTML(w io.Writer, diffs []Diff) error {
+	r := s.HTML
+	if r == nil {
+		r = NewHTMLRenderer()
+	}
+	ew := &errWriter{w: w}
+	r.writeTable(ew, s.Pairs(diffs))
+	return ew.err
+}
+
+// wideRunes are the ranges of characters that take up two cells in a
+// terminal, an approximation of the East Asian Wide and Fullwidth classes.
+var wideRunes = [][2]rune{
+	{0x1100, 0x115f}, {0x231a, 0x231b}, {0x2329, 0x232a}, {0x23e9, 0x23ec},
+	{0x23f0, 0x23f0}, {0x23f3, 0x23f3}, {0x25fd, 0x25fe}, {0x2614, 0x2615},
+	{0x2648, 0x2653}, {0x267f, 0x267f}, {0x2693, 0x2693}, {0x26a1, 0x26a1},
+	{0x26aa, 0x26ab}, {0x26bd, 0x26be}, {0x26c4, 0x26c5}, {0x26ce, 0x26ce},
+	{0x26d4, 0x26d4}, {0x26ea, 0x26ea}, {0x26f2, 0x26f3}, {0x26f5, 0x26f5},
+	{0x26fa, 0x26fa}, {0x26fd, 0x26fd}, {0x2705, 0x2705}, {0x270a, 0x270b},
+	{0x2728, 0x2728}, {0x274c, 0x274c}, {0x274e, 0x274e}, {0x2753, 0x2755},
+	{0x2757, 0x2757}, {0x2795, 0x2797}, {0x27b0, 0x27b0}, {0x27bf, 0x27bf},
+	{0x2b1b, 0x2b1c}, {0x2b50, 0x2b50}, {0x2b55, 0x2b55}, {0x2e80, 0x303e},
+	{0x3041, 0x33ff}, {0x3400, 0x4dbf}, {0x4e00, 0x9fff}, {0xa000, 0xa4cf},
+	{0xa960, 0xa97f}, {0xac00, 0xd7a3}, {0xf900, 0xfaff}, {0xfe10, 0xfe19},
+	{0xfe30, 0xfe6f}, {0xff00, 0xff60}, {0xffe0, 0xffe6}, {0x16fe0, 0x16fe4},
+	{0x17000, 0x18cff}, {0x1b000, 0x1b2ff}, {0x1f004, 0x1f004}, {0x1f0cf, 0x1f0cf},
+	{0x1f18e, 0x1f18e}, {0x1f191, 0x1f19a}, {0x1f200, 0x1f2ff}, {0x1f300, 0x1f64f},
+	{0x1f680, 0x1f6ff}, {0x1f900, 0x1f9ff}, {0x1fa70, 0x1faff}, {0x20000, 0x2fffd},
+	{0x30000, 0x3fffd},
+}
+
+// runeWidth returns the number of terminal cells r takes up.
+func runeWidth(r rune) int {
+	if unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+	i := sort.Search(len(wideRunes), func(i int) bool { return wideRunes[i][1] >= r })
+	if i < len(wideRunes) && wideRunes[i][0] <= r {
+		return 2
+	}
+	return 1
+}
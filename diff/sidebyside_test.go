@@ -0,0 +1,113 @@
+package diffmatchpatch
+
+import (
+	"bytes"
+	"github.com/bmizerany/assert"
+	"strings"
+	"testing"
+)
+
+func Test_alignLines(t *testing.T) {
+	dmp := createDMP()
+	text1 := "one\ntwo\nthree\nfour\nfive\n"
+	text2 := "one\ntwice\nfour\nfive\nsix\n"
+	pairs := dmp.AlignLines(dmp.DiffMain(text1, text2, false))
+	assert.Equal(t, []LinePair{
+		{Left: []Diff{Diff{DiffEqual, "one"}}, Right: []Diff{Diff{DiffEqual, "one"}}, Line1: 1, Line2: 1},
+		{Left: []Diff{Diff{DiffEqual, "tw"}, Diff{DiffDelete, "o"}},
+			Right: []Diff{Diff{DiffEqual, "tw"}, Diff{DiffInsert, "ice"}}, Line1: 2, Line2: 2},
+		{Left: []Diff{Diff{DiffDelete, "three"}}, Line1: 3},
+		{Left: []Diff{Diff{DiffEqual, "four"}}, Right: []Diff{Diff{DiffEqual, "four"}}, Line1: 4, Line2: 3},
+		{Left: []Diff{Diff{DiffEqual, "five"}}, Right: []Diff{Diff{DiffEqual, "five"}}, Line1: 5, Line2: 4},
+		{Right: []Diff{Diff{DiffInsert, "six"}}, Line2: 5},
+	}, pairs)
+
+	// The alignment doesn't depend on the diff passed in.
+	assert.Equal(t, pairs, dmp.AlignLines([]Diff{Diff{DiffDelete, text1}, Diff{DiffInsert, text2}}))
+	assert.Equal(t, 0, len(dmp.AlignLines(nil)))
+}
+
+func Test_foldPairs(t *testing.T) {
+	dmp := createDMP()
+	var lines []string
+	for i := 0; i < 20; i++ {
+		lines = append(lines, strings.Repeat("x", i))
+	}
+	text1 := strings.Join(lines, "\n") + "\n"
+	text2 := strings.Replace(text1, "\nxxxxxxxxxx\n", "\nchanged\n", 1)
+	pairs := foldPairs(dmp.AlignLines(dmp.DiffMain(text1, text2, false)), 2)
+	assert.Equal(t, 7, len(pairs))
+	assert.Equal(t, LinePair{Line1: 1, Line2: 1, Folded: 8}, pairs[0])
+	assert.Equal(t, 9, pairs[1].Line1)
+	assert.Equal(t, true, pairs[3].changed())
+	assert.Equal(t, LinePair{Line1: 14, Line2: 14, Folded: 7}, pairs[6])
+
+	// Nothing is folded next to the changes.
+	assert.Equal(t, 20, len(foldPairs(dmp.AlignLines(dmp.DiffMain(text1, text2, false)), 10)))
+}
+
+func Test_sideBySideRender(t *testing.T) {
+	dmp := createDMP()
+	text1 := "one\ntwo\nthree\nfour\nfive\n"
+	text2 := "one\ntwice\nfour\nfive\nsix\n"
+	diffs := dmp.DiffMain(text1, text2, false)
+
+	var buff bytes.Buffer
+	s := &SideBySide{Width: 23}
+	assert.Equal(t, nil, s.Render(&buff, diffs))
+	assert.Equal(t, "one          one\n"+
+		"two        | twice\n"+
+		"three      <\n"+
+		"four         four\n"+
+		"five         five\n"+
+		"           > six\n", buff.String())
+
+	// Colour highlights the changes within paired lines.
+	buff.Reset()
+	s = dmp.NewSideBySide(&buff)
+	s.Width, s.NoColor = 23, false
+	assert.Equal(t, nil, s.Render(&buff, diffs))
+	assert.Equal(t, "one          one\n"+
+		"\x1b[31mtw\x1b[0m\x1b[7;31mo\x1b[0m        | \x1b[32mtw\x1b[0m\x1b[7;32mice\x1b[0m\n"+
+		"\x1b[31mthree\x1b[0m      <\n"+
+		"four         four\n"+
+		"five         five\n"+
+		"           > \x1b[32msix\x1b[0m\n", buff.String())
+
+	// Folding.
+	buff.Reset()
+	s = &SideBySide{Width: 23, Fold: true, Context: -1}
+	assert.Equal(t, nil, s.Render(&buff, dmp.DiffMain("a\nb\nc\nd\n", "a\nb\nc\nD\n", false)))
+	assert.Equal(t, "⋯ 3 unchanged lines\n"+
+		"d          | D\n", buff.String())
+}
+
+func Test_sideBySideColumns(t *testing.T) {
+	dmp := createDMP()
+	var buff bytes.Buffer
+	s := &SideBySide{Width: 15, TabWidth: 4}
+	// Tabs are expanded, long lines cut off and wide characters take two
+	// cells without being split.
+	assert.Equal(t, nil, s.Render(&buff, dmp.DiffMain("a\tb\n中文字\nlong line\r\n", "a\tb\n中文字\nlong line\r\n", false)))
+	assert.Equal(t, "a   b    a   b\n"+
+		"中文字   中文字\n"+
+		"long l   long l\n", buff.String())
+
+	assert.Equal(t, 1, runeWidth('a'))
+	assert.Equal(t, 2, runeWidth('中'))
+	assert.Equal(t, 2, runeWidth('😀'))
+	assert.Equal(t, 0, runeWidth('́'))
+}
+
+func Test_sideBySideRenderHTML(t *testing.T) {
+	dmp := createDMP()
+	var buff bytes.Buffer
+	s := &SideBySide{Fold: true, Context: 1}
+	assert.Equal(t, nil, s.RenderHTML(&buff, dmp.DiffMain("a\nb\nc\n<d>\n", "a\nb\nc\n<e>\n", false)))
+	assert.Equal(t, "<table>\n"+
+		"<tr><td colspan=\"2\">⋯ 2 unchanged lines</td></tr>\n"+
+		"<tr><td><span>c</span></td><td><span>c</span></td></tr>\n"+
+		"<tr><td><span>&lt;</span><del style=\"background:#ffe6e6;\">d</del><span>&gt;</span></td>"+
+		"<td><span>&lt;</span><ins style=\"background:#e6ffe6;\">e</ins><span>&gt;</span></td></tr>\n"+
+		"</table>\n", buff.String())
+}
@@ -0,0 +1,448 @@
+package diffmatchpatch
+
+import (
+	"context"
+	"time"
+)
+
+// Edit is one operation of a diff between two slices.  Type is DiffDelete
+// for items only in the first slice, DiffInsert for items only in the
+// second and DiffEqual for items in both.
+type Edit[T any] struct {
+	Type  int8
+	Items []T
+}
+
+// DiffSlices finds the differences between two slices with the algorithm
+// DiffMain uses for text, including its speedups, and the settings of dmp:
+// its DiffAlgorithm, and its DiffTimeout, which also enables the
+// half-match speedup that can make the diff non-minimal.  The Items of the
+// edits share memory with a and b.
+func DiffSlices[T comparable](dmp *DiffMatchPatch, a, b []T) []Edit[T] {
+	d := &differ[T]{dmp: dmp, ctx: context.Background(), deadline: dmp.deadline()}
+	return d.main(a, b, false)
+}
+
+// DiffSlicesFunc is like DiffSlices but compares items with equal, which
+// has to be an equivalence relation.  The items of equalities are those of
+// a.  Apart from a common prefix and suffix, every item is compared with
+// one item of each group of equal items seen before it; DiffSlices is much
+// faster for items with many different values.
+func DiffSlicesFunc[T any](dmp *DiffMatchPatch, a, b []T, equal func(x, y T) bool) []Edit[T] {
+	// Trim off the common prefix and suffix.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && equal(a[prefix], b[prefix]) {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && equal(a[len(a)-suffix-1], b[len(b)-suffix-1]) {
+		suffix++
+	}
+
+	// Number the items in between so that equal items get the same number
+	// and diff the numbers.
+	var groups []T
+	number := func(items []T) []int {
+		numbers := make([]int, len(items))
+		for i, item := range items {
+			n := 0
+			for n < len(groups) && !equal(groups[n], item) {
+				n++
+			}
+			if n == len(groups) {
+				groups = append(groups, item)
+			}
+			numbers[i] = n
+		}
+		return numbers
+	}
+	middle := DiffSlices(dmp, number(a[prefix:len(a)-suffix]), number(b[prefix:len(b)-suffix]))
+
+	edits := []Edit[T]{}
+	if prefix != 0 {
+		edits = append(edits, Edit[T]{DiffEqual, a[:prefix]})
+	}
+	i, j := prefix, prefix
+	for _, e := range middle {
+		n := len(e.Items)
+		switch e.Type {
+		case DiffInsert:
+			edits = append(edits, Edit[T]{DiffInsert, b[j : j+n]})
+			j += n
+		case DiffDelete:
+			edits = append(edits, Edit[T]{DiffDelete, a[i : i+n]})
+			i += n
+		default:
+			edits = append(edits, Edit[T]{DiffEqual, a[i : i+n]})
+			i += n
+			j += n
+		}
+	}
+	if suffix != 0 {
+		edits = append(edits, Edit[T]{DiffEqual, a[len(a)-suffix:]})
+	}
+	return mergeEdits(edits)
+}
+
+// differ holds the state of one diff between slices of T.
+type differ[T comparable] struct {
+	dmp      *DiffMatchPatch
+	ctx      context.Context
+	deadline time.Time
+	// lineMode, if set, diffs long inputs coarsely first when asked to
+	// check lines.
+	lineMode func(a, b []T) []Edit[T]
+}
+
+// main finds the differences between a and b.
+func (d *differ[T]) main(a, b []T, checklines bool) []Edit[T] {
+	if sliceEqual(a, b) {
+		if len(a) > 0 {
+			return []Edit[T]{{DiffEqual, a}}
+		}
+		return []Edit[T]{}
+	}
+
+	// Trim off the common prefix and suffix (speedup).
+	n := commonPrefix(a, b)
+	prefix := a[:n]
+	a, b = a[n:], b[n:]
+	n = commonSuffix(a, b)
+	suffix := a[len(a)-n:]
+	a, b = a[:len(a)-n], b[:len(b)-n]
+
+	// Compute the diff on the middle block.
+	edits := d.compute(a, b, checklines)
+	// Restore the prefix and suffix.
+	if len(prefix) != 0 {
+		edits = append([]Edit[T]{{DiffEqual, prefix}}, edits...)
+	}
+	if len(suffix) != 0 {
+		edits = append(edits, Edit[T]{DiffEqual, suffix})
+	}
+	return mergeEdits(edits)
+}
+
+// compute finds the differences between a and b, which have no common
+// prefix or suffix.
+func (d *differ[T]) compute(a, b []T, checklines bool) []Edit[T] {
+	if len(a) == 0 {
+		// Just add some items (speedup).
+		return []Edit[T]{{DiffInsert, b}}
+	}
+	if len(b) == 0 {
+		// Just delete some items (speedup).
+		return []Edit[T]{{DiffDelete, a}}
+	}
+	if d.ctx.Err() != nil {
+		// Cancelled, settle for the trivial diff.
+		return []Edit[T]{{DiffDelete, a}, {DiffInsert, b}}
+	}
+
+	longer, shorter := a, b
+	op := int8(DiffDelete)
+	if len(a) <= len(b) {
+		longer, shorter = b, a
+		op = DiffInsert
+	}
+	if i := index(longer, shorter, 0); i != -1 {
+		// The shorter slice is inside the longer one (speedup).
+		return []Edit[T]{
+			{op, longer[:i]},
+			{DiffEqual, shorter},
+			{op, longer[i+len(shorter):]},
+		}
+	}
+	if len(shorter) == 1 {
+		// After the previous speedup the item can't be an equality.
+		return []Edit[T]{{DiffDelete, a}, {DiffInsert, b}}
+	}
+
+	if d.dmp.DiffAlgorithm == AlgorithmMyers {
+		// Check to see if the problem can be split in two.
+		if hm := d.halfMatch(a, b); hm != nil {
+			// Send both pairs off for separate processing.
+			editsA := d.main(hm[0], hm[2], checklines)
+			editsB := d.main(hm[1], hm[3], checklines)
+			edits := append(editsA, Edit[T]{DiffEqual, hm[4]})
+			return append(edits, editsB...)
+		}
+	}
+	if checklines && d.lineMode != nil && len(a) > 100 && len(b) > 100 {
+		return d.lineMode(a, b)
+	}
+	switch d.dmp.DiffAlgorithm {
+	case AlgorithmPatience:
+		return d.patience(a, b)
+	case AlgorithmHistogram:
+		return d.histogram(a, b)
+	}
+	return d.bisect(a, b)
+}
+
+// bisect finds the 'middle snake' of a diff, splits the problem in two and
+// returns the recursively constructed diff.
+// See Myers 1986 paper: An O(ND) Difference Algorithm and Its Variations.
+func (d *differ[T]) bisect(a, b []T) []Edit[T] {
+	aLength, bLength := len(a), len(b)
+	maxD := (aLength + bLength + 1) / 2
+	vOffset := maxD
+	vLength := 2*maxD + 2
+	v1 := make([]int, vLength)
+	v2 := make([]int, vLength)
+	for i := range v1 {
+		v1[i] = -1
+		v2[i] = -1
+	}
+	v1[vOffset+1] = 0
+	v2[vOffset+1] = 0
+
+	delta := aLength - bLength
+	// If the total number of items is odd, then the front path will
+	// collide with the reverse path.
+	front := delta%2 != 0
+	// Offsets for start and end of k loop.  Prevents mapping of space
+	// beyond the grid.
+	k1start, k1end, k2start, k2end := 0, 0, 0, 0
+	for step := 0; step < maxD; step++ {
+		// Bail out if deadline is reached or the caller gave up.
+		if d.dmp.expired(d.ctx, d.deadline) {
+			break
+		}
+
+		// Walk the front path one step.
+		for k1 := -step + k1start; k1 <= step-k1end; k1 += 2 {
+			k1Offset := vOffset + k1
+			var x1 int
+			if k1 == -step || (k1 != step && v1[k1Offset-1] < v1[k1Offset+1]) {
+				x1 = v1[k1Offset+1]
+			} else {
+				x1 = v1[k1Offset-1] + 1
+			}
+			y1 := x1 - k1
+			for x1 < aLength && y1 < bLength && a[x1] == b[y1] {
+				x1++
+				y1++
+			}
+			v1[k1Offset] = x1
+			if x1 > aLength {
+				// Ran off the right of the graph.
+				k1end += 2
+			} else if y1 > bLength {
+				// Ran off the bottom of the graph.
+				k1start += 2
+			} else if front {
+				k2Offset := vOffset + delta - k1
+				if k2Offset >= 0 && k2Offset < vLength && v2[k2Offset] != -1 {
+					// Mirror x2 onto top-left coordinate system.
+					x2 := aLength - v2[k2Offset]
+					if x1 >= x2 {
+						// Overlap detected.
+						return d.bisectSplit(a, b, x1, y1)
+					}
+				}
+			}
+		}
+
+		// Walk the reverse path one step.
+		for k2 := -step + k2start; k2 <= step-k2end; k2 += 2 {
+			k2Offset := vOffset + k2
+			var x2 int
+			if k2 == -step || (k2 != step && v2[k2Offset-1] < v2[k2Offset+1]) {
+				x2 = v2[k2Offset+1]
+			} else {
+				x2 = v2[k2Offset-1] + 1
+			}
+			y2 := x2 - k2
+			for x2 < aLength && y2 < bLength && a[aLength-x2-1] == b[bLength-y2-1] {
+				x2++
+				y2++
+			}
+			v2[k2Offset] = x2
+			if x2 > aLength {
+				// Ran off the left of the graph.
+				k2end += 2
+			} else if y2 > bLength {
+				// Ran off the top of the graph.
+				k2start += 2
+			} else if !front {
+				k1Offset := vOffset + delta - k2
+				if k1Offset >= 0 && k1Offset < vLength && v1[k1Offset] != -1 {
+					x1 := v1[k1Offset]
+					y1 := vOffset + x1 - k1Offset
+					// Mirror x2 onto top-left coordinate system.
+					x2 = aLength - x2
+					if x1 >= x2 {
+						// Overlap detected.
+						return d.bisectSplit(a, b, x1, y1)
+					}
+				}
+			}
+		}
+	}
+	// The diff hit the deadline or the number of edits equals the number
+	// of items, no commonality at all.
+	edits := []Edit[T]{}
+	if aLength > 0 {
+		edits = append(edits, Edit[T]{DiffDelete, a})
+	}
+	if bLength > 0 {
+		edits = append(edits, Edit[T]{DiffInsert, b})
+	}
+	return edits
+}
+
+// bisectSplit diffs the two halves either side of the middle snake at x
+// and y and joins the results.
+func (d *differ[T]) bisectSplit(a, b []T, x, y int) []Edit[T] {
+	edits := d.main(a[:x], b[:y], false)
+	return append(edits, d.main(a[x:], b[y:], false)...)
+}
+
+// halfMatch checks whether a and b share a run of items which is at least
+// half the length of the longer one.  It returns the prefix of a, the
+// suffix of a, the prefix of b, the suffix of b and the common middle, or
+// nil.  This speedup can produce non-minimal diffs.
+func (d *differ[T]) halfMatch(a, b []T) [][]T {
+	if d.dmp.DiffTimeout <= 0 {
+		// Don't risk returning a non-optimal diff if we have unlimited time.
+		return nil
+	}
+	longer, shorter := a, b
+	if len(a) <= len(b) {
+		longer, shorter = b, a
+	}
+	if len(longer) < 4 || len(shorter)*2 < len(longer) {
+		return nil // Pointless.
+	}
+
+	// First check if the second quarter is the seed for a half-match.
+	hm1 := d.halfMatchI(longer, shorter, (len(longer)+3)/4)
+	// Check again based on the third quarter.
+	hm2 := d.halfMatchI(longer, shorter, (len(longer)+1)/2)
+	var hm [][]T
+	switch {
+	case hm1 == nil && hm2 == nil:
+		return nil
+	case hm2 == nil:
+		hm = hm1
+	case hm1 == nil:
+		hm = hm2
+	case len(hm1[4]) > len(hm2[4]):
+		// Both matched.  Select the longest.
+		hm = hm1
+	default:
+		hm = hm2
+	}
+
+	if len(a) > len(b) {
+		return hm
+	}
+	return [][]T{hm[2], hm[3], hm[0], hm[1], hm[4]}
+}
+
+// halfMatchI looks for a run of shorter that is at least half the length of
+// longer and contains the quarter of longer starting at i.  It returns the
+// prefix of longer, the suffix of longer, the prefix of shorter, the suffix
+// of shorter and the common middle, or nil.
+func (d *differ[T]) halfMatchI(longer, shorter []T, i int) [][]T {
+	// Start with a 1/4 length seed at position i.
+	seed := longer[i : i+len(longer)/4]
+	var bestCommon, bestLongerA, bestLongerB, bestShorterA, bestShorterB []T
+	for j := index(shorter, seed, 0); j != -1; j = index(shorter, seed, j+1) {
+		prefixLength := commonPrefix(longer[i:], shorter[j:])
+		suffixLength := commonSuffix(longer[:i], shorter[:j])
+		if len(bestCommon) < suffixLength+prefixLength {
+			bestCommon = shorter[j-suffixLength : j+prefixLength]
+			bestLongerA = longer[:i-suffixLength]
+			bestLongerB = longer[i+prefixLength:]
+			bestShorterA = shorter[:j-suffixLength]
+			bestShorterB = shorter[j+prefixLength:]
+		}
+	}
+	if len(bestCommon)*2 >= len(longer) {
+		return [][]T{bestLongerA, bestLongerB, bestShorterA, bestShorterB, bestCommon}
+	}
+	return nil
+}
+
+func sliceEqual[T comparable](a, b []T) bool {
+	return len(a) == len(b) && commonPrefix(a, b) == len(a)
+}
+
+// index returns the index of the first occurrence of sub in s at or after
+// from, or -1.
+func index[T comparable](s, sub []T, from int) int {
+	for i := from; i <= len(s)-len(sub); i++ {
+		if sliceEqual(s[i:i+len(sub)], sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+// commonPrefix returns the length of the common prefix of a and b.
+func commonPrefix[T comparable](a, b []T) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// commonSuffix returns the length of the common suffix of a and b.
+func commonSuffix[T comparable](a, b []T) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[len(a)-n-1] == b[len(b)-n-1] {
+		n++
+	}
+	return n
+}
+
+// mergeEdits drops empty edits and merges neighbours of the same type,
+// putting the deletions of a run of changes before its insertions.
+func mergeEdits[T any](edits []Edit[T]) []Edit[T] {
+	merged := []Edit[T]{}
+	var deleted, inserted []T
+	add := func(op int8, items []T) {
+		if len(items) == 0 {
+			return
+		}
+		if n := len(merged); n > 0 && merged[n-1].Type == op {
+			merged[n-1].Items = joinItems(merged[n-1].Items, items)
+			return
+		}
+		merged = append(merged, Edit[T]{op, items})
+	}
+	for _, e := range edits {
+		switch e.Type {
+		case DiffDelete:
+			deleted = joinItems(deleted, e.Items)
+		case DiffInsert:
+			inserted = joinItems(inserted, e.Items)
+		default:
+			add(DiffDelete, deleted)
+			add(DiffInsert, inserted)
+			deleted, inserted = nil, nil
+			add(DiffEqual, e.Items)
+		}
+	}
+	add(DiffDelete, deleted)
+	add(DiffInsert, inserted)
+	return merged
+}
+
+// joinItems returns x followed by y.  Neighbouring parts of one slice are
+// joined without copying; otherwise the result is a new slice, never one
+// that shares memory with the input.
+func joinItems[T any](x, y []T) []T {
+	switch {
+	case len(x) == 0:
+		return y
+	case len(y) == 0:
+		return x
+	case cap(x) > len(x) && &x[:len(x)+1][len(x)] == &y[0]:
+		return x[:len(x)+len(y)]
+	}
+	return append(append(make([]T, 0, len(x)+len(y)), x...), y...)
+}
@@ -0,0 +1,100 @@
+package diffmatchpatch
+
+import (
+	"github.com/bmizerany/assert"
+	"strings"
+	"testing"
+)
+
+func Test_diffSlices(t *testing.T) {
+	dmp := createDMP()
+	// Null case.
+	assert.Equal(t, []Edit[int]{}, DiffSlices(&dmp, []int{}, []int{}))
+
+	assert.Equal(t, []Edit[int]{{DiffEqual, []int{1, 2, 3}}}, DiffSlices(&dmp, []int{1, 2, 3}, []int{1, 2, 3}))
+
+	assert.Equal(t, []Edit[int]{
+		{DiffEqual, []int{1}},
+		{DiffDelete, []int{2}},
+		{DiffInsert, []int{4}},
+		{DiffEqual, []int{3}},
+	}, DiffSlices(&dmp, []int{1, 2, 3}, []int{1, 4, 3}))
+
+	assert.Equal(t, []Edit[string]{
+		{DiffInsert, []string{"new"}},
+		{DiffEqual, []string{"a", "b"}},
+		{DiffDelete, []string{"c"}},
+	}, DiffSlices(&dmp, []string{"a", "b", "c"}, []string{"new", "a", "b"}))
+
+	// Items that no rune could stand for.
+	type row struct {
+		id   int
+		name string
+	}
+	var a, b []row
+	for i := 0; i < 0x110000+10; i += 0x10000 {
+		a = append(a, row{i, "x"})
+		b = append(b, row{i, "x"})
+	}
+	b[3].name = "y"
+	edits := DiffSlices(&dmp, a, b)
+	assert.Equal(t, 4, len(edits))
+	assert.Equal(t, []row{a[3]}, edits[1].Items)
+	assert.Equal(t, []row{b[3]}, edits[2].Items)
+}
+
+func Test_diffSlicesFunc(t *testing.T) {
+	dmp := createDMP()
+	a := []string{"One", "two", "Three", "four"}
+	b := []string{"one", "TWO", "five", "FOUR"}
+	edits := DiffSlicesFunc(&dmp, a, b, strings.EqualFold)
+	assert.Equal(t, []Edit[string]{
+		{DiffEqual, []string{"One", "two"}},
+		{DiffDelete, []string{"Three"}},
+		{DiffInsert, []string{"five"}},
+		{DiffEqual, []string{"four"}},
+	}, edits)
+
+	// Items that aren't comparable.
+	equal := func(x, y []int) bool { return len(x) == len(y) && sliceEqual(x, y) }
+	assert.Equal(t, []Edit[[]int]{
+		{DiffDelete, [][]int{{1}}},
+		{DiffEqual, [][]int{{2, 3}}},
+		{DiffInsert, [][]int{{4}}},
+	}, DiffSlicesFunc(&dmp, [][]int{{1}, {2, 3}}, [][]int{{2, 3}, {4}}, equal))
+}
+
+func Test_mergeEdits(t *testing.T) {
+	items := []int{1, 2, 3, 4}
+	edits := mergeEdits([]Edit[int]{
+		{DiffEqual, items[:1]},
+		{DiffEqual, items[1:2]},
+		{DiffInsert, []int{5}},
+		{DiffDelete, items[2:3]},
+		{DiffInsert, []int{6}},
+		{DiffDelete, nil},
+		{DiffEqual, items[3:]},
+	})
+	assert.Equal(t, []Edit[int]{
+		{DiffEqual, []int{1, 2}},
+		{DiffDelete, []int{3}},
+		{DiffInsert, []int{5, 6}},
+		{DiffEqual, []int{4}},
+	}, edits)
+	// Neighbouring parts of one slice are joined in place.
+	assert.Equal(t, &items[0], &edits[0].Items[0])
+}
+
+func Test_diffSlicesSettings(t *testing.T) {
+	a := strings.Split("int a()|{|x();|}||int b()|{|y();|}", "|")
+	b := strings.Split("int b()|{|y();|}||int a()|{|z();|}", "|")
+	dmp := createDMP()
+	assert.Equal(t, 12, len(DiffSlices(&dmp, a, b)))
+	dmp.DiffAlgorithm = AlgorithmPatience
+	assert.Equal(t, []Edit[string]{
+		{DiffDelete, []string{"int a()", "{", "x();", "}", ""}},
+		{DiffEqual, []string{"int b()", "{", "y();"}},
+		{DiffInsert, []string{"}", "", "int a()", "{", "z();"}},
+		{DiffEqual, []string{"}"}},
+	}, DiffSlices(&dmp, a, b))
+}
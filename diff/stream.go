@@ -0,0 +1,302 @@
+package diffmatchpatch
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// streamWindow is the number of bytes of each input DiffReaders holds at a
+// time unless DiffMaxMemory asks for less.
+const streamWindow = 4 << 20
+
+// DiffWriter receives the diffs found by DiffReaders as they are found.
+type DiffWriter interface {
+	WriteDiff(d Diff) error
+}
+
+// DiffWriterFunc adapts a function to a DiffWriter.
+type DiffWriterFunc func(d Diff) error
+
+// WriteDiff calls f(d).
+func (f DiffWriterFunc) WriteDiff(d Diff) error {
+	return f(d)
+}
+
+// DiffReaders diffs two streams line by line without reading them into
+// memory as a whole and passes the diffs to w as it goes.  It holds a
+// window of lines of each input, diffs them up to the last line that
+// occurs exactly once in both windows and moves on from there.  The texts
+// of the diffs consist of whole lines, except that lines longer than a
+// window are split between characters into pieces of about a window;
+// consecutive diffs may be of the same type.  A window is a quarter of
+// DiffMaxMemory or 4 MB, whichever is less.
+//
+// Where the windows have no line in common, one of them is passed over as
+// deleted or inserted and the other kept.  The one passed over is that of
+// the stream whose lines were passed over in the other stream, which has
+// to catch up, or if there is no telling, the second, then the first for
+// twice as many windows, and so on, so that the streams synchronise again
+// after an insertion or deletion of any size, although lines of the other
+// stream may be passed over too.
+//
+// DiffReaders returns the first error from reading, from w or of ctx.
+func (dmp *DiffMatchPatch) DiffReaders(ctx context.Context, r1, r2 io.Reader, w DiffWriter) error {
+	window := streamWindow
+	if dmp.DiffMaxMemory > 0 && dmp.DiffMaxMemory/4 < window {
+		window = dmp.DiffMaxMemory / 4
+	}
+	win1 := &lineWindow{r: bufio.NewReader(r1)}
+	win2 := &lineWindow{r: bufio.NewReader(r2)}
+	skip := newSkipper(dmp, window)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := win1.fill(window); err != nil {
+			return err
+		}
+		if err := win2.fill(window); err != nil {
+			return err
+		}
+		if len(win1.lines) == 0 && len(win2.lines) == 0 {
+			return nil
+		}
+
+		numbers := map[string]int{}
+		ids1, ids2 := dmp.numberLines(win1.lines, numbers), dmp.numberLines(win2.lines, numbers)
+		final := win1.eof && win2.eof
+		anchors := [][2]int(nil)
+		if !final {
+			// The lines after the last anchor may match lines that haven't
+			// been read yet.
+			anchors = patienceAnchors(ids1, ids2)
+			if len(anchors) != 0 {
+				last := anchors[len(anchors)-1]
+				ids1, ids2 = ids1[:last[0]+1], ids2[:last[1]+1]
+			}
+		}
+		d := &differ[int]{dmp: dmp, ctx: ctx, deadline: dmp.deadline()}
+		edits := d.main(ids1, ids2, false)
+		passed := int8(DiffEqual)
+		if !final && len(anchors) == 0 {
+			// Nothing to synchronise on, hold back what follows the last
+			// equality.
+			k := len(edits) - 1
+			for k >= 0 && edits[k].Type != DiffEqual {
+				k--
+			}
+			switch {
+			case k >= 0:
+				edits = edits[:k+1]
+			case len(ids1) != 0 && len(ids2) != 0:
+				// Nothing in common, pass over one window only.
+				passed = skip.choose(win1.lines, win2.lines)
+			}
+		}
+		switch passed {
+		case DiffInsert:
+			edits = []Edit[int]{{DiffInsert, ids2}}
+			skip.passed2.add(win2.lines)
+		case DiffDelete:
+			edits = []Edit[int]{{DiffDelete, ids1}}
+			skip.passed1.add(win1.lines)
+		default:
+			skip.reset()
+		}
+
+		n1, n2 := 0, 0
+		for _, e := range edits {
+			var text string
+			switch e.Type {
+			case DiffInsert:
+				text = strings.Join(win2.lines[n2:n2+len(e.Items)], "")
+				n2 += len(e.Items)
+			default:
+				text = strings.Join(win1.lines[n1:n1+len(e.Items)], "")
+				n1 += len(e.Items)
+				if e.Type == DiffEqual {
+					n2 += len(e.Items)
+				}
+			}
+			if err := w.WriteDiff(Diff{e.Type, text}); err != nil {
+				return err
+			}
+		}
+		win1.consume(n1)
+		win2.consume(n2)
+	}
+}
+
+// skipper decides which window DiffReaders passes over while the windows
+// have nothing in common.
+type skipper struct {
+	// The window passed over when there is no telling, for how many more
+	// windows and for how many the next time.
+	side       int8
+	left, next int
+	// Samples of the lines passed over in each stream since the streams
+	// were last in step.
+	passed1, passed2 *lineSample
+}
+
+func newSkipper(dmp *DiffMatchPatch, window int) *skipper {
+	// A sample takes no more memory than a window.
+	limit := window / numberSize
+	s := &skipper{
+		passed1: &lineSample{dmp: dmp, limit: limit},
+		passed2: &lineSample{dmp: dmp, limit: limit},
+	}
+	s.reset()
+	return s
+}
+
+// choose returns DiffDelete to pass over the window of the first stream and
+// DiffInsert for that of the second.
+func (s *skipper) choose(lines1, lines2 []string) int8 {
+	// The stream with more lines the other stream passed over is behind.
+	behind1, behind2 := s.passed2.count(lines1), s.passed1.count(lines2)
+	switch {
+	case behind1 > behind2:
+		return DiffDelete
+	case behind2 > behind1:
+		return DiffInsert
+	}
+	side := s.side
+	if s.left--; s.left == 0 {
+		s.side, s.left, s.next = -s.side, s.next, 2*s.next
+	}
+	return side
+}
+
+// reset forgets what was passed over once the streams are in step.
+func (s *skipper) reset() {
+	s.side, s.left, s.next = DiffInsert, 1, 2
+	s.passed1.reset()
+	s.passed2.reset()
+}
+
+// lineSample holds the hashes of the lines added to it that are multiples
+// of a modulus, which doubles whenever there are more than limit, so that
+// the sample covers every line added in bounded memory.
+type lineSample struct {
+	dmp    *DiffMatchPatch
+	limit  int
+	hashes map[uint32]bool
+	mod    uint32
+}
+
+func (s *lineSample) reset() {
+	if s.hashes == nil || len(s.hashes) != 0 {
+		s.hashes = map[uint32]bool{}
+	}
+	s.mod = 1
+}
+
+func (s *lineSample) add(lines []string) {
+	for _, line := range lines {
+		if h := fnv32(s.dmp.lineKey(line)); h%s.mod == 0 {
+			s.hashes[h] = true
+		}
+		for len(s.hashes) > s.limit && s.mod < 1<<31 {
+			s.mod *= 2
+			for h := range s.hashes {
+				if h%s.mod != 0 {
+					delete(s.hashes, h)
+				}
+			}
+		}
+	}
+}
+
+// count returns how many of lines are in the sample.
+func (s *lineSample) count(lines []string) int {
+	n := 0
+	for _, line := range lines {
+		if h := fnv32(s.dmp.lineKey(line)); h%s.mod == 0 && s.hashes[h] {
+			n++
+		}
+	}
+	return n
+}
+
+// numberLines returns the numbers of lines, giving lines that are equal,
+// once folded if there is a Folder, the same number.
+func (dmp *DiffMatchPatch) numberLines(lines []string, numbers map[string]int) []int {
+	ids := make([]int, len(lines))
+	for i, line := range lines {
+		line = dmp.lineKey(line)
+		id, ok := numbers[line]
+		if !ok {
+			id = len(numbers)
+			numbers[line] = id
+		}
+		ids[i] = id
+	}
+	return ids
+}
+
+// lineWindow holds the lines of a stream that have been read but not yet
+// diffed.
+type lineWindow struct {
+	r     *bufio.Reader
+	lines []string
+	size  int
+	eof   bool
+	rest  []byte // The start of a character cut off a split line.
+}
+
+// fill reads lines until the window holds at least limit bytes, at least
+// one line, or the stream ends.  Lines of limit bytes or more are split
+// after the first limit bytes, or a little more, between characters.
+func (w *lineWindow) fill(limit int) error {
+	for !w.eof && (len(w.lines) == 0 || w.size < limit) {
+		line := w.rest
+		w.rest = nil
+		var err error
+		for {
+			var frag []byte
+			frag, err = w.r.ReadSlice('\n')
+			line = append(line, frag...)
+			if err != bufio.ErrBufferFull {
+				break
+			}
+			if len(line) >= limit {
+				err = nil
+				// Keep the bytes of an incomplete character for the
+				// next piece.
+				for i := len(line) - 1; i >= 0 && i >= len(line)-utf8.UTFMax; i-- {
+					if utf8.RuneStart(line[i]) {
+						if !utf8.FullRune(line[i:]) {
+							w.rest = append([]byte(nil), line[i:]...)
+							line = line[:i]
+						}
+						break
+					}
+				}
+				break
+			}
+		}
+		if len(line) != 0 {
+			w.lines = append(w.lines, string(line))
+			w.size += len(line)
+		}
+		if err == io.EOF {
+			w.eof = true
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// consume drops the first n lines.
+func (w *lineWindow) consume(n int) {
+	for _, line := range w.lines[:n] {
+		w.size -= len(line)
+	}
+	// Copy the rest so that the dropped lines can be freed.
+	w.lines = append([]string(nil), w.lines[n:]...)
+}
@@ -0,0 +1,124 @@
+package diffmatchpatch
+
+import (
+	"context"
+	"errors"
+	"github.com/bmizerany/assert"
+	"io"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// collectDiffs runs DiffReaders over two texts and returns the diffs.
+func collectDiffs(dmp *DiffMatchPatch, text1, text2 string) ([]Diff, error) {
+	diffs := []Diff{}
+	err := dmp.DiffReaders(context.Background(), strings.NewReader(text1), strings.NewReader(text2),
+		DiffWriterFunc(func(d Diff) error {
+			diffs = append(diffs, d)
+			return nil
+		}))
+	return diffs, err
+}
+
+func Test_diffReaders(t *testing.T) {
+	dmp := createDMP()
+	diffs, err := collectDiffs(&dmp, "a\nb\nc\n", "a\nB\nc\nd")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []Diff{
+		Diff{DiffEqual, "a\n"},
+		Diff{DiffDelete, "b\n"},
+		Diff{DiffInsert, "B\n"},
+		Diff{DiffEqual, "c\n"},
+		Diff{DiffInsert, "d"}}, diffs)
+
+	diffs, err = collectDiffs(&dmp, "", "")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []Diff{}, diffs)
+
+	// Windows of a few lines.
+	dmp.DiffMaxMemory = 400
+	text1 := logText(500, 37, false)
+	text2 := logText(500, 37, true)
+	text2 = strings.Replace(text2, "000100 INFO request 700 served\n", "", 1)
+	text2 += "extra\n"
+	diffs, err = collectDiffs(&dmp, text1, text2)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{text1, text2}, diffRebuildtexts(diffs))
+	// The same as a line diff in memory.
+	dmp2 := createDMP()
+	chars1, chars2, lineArray := dmp2.DiffLinesToChars(text1, text2)
+	expected := dmp2.DiffCharsToLines(dmp2.DiffMain(chars1, chars2, false), lineArray)
+	var merged []Diff
+	for _, aDiff := range diffs {
+		if n := len(merged); n > 0 && merged[n-1].Type == aDiff.Type {
+			merged[n-1].Text += aDiff.Text
+		} else {
+			merged = append(merged, aDiff)
+		}
+	}
+	assert.Equal(t, expected, merged)
+
+	// Nothing in common.
+	diffs, err = collectDiffs(&dmp, logText(100, 1, false), logText(100, 1, true))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{logText(100, 1, false), logText(100, 1, true)}, diffRebuildtexts(diffs))
+
+	// An insertion or deletion larger than a window: once it is passed
+	// over the rest is equal.
+	dmp.DiffMaxMemory = 4000
+	text1 = logText(500, 1, false)
+	text2 = strings.Replace(logText(300, 1, true), "WARN", "NEW", -1) + text1
+	for _, texts := range [][2]string{{text1, text2}, {text2, text1}} {
+		diffs, err = collectDiffs(&dmp, texts[0], texts[1])
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []string{texts[0], texts[1]}, diffRebuildtexts(diffs))
+		equal := 0
+		for k := len(diffs) - 1; k >= 0 && diffs[k].Type == DiffEqual; k-- {
+			equal += len(diffs[k].Text)
+		}
+		assert.T(t, equal > len(text1)/3, equal)
+	}
+
+	// Lines longer than a window are split.
+	long1 := strings.Repeat("x", 5000) + "\n" + strings.Repeat("y", 5000) + "\n"
+	long2 := strings.Repeat("x", 5000) + "\n" + strings.Repeat("y", 4000) + "z" + strings.Repeat("y", 999) + "\n"
+	diffs, err = collectDiffs(&dmp, long1, long2)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{long1, long2}, diffRebuildtexts(diffs))
+	assert.Equal(t, Diff{DiffEqual, strings.Repeat("x", 4096)}, diffs[0])
+	assert.Equal(t, Diff{DiffEqual, strings.Repeat("y", 904) + "\n"}, diffs[len(diffs)-1])
+
+	// Not inside a character.
+	long1 = strings.Repeat("日本🐱", 2000) + "\n"
+	long2 = strings.Replace(long1, "🐱", "猫", 1000)
+	diffs, err = collectDiffs(&dmp, long1, long2)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{long1, long2}, diffRebuildtexts(diffs))
+	for _, aDiff := range diffs {
+		assert.T(t, utf8.ValidString(aDiff.Text), aDiff)
+	}
+}
+
+type errReader struct{}
+
+func (errReader) Read(p []byte) (int, error) {
+	return 0, io.ErrUnexpectedEOF
+}
+
+func Test_diffReadersErrors(t *testing.T) {
+	dmp := createDMP()
+	discard := DiffWriterFunc(func(Diff) error { return nil })
+	err := dmp.DiffReaders(context.Background(), strings.NewReader("a\n"), errReader{}, discard)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	stop := errors.New("stop")
+	err = dmp.DiffReaders(context.Background(), strings.NewReader("a\n"), strings.NewReader("b\n"),
+		DiffWriterFunc(func(Diff) error { return stop }))
+	assert.Equal(t, stop, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = dmp.DiffReaders(ctx, strings.NewReader("a\n"), strings.NewReader("b\n"), discard)
+	assert.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,335 @@
+package diffmatchpatch
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Color is a terminal colour.  The zero value leaves the terminal's
+// default colour in place.
+type Color struct {
+	kind  colorKind
+	value uint32
+}
+
+type colorKind uint8
+
+const (
+	colorNone colorKind = iota
+	color16
+	color256
+	colorRGB
+)
+
+// Color16 returns one of the 16 basic ANSI colours: 0-7 are the normal and
+// 8-15 the bright variants.
+func Color16(n uint8) Color {
+	return Color{color16, uint32(n & 15)}
+}
+
+// Color256 returns a colour of the xterm 256 colour palette.
+func Color256(n uint8) Color {
+	return Color{color256, uint32(n)}
+}
+
+// RGB returns a 24-bit colour for terminals that support truecolour.
+func RGB(r, g, b uint8) Color {
+	return Color{colorRGB, uint32(r)<<16 | uint32(g)<<8 | uint32(b)}
+}
+
+// sgr appends the SGR parameters that select c; base is 30 for the
+// foreground and 40 for the background.
+func (c Color) sgr(params []string, base int) []string {
+	switch c.kind {
+	case color16:
+		if c.value < 8 {
+			return append(params, strconv.Itoa(base+int(c.value)))
+		}
+		return append(params, strconv.Itoa(base+60+int(c.value)-8))
+	case color256:
+		return append(params, strconv.Itoa(base+8), "5", strconv.Itoa(int(c.value)))
+	case colorRGB:
+		return append(params, strconv.Itoa(base+8), "2",
+			strconv.Itoa(int(c.value>>16)), strconv.Itoa(int(c.value>>8&0xff)), strconv.Itoa(int(c.value&0xff)))
+	}
+	return params
+}
+
+// Style is the appearance of a piece of text.
+type Style struct {
+	Fg, Bg  Color
+	Bold    bool
+	Reverse bool
+}
+
+func (s Style) sequence() string {
+	var params []string
+	if s.Bold {
+		params = append(params, "1")
+	}
+	if s.Reverse {
+		params = append(params, "7")
+	}
+	params = s.Fg.sgr(params, 30)
+	params = s.Bg.sgr(params, 40)
+	if len(params) == 0 {
+		return ""
+	}
+	return "\x1b[" + strings.Join(params, ";") + "m"
+}
+
+// Palette holds the styles a TerminalRenderer uses.
+type Palette struct {
+	Insert, Delete, Equal Style
+	// InsertHighlight and DeleteHighlight mark the characters that changed
+	// within a changed line when rendering lines.
+	InsertHighlight, DeleteHighlight Style
+	// Whitespace is used for the markers of visualised whitespace.
+	Whitespace Style
+}
+
+var (
+	// DefaultPalette uses the basic colours every terminal supports.
+	DefaultPalette = Palette{
+		Insert:          Style{Fg: Color16(2)},
+		Delete:          Style{Fg: Color16(1)},
+		InsertHighlight: Style{Fg: Color16(2), Reverse: true},
+		DeleteHighlight: Style{Fg: Color16(1), Reverse: true},
+		Whitespace:      Style{Fg: Color16(8)},
+	}
+	// Palette256 uses the xterm 256 colour palette.
+	Palette256 = Palette{
+		Insert:          Style{Fg: Color256(34)},
+		Delete:          Style{Fg: Color256(160)},
+		InsertHighlight: Style{Fg: Color256(231), Bg: Color256(28)},
+		DeleteHighlight: Style{Fg: Color256(231), Bg: Color256(124)},
+		Whitespace:      Style{Fg: Color256(244)},
+	}
+	// PaletteTrueColor uses 24-bit colours.
+	PaletteTrueColor = Palette{
+		Insert:          Style{Fg: RGB(0x1a, 0x7f, 0x37)},
+		Delete:          Style{Fg: RGB(0xcf, 0x22, 0x2e)},
+		InsertHighlight: Style{Fg: RGB(0x1a, 0x7f, 0x37), Bg: RGB(0xab, 0xf2, 0xbc)},
+		DeleteHighlight: Style{Fg: RGB(0xcf, 0x22, 0x2e), Bg: RGB(0xff, 0xc1, 0xc0)},
+		Whitespace:      Style{Fg: RGB(0x8c, 0x95, 0x9f)},
+	}
+)
+
+// TerminalRenderer writes diffs for display in a terminal.
+type TerminalRenderer struct {
+	Palette Palette
+	// Lines renders whole lines prefixed with "-", "+" or " " as in a
+	// unified diff, highlighting the characters that changed within each
+	// changed line.  Otherwise the diff is rendered inline.
+	Lines bool
+	// ShowWhitespace makes tabs, trailing spaces and carriage returns
+	// visible.
+	ShowWhitespace bool
+	// NoColor disables escape sequences.  Inline changes are then marked
+	// [-like this-] and {+like this+}.
+	NoColor bool
+}
+
+// NewTerminalRenderer returns a renderer with the default palette that
+// writes colour only if w is a terminal and NO_COLOR is not set.
+func NewTerminalRenderer(w io.Writer) *TerminalRenderer {
+	return &TerminalRenderer{Palette: DefaultPalette, NoColor: !ColorEnabled(w)}
+}
+
+// ColorEnabled reports whether colour should be written to w: w has to be
+// a terminal and the NO_COLOR environment variable must be unset or empty.
+// See https://no-color.org.
+func ColorEnabled(w io.Writer) bool {
+	if len(os.Getenv("NO_COLOR")) != 0 {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// termSegment is a piece of a line with the operation that produced it.
+type termSegment struct {
+	op   int8
+	text string
+}
+
+// Render writes diffs to w.  It stops at the first write error and returns
+// it.
+func (r *TerminalRenderer) Render(w io.Writer, diffs []Diff) error {
+	ew := &errWriter{w: w}
+	if r.Lines {
+		r.renderLines(ew, diffs)
+		return ew.err
+	}
+	for i, aDiff := range diffs {
+		atLineEnd := i == len(diffs)-1 || strings.HasPrefix(diffs[i+1].Text, "\n") ||
+			strings.HasPrefix(diffs[i+1].Text, "\r")
+		switch aDiff.Type {
+		case DiffInsert:
+			if r.NoColor {
+				ew.WriteString("{+")
+			}
+			r.writeText(ew, r.Palette.Insert, aDiff.Text, atLineEnd)
+			if r.NoColor {
+				ew.WriteString("+}")
+			}
+		case DiffDelete:
+			if r.NoColor {
+				ew.WriteString("[-")
+			}
+			r.writeText(ew, r.Palette.Delete, aDiff.Text, atLineEnd)
+			if r.NoColor {
+				ew.WriteString("-]")
+			}
+		case DiffEqual:
+			r.writeText(ew, r.Palette.Equal, aDiff.Text, atLineEnd)
+		}
+	}
+	return ew.err
+}
+
+// renderLines writes the line layout.  Changed lines are collected until
+// the next line break inside an equality, then written deletions first.
+func (r *TerminalRenderer) renderLines(w *errWriter, diffs []Diff) {
+	var left, right [][]termSegment
+	var curLeft, curRight []termSegment
+	flush := func() {
+		if len(left) == 1 && len(right) == 1 && !lineChanged(left[0]) && !lineChanged(right[0]) {
+			r.writeLine(w, DiffEqual, left[0])
+		} else {
+			for _, line := range left {
+				r.writeLine(w, DiffDelete, line)
+			}
+			for _, line := range right {
+				r.writeLine(w, DiffInsert, line)
+			}
+		}
+		left, right = nil, nil
+	}
+	for _, aDiff := range diffs {
+		for i, line := range strings.Split(aDiff.Text, "\n") {
+			if i > 0 {
+				if aDiff.Type != DiffInsert {
+					left = append(left, curLeft)
+					curLeft = nil
+				}
+				if aDiff.Type != DiffDelete {
+					right = append(right, curRight)
+					curRight = nil
+				}
+				if aDiff.Type == DiffEqual {
+					flush()
+				}
+			}
+			if len(line) == 0 {
+				continue
+			}
+			segment := termSegment{aDiff.Type, line}
+			if aDiff.Type != DiffInsert {
+				curLeft = append(curLeft, segment)
+			}
+			if aDiff.Type != DiffDelete {
+				curRight = append(curRight, segment)
+			}
+		}
+	}
+	if len(curLeft) != 0 {
+		left = append(left, curLeft)
+	}
+	if len(curRight) != 0 {
+		right = append(right, curRight)
+	}
+	flush()
+}
+
+func lineChanged(line []termSegment) bool {
+	for _, segment := range line {
+		if segment.op != DiffEqual {
+			return true
+		}
+	}
+	return false
+}
+
+// writeLine writes one line of the line layout; op selects its prefix and
+// style.
+func (r *TerminalRenderer) writeLine(w *errWriter, op int8, line []termSegment) {
+	style, highlight, prefix := r.Palette.Equal, r.Palette.Equal, " "
+	switch op {
+	case DiffInsert:
+		style, highlight, prefix = r.Palette.Insert, r.Palette.InsertHighlight, "+"
+	case DiffDelete:
+		style, highlight, prefix = r.Palette.Delete, r.Palette.DeleteHighlight, "-"
+	}
+	r.writeText(w, style, prefix, false)
+	for i, segment := range line {
+		s := style
+		if segment.op != DiffEqual {
+			s = highlight
+		}
+		r.writeText(w, s, segment.text, i == len(line)-1)
+	}
+	w.WriteString("\n")
+}
+
+// writeText writes text in style.  atLineEnd tells whether text is
+// followed by the end of a line, which makes its trailing spaces visible
+// when whitespace is shown.
+func (r *TerminalRenderer) writeText(w *errWriter, style Style, text string, atLineEnd bool) {
+	if len(text) == 0 {
+		return
+	}
+	start := ""
+	if !r.NoColor {
+		start = style.sequence()
+	}
+	w.WriteString(start)
+	if r.ShowWhitespace {
+		r.writeWhitespace(w, start, text, atLineEnd)
+	} else {
+		w.WriteString(text)
+	}
+	if len(start) != 0 {
+		w.WriteString("\x1b[0m")
+	}
+}
+
+// writeWhitespace writes text with tabs, carriage returns and trailing
+// spaces replaced by markers in the whitespace style, restoring style
+// afterwards.
+func (r *TerminalRenderer) writeWhitespace(w *errWriter, style, text string, atLineEnd bool) {
+	marker := func(m string) {
+		if r.NoColor {
+			w.WriteString(m)
+			return
+		}
+		w.WriteString("\x1b[0m" + r.Palette.Whitespace.sequence() + m + "\x1b[0m" + style)
+	}
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; c {
+		case '\t':
+			marker("→")
+		case '\r':
+			marker("␍")
+		case ' ':
+			j := i
+			for j < len(text) && text[j] == ' ' {
+				j++
+			}
+			if (j == len(text) && atLineEnd) || (j < len(text) && (text[j] == '\n' || text[j] == '\r')) {
+				marker(strings.Repeat("·", j-i))
+			} else {
+				w.WriteString(text[i:j])
+			}
+			i = j - 1
+		default:
+			w.WriteString(text[i : i+1])
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package diffmatchpatch
+
+import (
+	"bytes"
+	"github.com/bmizerany/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func Test_diffPrettyText(t *testing.T) {
+	dmp := createDMP()
+	diffs := []Diff{
+		Diff{DiffEqual, "a\n"},
+		Diff{DiffDelete, "<B>b</B>"},
+		Diff{DiffInsert, "c&d"}}
+	assert.Equal(t, "a\n\x1b[31m<B>b</B>\x1b[0m\x1b[32mc&d\x1b[0m", dmp.DiffPrettyText(diffs))
+}
+
+func Test_terminalColors(t *testing.T) {
+	assert.Equal(t, "\x1b[1;91;40m", Style{Fg: Color16(9), Bg: Color16(0), Bold: true}.sequence())
+	assert.Equal(t, "\x1b[38;5;203m", Style{Fg: Color256(203)}.sequence())
+	assert.Equal(t, "\x1b[7;38;2;1;2;3;48;2;255;128;0m",
+		Style{Fg: RGB(1, 2, 3), Bg: RGB(255, 128, 0), Reverse: true}.sequence())
+	assert.Equal(t, "", Style{}.sequence())
+}
+
+func Test_terminalRendererLines(t *testing.T) {
+	diffs := []Diff{
+		Diff{DiffEqual, "same\nab"},
+		Diff{DiffDelete, "c"},
+		Diff{DiffInsert, "x"},
+		Diff{DiffEqual, "d\nend"}}
+
+	var buff bytes.Buffer
+	r := &TerminalRenderer{Palette: DefaultPalette, Lines: true, NoColor: true}
+	assert.Equal(t, nil, r.Render(&buff, diffs))
+	assert.Equal(t, " same\n-abcd\n+abxd\n end\n", buff.String())
+
+	// Changed characters are highlighted within the changed lines.
+	buff.Reset()
+	r.NoColor = false
+	assert.Equal(t, nil, r.Render(&buff, []Diff{Diff{DiffEqual, "ab"}, Diff{DiffDelete, "c"}}))
+	assert.Equal(t, "\x1b[31m-\x1b[0m\x1b[31mab\x1b[0m\x1b[7;31mc\x1b[0m\n"+
+		"\x1b[32m+\x1b[0m\x1b[32mab\x1b[0m\n", buff.String())
+}
+
+func Test_terminalRendererWhitespace(t *testing.T) {
+	var buff bytes.Buffer
+	r := &TerminalRenderer{ShowWhitespace: true, NoColor: true}
+	assert.Equal(t, nil, r.Render(&buff, []Diff{
+		Diff{DiffEqual, "a\tb  \r\nc d"},
+		Diff{DiffInsert, "  "}}))
+	assert.Equal(t, "a→b··␍\nc d{+··+}", buff.String())
+
+	buff.Reset()
+	r = &TerminalRenderer{Palette: DefaultPalette, ShowWhitespace: true}
+	assert.Equal(t, nil, r.Render(&buff, []Diff{Diff{DiffInsert, "x\t"}}))
+	assert.Equal(t, "\x1b[32mx\x1b[0m\x1b[90m→\x1b[0m\x1b[32m\x1b[0m", buff.String())
+}
+
+func Test_colorEnabled(t *testing.T) {
+	var buff bytes.Buffer
+	assert.Equal(t, false, ColorEnabled(&buff))
+
+	f, err := ioutil.TempFile("", "dmp")
+	assert.Equal(t, nil, err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+	assert.Equal(t, false, ColorEnabled(f))
+	assert.Equal(t, true, NewTerminalRenderer(f).NoColor)
+
+	t.Setenv("NO_COLOR", "1")
+	assert.Equal(t, false, ColorEnabled(os.Stdout))
+}
@@ -0,0 +1,591 @@
+package diffmatchpatch
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FileDiff is the change to one file in a unified diff or git patch.
+type FileDiff struct {
+	// OldName and NewName are the paths of the file before and after the
+	// change.  The a/ and b/ prefixes of git patches are removed; other
+	// paths are kept as they appear in the patch.  A file that is created
+	// has no OldName, a file that is deleted has no NewName.
+	OldName, NewName string
+	// OldMode and NewMode are git file modes such as 0100644, or 0 if the
+	// patch doesn't give them.
+	OldMode, NewMode uint32
+	// IsNew, IsDelete, IsRename and IsCopy describe the operation.
+	IsNew, IsDelete, IsRename, IsCopy bool
+	// IsGit marks the files of git patches, which start with a
+	// "diff --git" line.
+	IsGit bool
+	// IsBinary marks git binary patches, whose contents are not parsed.
+	IsBinary bool
+	Hunks    []Hunk
+}
+
+// Hunk is one "@@" section of a FileDiff.
+type Hunk struct {
+	// OldStart and NewStart are the 1-based line numbers from the hunk
+	// header; OldLines and NewLines are the number of lines it covers.
+	OldStart, OldLines int
+	NewStart, NewLines int
+	// Section is the text following the header, often a function name.
+	Section string
+	// Diffs holds the lines of the hunk, consecutive lines with the same
+	// operation sharing a Diff.  Every Diff ends with a line break except
+	// at an end of file without one.
+	Diffs []Diff
+}
+
+// HunkError reports the hunks of a file that could not be applied.
+type HunkError struct {
+	File  string
+	Hunks []int // Indices into FileDiff.Hunks.
+}
+
+func (e *HunkError) Error() string {
+	return fmt.Sprintf("diffmatchpatch: %d of the hunks for %s could not be applied", len(e.Hunks), e.File)
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@ ?(.*)$`)
+
+// ParseUnifiedDiff parses the output of diff -u or git diff, which may
+// cover several files.  Lines outside of the file headers and hunks, such
+// as a commit message, are ignored.
+func ParseUnifiedDiff(text string) ([]FileDiff, error) {
+	lines := strings.SplitAfter(text, "\n")
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+
+	var files []FileDiff
+	var fd *FileDiff
+	git := false // Whether fd started with a "diff --git" line.
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r\n")
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			files = append(files, FileDiff{})
+			fd = &files[len(files)-1]
+			git = true
+			fd.IsGit = true
+			fd.OldName, fd.NewName = parseGitNames(line[len("diff --git "):])
+
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			if fd == nil || !git || len(fd.Hunks) != 0 {
+				files = append(files, FileDiff{})
+				fd = &files[len(files)-1]
+				git = false
+			}
+			oldName, err := parseFileName(line[len("--- "):], git, "a/")
+			if err != nil {
+				return files, fmt.Errorf("Line %d: %v", i+1, err)
+			}
+			newName, err := parseFileName(strings.TrimRight(lines[i+1][len("+++ "):], "\r\n"), git, "b/")
+			if err != nil {
+				return files, fmt.Errorf("Line %d: %v", i+2, err)
+			}
+			fd.OldName, fd.NewName = oldName, newName
+			fd.IsNew = fd.IsNew || len(oldName) == 0
+			fd.IsDelete = fd.IsDelete || len(newName) == 0
+			i++
+
+		case strings.HasPrefix(line, "@@ "):
+			if fd == nil {
+				return files, fmt.Errorf("Line %d: hunk before any file header", i+1)
+			}
+			hunk, next, err := parseHunk(lines, i)
+			if err != nil {
+				return files, err
+			}
+			fd.Hunks = append(fd.Hunks, hunk)
+			i = next - 1
+
+		case fd != nil && git && len(fd.Hunks) == 0:
+			if err := parseGitHeader(fd, line); err != nil {
+				return files, fmt.Errorf("Line %d: %v", i+1, err)
+			}
+		}
+	}
+	return files, nil
+}
+
+// parseGitHeader handles an extended header line of a git patch.
+func parseGitHeader(fd *FileDiff, line string) (err error) {
+	mode := func(s string) uint32 {
+		m, e := strconv.ParseUint(strings.TrimSpace(s), 8, 32)
+		if e != nil {
+			err = errors.New("Invalid file mode: " + s)
+		}
+		return uint32(m)
+	}
+	name := func(s string) string {
+		n, e := unquoteName(s)
+		if e != nil {
+			err = e
+		}
+		return n
+	}
+	switch {
+	case strings.HasPrefix(line, "old mode "):
+		fd.OldMode = mode(line[len("old mode "):])
+	case strings.HasPrefix(line, "new mode "):
+		fd.NewMode = mode(line[len("new mode "):])
+	case strings.HasPrefix(line, "deleted file mode "):
+		fd.OldMode = mode(line[len("deleted file mode "):])
+		fd.IsDelete = true
+		fd.NewName = ""
+	case strings.HasPrefix(line, "new file mode "):
+		fd.NewMode = mode(line[len("new file mode "):])
+		fd.IsNew = true
+		fd.OldName = ""
+	case strings.HasPrefix(line, "rename from "):
+		fd.OldName = name(line[len("rename from "):])
+		fd.IsRename = true
+	case strings.HasPrefix(line, "rename to "):
+		fd.NewName = name(line[len("rename to "):])
+		fd.IsRename = true
+	case strings.HasPrefix(line, "copy from "):
+		fd.OldName = name(line[len("copy from "):])
+		fd.IsCopy = true
+	case strings.HasPrefix(line, "copy to "):
+		fd.NewName = name(line[len("copy to "):])
+		fd.IsCopy = true
+	case strings.HasPrefix(line, "index "):
+		// "index <old>..<new> <mode>" gives the mode of unchanged modes.
+		if fields := strings.Fields(line); len(fields) == 3 {
+			fd.OldMode = mode(fields[2])
+			fd.NewMode = fd.OldMode
+		}
+	case strings.HasPrefix(line, "Binary files "), line == "GIT binary patch":
+		fd.IsBinary = true
+	}
+	return err
+}
+
+// parseGitNames splits the "a/old b/new" of a "diff --git" line.  Unquoted
+// names containing spaces are only recognised when both are the same,
+// which is the case unless the file is renamed or copied; those patches
+// name the files again in their extended headers.
+func parseGitNames(s string) (string, string) {
+	if strings.HasPrefix(s, "\"") {
+		if end := closingQuote(s); end > 0 {
+			oldName, err1 := unquoteName(s[:end+1])
+			newName, err2 := unquoteName(strings.TrimLeft(s[end+1:], " "))
+			if err1 == nil && err2 == nil {
+				return strings.TrimPrefix(oldName, "a/"), strings.TrimPrefix(newName, "b/")
+			}
+		}
+		return "", ""
+	}
+	if len(s)%2 == 1 {
+		half := len(s) / 2
+		if s[half] == ' ' && strings.HasPrefix(s, "a/") && s[half+1:half+3] == "b/" && s[2:half] == s[half+3:] {
+			return s[2:half], s[half+3:]
+		}
+	}
+	return "", ""
+}
+
+// closingQuote returns the index of the quote ending the C-style quoted
+// string at the start of s, or -1.
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// unquoteName undoes the C-style quoting git applies to unusual file
+// names.
+func unquoteName(s string) (string, error) {
+	if !strings.HasPrefix(s, "\"") {
+		return s, nil
+	}
+	name, err := strconv.Unquote(s)
+	if err != nil {
+		return "", errors.New("Invalid quoted file name: " + s)
+	}
+	return name, nil
+}
+
+// parseFileName extracts the path from a "---" or "+++" header.
+// /dev/null yields the empty string.
+func parseFileName(s string, git bool, prefix string) (string, error) {
+	if tab := strings.IndexByte(s, '\t'); tab >= 0 {
+		s = s[:tab] // Drop the timestamp.
+	}
+	name, err := unquoteName(s)
+	if err != nil || name == "/dev/null" {
+		return "", err
+	}
+	if git {
+		name = strings.TrimPrefix(name, prefix)
+	}
+	return name, nil
+}
+
+// parseHunk parses the hunk whose header is lines[start] and returns it
+// with the index of the line following it.
+func parseHunk(lines []string, start int) (Hunk, int, error) {
+	m := hunkHeader.FindStringSubmatch(strings.TrimRight(lines[start], "\r\n"))
+	if m == nil {
+		return Hunk{}, 0, fmt.Errorf("Line %d: invalid hunk header: %s", start+1, lines[start])
+	}
+	count := func(s string) int {
+		if len(s) == 0 {
+			return 1
+		}
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	hunk := Hunk{}
+	hunk.OldStart, _ = strconv.Atoi(m[1])
+	hunk.OldLines = count(m[2])
+	hunk.NewStart, _ = strconv.Atoi(m[3])
+	hunk.NewLines = count(m[4])
+	hunk.Section = m[5]
+
+	add := func(op int8, text string) {
+		if n := len(hunk.Diffs); n > 0 && hunk.Diffs[n-1].Type == op {
+			hunk.Diffs[n-1].Text += text
+		} else {
+			hunk.Diffs = append(hunk.Diffs, Diff{op, text})
+		}
+	}
+	oldCount, newCount := hunk.OldLines, hunk.NewLines
+	i := start + 1
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if strings.HasPrefix(line, "\\") {
+			// "\ No newline at end of file" applies to the line before.
+			if n := len(hunk.Diffs); n > 0 {
+				hunk.Diffs[n-1].Text = strings.TrimSuffix(hunk.Diffs[n-1].Text, "\n")
+			}
+			continue
+		}
+		if oldCount == 0 && newCount == 0 {
+			break
+		}
+		if line == "\n" || line == "\r\n" {
+			// A context line whose leading space was stripped.
+			line = " " + line
+		}
+		text := line[1:]
+		if !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		switch line[0] {
+		case ' ':
+			add(DiffEqual, text)
+			oldCount--
+			newCount--
+		case '-':
+			add(DiffDelete, text)
+			oldCount--
+		case '+':
+			add(DiffInsert, text)
+			newCount--
+		default:
+			return hunk, 0, fmt.Errorf("Line %d: unexpected line in hunk: %s", i+1, line)
+		}
+		if oldCount < 0 || newCount < 0 {
+			return hunk, 0, fmt.Errorf("Line %d: hunk is longer than its header says", i+1)
+		}
+	}
+	if oldCount != 0 || newCount != 0 {
+		return hunk, 0, fmt.Errorf("Line %d: hunk is shorter than its header says", i+1)
+	}
+	return hunk, i, nil
+}
+
+// ApplyFileDiff applies the hunks of fd to text.  Each hunk is looked for
+// at its line number, adjusted for the hunks before it, and then at the
+// nearest line where it matches exactly.  Failing that it is applied with
+// PatchApply, which tolerates changes to the context.  The result of each
+// hunk is reported in the returned slice.
+func (dmp *DiffMatchPatch) ApplyFileDiff(fd *FileDiff, text string) (string, []bool) {
+	lines := splitLines(text)
+	results := make([]bool, len(fd.Hunks))
+	offset := 0 // Where the hunks are found relative to their line numbers.
+	for x, hunk := range fd.Hunks {
+		expected := hunk.OldStart - 1 + offset
+		if hunk.OldLines == 0 {
+			// An empty range is given by the line before it.
+			expected++
+		}
+		if expected < 0 {
+			expected = 0
+		} else if expected > len(lines) {
+			expected = len(lines)
+		}
+		oldLines := splitLines(dmp.DiffText1(hunk.Diffs))
+		newLines := splitLines(dmp.DiffText2(hunk.Diffs))
+		if loc := findLines(lines, oldLines, expected); loc != -1 {
+			lines = append(lines[:loc], append(newLines, lines[loc+len(oldLines):]...)...)
+			offset += loc - expected + len(newLines) - len(oldLines)
+			results[x] = true
+			continue
+		}
+
+		// The context has drifted, fall back on fuzzy matching.
+		start := 0
+		for _, line := range lines[:expected] {
+			start += len(line)
+		}
+		patch := NewPatch(start, start, hunk.Diffs)
+		patched, applied := dmp.PatchApply([]Patch{patch}, strings.Join(lines, ""))
+		results[x] = len(applied) > 0
+		for _, ok := range applied {
+			results[x] = results[x] && ok
+		}
+		if results[x] {
+			lines = splitLines(patched)
+			offset += len(newLines) - len(oldLines)
+		}
+	}
+	return strings.Join(lines, ""), results
+}
+
+// splitLines splits text after each line break.
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// findLines returns the index of the occurrence of block in lines closest
+// to expected, or -1.
+func findLines(lines, block []string, expected int) int {
+	matches := func(i int) bool {
+		if i < 0 || i+len(block) > len(lines) {
+			return false
+		}
+		for j, line := range block {
+			if lines[i+j] != line {
+				return false
+			}
+		}
+		return true
+	}
+	for d := 0; expected-d >= 0 || expected+d <= len(lines); d++ {
+		if matches(expected - d) {
+			return expected - d
+		}
+		if d > 0 && matches(expected+d) {
+			return expected + d
+		}
+	}
+	return -1
+}
+
+// treeFile is the planned state of a file during ApplyTree.
+type treeFile struct {
+	content string
+	mode    os.FileMode
+	exists  bool
+}
+
+// ApplyTree applies files to the directory tree at root.  Paths in the
+// patch are relative to root and may not leave it.  Like patch -p, strip
+// removes that many leading components from the paths of files that don't
+// come from git patches, whose a/ and b/ are already gone; a file diff
+// that is neither a creation, deletion, rename nor copy changes whichever
+// of its old and new files exists in place, as patch(1) does.  Either all
+// files are updated or, if a hunk fails to apply or a file is missing,
+// none are and the error is returned; a *HunkError names the hunks that
+// failed.
+func (dmp *DiffMatchPatch) ApplyTree(root string, files []FileDiff, strip int) error {
+	planned := map[string]*treeFile{}
+	var order []string
+	load := func(name string) (*treeFile, error) {
+		path, err := treePath(root, name)
+		if err != nil {
+			return nil, err
+		}
+		if f, ok := planned[path]; ok {
+			return f, nil
+		}
+		f := &treeFile{mode: 0644}
+		if info, err := os.Stat(path); err == nil {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			f.content, f.mode, f.exists = string(data), info.Mode().Perm(), true
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+		planned[path] = f
+		order = append(order, path)
+		return f, nil
+	}
+
+	for i := range files {
+		fd := &files[i]
+		if fd.IsBinary {
+			return errors.New("diffmatchpatch: binary patch for " + fd.NewName + " is not supported")
+		}
+		oldName, newName := fd.OldName, fd.NewName
+		if !fd.IsGit {
+			var err error
+			if oldName, err = stripPath(oldName, strip); err != nil {
+				return err
+			}
+			if newName, err = stripPath(newName, strip); err != nil {
+				return err
+			}
+		}
+		if !fd.IsNew && !fd.IsDelete && !fd.IsRename && !fd.IsCopy && oldName != newName {
+			name, err := patchTarget(load, oldName, newName)
+			if err != nil {
+				return err
+			}
+			oldName, newName = name, name
+		}
+		var src *treeFile
+		var err error
+		if fd.IsNew {
+			src = &treeFile{mode: 0644}
+		} else {
+			if src, err = load(oldName); err != nil {
+				return err
+			}
+			if !src.exists {
+				return errors.New("diffmatchpatch: " + oldName + " does not exist")
+			}
+		}
+
+		content, applied := dmp.ApplyFileDiff(fd, src.content)
+		var failed []int
+		for x, ok := range applied {
+			if !ok {
+				failed = append(failed, x)
+			}
+		}
+		if len(failed) != 0 {
+			name := oldName
+			if len(name) == 0 {
+				name = newName
+			}
+			return &HunkError{name, failed}
+		}
+
+		mode := src.mode
+		if fd.NewMode != 0 {
+			mode = os.FileMode(fd.NewMode).Perm()
+		}
+		if fd.IsDelete {
+			src.exists = false
+			continue
+		}
+		dst, err := load(newName)
+		if err != nil {
+			return err
+		}
+		if fd.IsNew && dst.exists {
+			return errors.New("diffmatchpatch: " + newName + " already exists")
+		}
+		if fd.IsRename && dst != src {
+			src.exists = false
+		}
+		dst.content, dst.mode, dst.exists = content, mode, true
+	}
+
+	// Everything applied, write the results.
+	for _, path := range order {
+		f := planned[path]
+		if !f.exists {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, []byte(f.content), f.mode); err != nil {
+			return err
+		}
+		if err := os.Chmod(path, f.mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// patchTarget chooses the file an ordinary diff changes as patch(1) does:
+// whichever of oldName and newName exists or, if both do, the one with
+// the fewest path components, then the shortest base name, then the
+// shortest path.  oldName is returned when neither exists.
+func patchTarget(load func(string) (*treeFile, error), oldName, newName string) (string, error) {
+	oldFile, err := load(oldName)
+	if err != nil {
+		return "", err
+	}
+	newFile, err := load(newName)
+	if err != nil {
+		return "", err
+	}
+	if !newFile.exists || oldFile == newFile {
+		return oldName, nil
+	} else if !oldFile.exists {
+		return newName, nil
+	}
+	oldParts, newParts := strings.Count(oldName, "/"), strings.Count(newName, "/")
+	oldBase, newBase := path.Base(oldName), path.Base(newName)
+	switch {
+	case oldParts != newParts:
+		if newParts < oldParts {
+			return newName, nil
+		}
+	case len(oldBase) != len(newBase):
+		if len(newBase) < len(oldBase) {
+			return newName, nil
+		}
+	case len(newName) < len(oldName):
+		return newName, nil
+	}
+	return oldName, nil
+}
+
+// stripPath removes the first n components of the slash separated path
+// name, as patch -p does.
+func stripPath(name string, n int) (string, error) {
+	stripped := name
+	for i := 0; i < n && len(stripped) != 0; i++ {
+		slash := strings.IndexByte(stripped, '/')
+		if slash < 0 {
+			return "", fmt.Errorf("diffmatchpatch: cannot strip %d components from %s", n, name)
+		}
+		stripped = strings.TrimLeft(stripped[slash+1:], "/")
+	}
+	return stripped, nil
+}
+
+// treePath returns the path of name below root, refusing names that
+// would leave it.
+func treePath(root, name string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if len(name) == 0 || filepath.IsAbs(clean) || clean == ".." ||
+		strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", errors.New("diffmatchpatch: invalid path in patch: " + strconv.Quote(name))
+	}
+	return filepath.Join(root, clean), nil
+}
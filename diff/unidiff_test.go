@@ -0,0 +1,217 @@
+package diffmatchpatch
+
+import (
+	"github.com/bmizerany/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const gitPatch = `commit message lines are skipped
+
+diff --git a/README b/README.md
+similarity index 100%
+rename from README
+rename to README.md
+diff --git a/gone.txt b/gone.txt
+deleted file mode 100644
+index 286c5f5..0000000
+--- a/gone.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-gone
+diff --git a/main.go b/main.go
+index d6e0156..4a73987 100644
+--- a/main.go
++++ b/main.go
+@@ -1,5 +1,5 @@ package main
+ package main
+
+ func main() {
+-	println("hi")
++	println("hello")
+ }
+diff --git a/new.txt b/new.txt
+new file mode 100644
+index 0000000..5786b13
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1,2 @@
++brand
++new
+diff --git a/notes one.txt b/notes one.txt
+index 0addb3c..03d21c6 100644
+--- a/notes one.txt
++++ b/notes one.txt
+@@ -1,2 +1,3 @@
+ keep
+ this
++more
+\ No newline at end of file
+diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+diff --git "a/tab\there" "b/tab\there"
+index 0addb3c..03d21c6 100644
+--- "a/tab\there"
++++ "b/tab\there"
+@@ -1 +1 @@
+-x
++y
+`
+
+func Test_parseUnifiedDiff(t *testing.T) {
+	files, err := ParseUnifiedDiff(gitPatch)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 7, len(files))
+
+	assert.Equal(t, FileDiff{OldName: "README", NewName: "README.md", IsRename: true, IsGit: true}, files[0])
+	assert.Equal(t, FileDiff{OldName: "gone.txt", OldMode: 0100644, IsDelete: true, IsGit: true,
+		Hunks: []Hunk{{1, 1, 0, 0, "", []Diff{Diff{DiffDelete, "gone\n"}}}}}, files[1])
+	assert.Equal(t, FileDiff{OldName: "main.go", NewName: "main.go", OldMode: 0100644, NewMode: 0100644, IsGit: true,
+		Hunks: []Hunk{{1, 5, 1, 5, "package main", []Diff{
+			Diff{DiffEqual, "package main\n\nfunc main() {\n"},
+			Diff{DiffDelete, "\tprintln(\"hi\")\n"},
+			Diff{DiffInsert, "\tprintln(\"hello\")\n"},
+			Diff{DiffEqual, "}\n"}}}}}, files[2])
+	assert.Equal(t, FileDiff{NewName: "new.txt", NewMode: 0100644, IsNew: true, IsGit: true,
+		Hunks: []Hunk{{0, 0, 1, 2, "", []Diff{Diff{DiffInsert, "brand\nnew\n"}}}}}, files[3])
+	assert.Equal(t, "notes one.txt", files[4].OldName)
+	assert.Equal(t, "notes one.txt", files[4].NewName)
+	assert.Equal(t, []Diff{Diff{DiffEqual, "keep\nthis\n"}, Diff{DiffInsert, "more"}}, files[4].Hunks[0].Diffs)
+	assert.Equal(t, FileDiff{OldName: "run.sh", NewName: "run.sh", OldMode: 0100644, NewMode: 0100755, IsGit: true}, files[5])
+	assert.Equal(t, "tab\there", files[6].OldName)
+	assert.Equal(t, "tab\there", files[6].NewName)
+
+	// The output of diff -u keeps its paths and drops the timestamps.
+	dmp := createDMP()
+	a, b := "one\ntwo\nthree\n", "one\n2\nthree"
+	files, err = ParseUnifiedDiff(dmp.UnifiedDiff(a, b, UnifiedOptions{FromFile: "a/x.txt", ToFile: "x.txt"}))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []FileDiff{{OldName: "a/x.txt", NewName: "x.txt",
+		Hunks: []Hunk{{1, 3, 1, 3, "", []Diff{
+			Diff{DiffEqual, "one\n"},
+			Diff{DiffDelete, "two\nthree\n"},
+			Diff{DiffInsert, "2\nthree"}}}}}}, files)
+
+	for _, text := range []string{
+		"--- a\n+++ b\n@@ -1,2 +1,2 @@\n-x\n+y\n",
+		"--- a\n+++ b\n@@ -1 +1 @@\n-x\n-y\n+z\n",
+		"--- a\n+++ b\n@@ -1 +1 @@\n*x\n+y\n",
+		"--- a\n+++ b\n@@ -x +1 @@\n-x\n+y\n",
+		"@@ -1 +1 @@\n-x\n+y\n",
+		"diff --git a/x b/x\nold mode 10064z\n",
+		"--- \"a\\q\"\n+++ b\n",
+	} {
+		_, err := ParseUnifiedDiff(text)
+		assert.NotEqual(t, nil, err, text)
+	}
+}
+
+func Test_applyFileDiff(t *testing.T) {
+	dmp := createDMP()
+	a := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	b := strings.Replace(strings.Replace(a, "two", "2", 1), "nine", "9", 1)
+	files, _ := ParseUnifiedDiff(dmp.UnifiedDiff(a, b, UnifiedOptions{Context: 1}))
+	fd := &files[0]
+	assert.Equal(t, 2, len(fd.Hunks))
+
+	text, applied := dmp.ApplyFileDiff(fd, a)
+	assert.Equal(t, b, text)
+	assert.Equal(t, []bool{true, true}, applied)
+
+	// Lines added above move the hunks.
+	text, applied = dmp.ApplyFileDiff(fd, "zero\nzero\n"+a)
+	assert.Equal(t, "zero\nzero\n"+b, text)
+	assert.Equal(t, []bool{true, true}, applied)
+
+	// Changed context is matched fuzzily.
+	text, applied = dmp.ApplyFileDiff(fd, strings.Replace(a, "ten", "TEN", 1))
+	assert.Equal(t, strings.Replace(b, "ten", "TEN", 1), text)
+	assert.Equal(t, []bool{true, true}, applied)
+
+	// A hunk that matches nowhere fails alone.
+	text, applied = dmp.ApplyFileDiff(fd, strings.Replace(a, "eight\nnine\nten\n", "", 1))
+	assert.Equal(t, strings.Replace(b, "eight\n9\nten\n", "", 1), text)
+	assert.Equal(t, []bool{true, false}, applied)
+}
+
+func Test_applyTree(t *testing.T) {
+	dmp := createDMP()
+	root, err := ioutil.TempDir("", "unidiff")
+	assert.Equal(t, nil, err)
+	defer os.RemoveAll(root)
+
+	write := func(name, content string, mode os.FileMode) {
+		assert.Equal(t, nil, ioutil.WriteFile(filepath.Join(root, name), []byte(content), mode))
+	}
+	read := func(name string) string {
+		data, err := ioutil.ReadFile(filepath.Join(root, name))
+		if err != nil {
+			return "<" + err.Error() + ">"
+		}
+		return string(data)
+	}
+	write("main.go", "package main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n", 0644)
+	write("README", "old readme\n", 0644)
+	write("notes one.txt", "keep\nthis\n", 0644)
+	write("run.sh", "#!/bin/sh\necho run\n", 0644)
+	write("gone.txt", "gone\n", 0644)
+	write("tab\there", "x\n", 0644)
+
+	files, _ := ParseUnifiedDiff(gitPatch)
+
+	// A failing hunk leaves the tree untouched.
+	broken := append([]FileDiff(nil), files...)
+	broken[2].Hunks = []Hunk{{1, 1, 1, 1, "", []Diff{Diff{DiffDelete, "absent\n"}, Diff{DiffInsert, "present\n"}}}}
+	err = dmp.ApplyTree(root, broken, 1)
+	assert.Equal(t, &HunkError{"main.go", []int{0}}, err)
+	assert.Equal(t, "old readme\n", read("README"))
+	assert.Equal(t, "gone\n", read("gone.txt"))
+
+	assert.Equal(t, nil, dmp.ApplyTree(root, files, 1))
+	assert.Equal(t, "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n", read("main.go"))
+	assert.Equal(t, "old readme\n", read("README.md"))
+	assert.Equal(t, true, strings.HasPrefix(read("README"), "<"))
+	assert.Equal(t, true, strings.HasPrefix(read("gone.txt"), "<"))
+	assert.Equal(t, "brand\nnew\n", read("new.txt"))
+	assert.Equal(t, "keep\nthis\nmore", read("notes one.txt"))
+	assert.Equal(t, "y\n", read("tab\there"))
+	info, err := os.Stat(filepath.Join(root, "run.sh"))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+	// Creating a file twice or patching a missing one fails.
+	assert.NotEqual(t, nil, dmp.ApplyTree(root, files[3:4], 1))
+	assert.NotEqual(t, nil, dmp.ApplyTree(root, files[1:2], 1))
+
+	for _, name := range []string{"../escape", "/etc/passwd", "a/../../escape"} {
+		err := dmp.ApplyTree(root, []FileDiff{{NewName: name, IsNew: true,
+			Hunks: []Hunk{{0, 0, 1, 1, "", []Diff{Diff{DiffInsert, "x\n"}}}}}}, 0)
+		assert.NotEqual(t, nil, err, name)
+	}
+	assert.NotEqual(t, nil, dmp.ApplyTree(root, []FileDiff{{OldName: "main.go", NewName: "main.go", IsBinary: true}}, 0))
+
+	// The paths of diff -ru a b lose their first component with a strip
+	// of 1.
+	a := "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n"
+	b := strings.Replace(a, "hello", "bye", 1)
+	files, _ = ParseUnifiedDiff(dmp.UnifiedDiff(a, b, UnifiedOptions{FromFile: "a/main.go", ToFile: "b/main.go"}))
+	assert.NotEqual(t, nil, dmp.ApplyTree(root, files, 0))
+	assert.NotEqual(t, nil, dmp.ApplyTree(root, files, 2))
+	assert.Equal(t, nil, dmp.ApplyTree(root, files, 1))
+	assert.Equal(t, b, read("main.go"))
+
+	// diff -u x.orig x changes x in place, or x.orig if it is alone.
+	files, _ = ParseUnifiedDiff(dmp.UnifiedDiff(b, a, UnifiedOptions{FromFile: "main.go.orig", ToFile: "main.go"}))
+	assert.Equal(t, nil, dmp.ApplyTree(root, files, 0))
+	assert.Equal(t, a, read("main.go"))
+	assert.Equal(t, true, strings.HasPrefix(read("main.go.orig"), "<"))
+	write("main.go.orig", b, 0644)
+	assert.Equal(t, nil, os.Remove(filepath.Join(root, "main.go")))
+	assert.Equal(t, nil, dmp.ApplyTree(root, files, 0))
+	assert.Equal(t, a, read("main.go.orig"))
+	assert.Equal(t, true, strings.HasPrefix(read("main.go"), "<"))
+}
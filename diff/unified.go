@@ -0,0 +1,196 @@
+package diffmatchpatch
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UnifiedOptions controls the output of UnifiedDiff and DiffToContextDiff.
+type UnifiedOptions struct {
+	// FromFile and ToFile name the texts in the "---" and "+++" headers.
+	// They default to "a" and "b".
+	FromFile, ToFile string
+	// FromTime and ToTime are written after the file names.  The zero
+	// Time leaves the timestamp out.
+	FromTime, ToTime time.Time
+	// Context is the number of unchanged lines shown around each change.
+	// Zero means the customary 3, a negative value means none.  Changes
+	// separated by no more than twice this many lines share a hunk.
+	Context int
+}
+
+// unifiedTimeLayout is the timestamp format of GNU diff -u.
+const unifiedTimeLayout = "2006-01-02 15:04:05.000000000 -0700"
+
+// unifiedLine is one line of the diff with the operation that produced it.
+type unifiedLine struct {
+	op   int8
+	text string
+}
+
+// UnifiedDiff returns the line-by-line differences between a and b in the
+// unified format understood by patch and git apply.  It returns the empty
+// string if a and b are equal.
+func (dmp *DiffMatchPatch) UnifiedDiff(a, b string, opts UnifiedOptions) string {
+	if a == b {
+		return ""
+	}
+	lines := dmp.diffLines(a, b)
+
+	var out bytes.Buffer
+	out.WriteString(fileHeader("---", opts.FromFile, "a", opts.FromTime, unifiedTimeLayout))
+	out.WriteString(fileHeader("+++", opts.ToFile, "b", opts.ToTime, unifiedTimeLayout))
+
+	for _, h := range groupHunks(lines, opts.context()) {
+		writeHunk(&out, h.lines, h.start1, h.start2)
+	}
+	return out.String()
+}
+
+// diffLines diffs a and b line by line and returns the resulting lines.
+func (dmp *DiffMatchPatch) diffLines(a, b string) []unifiedLine {
+	chars1, chars2, _, lines1, lines2 := dmp.diffLinesToChars(a, b)
+	return splitDiffLines(diffCharsToTokens(dmp.DiffMain(chars1, chars2, false), lines1, lines2))
+}
+
+// splitDiffLines splits line-mode diffs into their lines.
+func splitDiffLines(diffs []Diff) []unifiedLine {
+	var lines []unifiedLine
+	for _, aDiff := range diffs {
+		text := aDiff.Text
+		for len(text) != 0 {
+			n := strings.IndexByte(text, '\n') + 1
+			if n == 0 {
+				n = len(text)
+			}
+			lines = append(lines, unifiedLine{aDiff.Type, text[:n]})
+			text = text[n:]
+		}
+	}
+	return lines
+}
+
+// lineHunk is a run of lines shown together; start1 and start2 are the
+// number of lines of either text that precede it.
+type lineHunk struct {
+	start1, start2 int
+	lines          []unifiedLine
+}
+
+// groupHunks cuts lines into hunks with up to context unchanged lines
+// around each change.  Changes separated by no more than 2*context lines
+// share a hunk.
+func groupHunks(lines []unifiedLine, context int) []lineHunk {
+	var hunks []lineHunk
+	// line1 and line2 count the lines of a and b before lines[i].
+	line1, line2 := 0, 0
+	for i := 0; i < len(lines); {
+		if lines[i].op == DiffEqual {
+			line1++
+			line2++
+			i++
+			continue
+		}
+		// A change at i.  Back up over the leading context, then extend
+		// the hunk until the changes are more than 2*context lines apart.
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		start1, start2 := line1-(i-start), line2-(i-start)
+		end := i
+		for j := i; j < len(lines); j++ {
+			if lines[j].op != DiffEqual {
+				end = j + 1
+			} else if j-end >= 2*context {
+				break
+			}
+		}
+		stop := end + context
+		if stop > len(lines) {
+			stop = len(lines)
+		}
+		hunks = append(hunks, lineHunk{start1, start2, lines[start:stop]})
+		for _, l := range lines[i:stop] {
+			if l.op != DiffInsert {
+				line1++
+			}
+			if l.op != DiffDelete {
+				line2++
+			}
+		}
+		i = stop
+	}
+	return hunks
+}
+
+// context returns the number of context lines opts asks for.
+func (opts *UnifiedOptions) context() int {
+	if opts.Context == 0 {
+		return 3
+	} else if opts.Context < 0 {
+		return 0
+	}
+	return opts.Context
+}
+
+// fileHeader formats the line naming one of the texts, giving its time in
+// layout.
+func fileHeader(prefix, name, fallback string, t time.Time, layout string) string {
+	if len(name) == 0 {
+		name = fallback
+	}
+	if t.IsZero() {
+		return prefix + " " + name + "\n"
+	}
+	return prefix + " " + name + "\t" + t.Format(layout) + "\n"
+}
+
+// writeHunk writes lines as one hunk; start1 and start2 are the number of
+// lines of either text that precede it.
+func writeHunk(out *bytes.Buffer, lines []unifiedLine, start1, start2 int) {
+	length1, length2 := 0, 0
+	for _, l := range lines {
+		if l.op != DiffInsert {
+			length1++
+		}
+		if l.op != DiffDelete {
+			length2++
+		}
+	}
+	out.WriteString("@@ -" + unifiedRange(start1, length1) + " +" + unifiedRange(start2, length2) + " @@\n")
+	for _, l := range lines {
+		switch l.op {
+		case DiffInsert:
+			writeLine(out, "+", l.text)
+		case DiffDelete:
+			writeLine(out, "-", l.text)
+		default:
+			writeLine(out, " ", l.text)
+		}
+	}
+}
+
+// writeLine writes prefix and text, marking a text without a line break
+// the way diff does.
+func writeLine(out *bytes.Buffer, prefix, text string) {
+	out.WriteString(prefix)
+	out.WriteString(text)
+	if !strings.HasSuffix(text, "\n") {
+		out.WriteString("\n\\ No newline at end of file\n")
+	}
+}
+
+// unifiedRange formats a hunk range the way GNU diff does: an empty range
+// is given by the line before it, a single line by its number alone.
+func unifiedRange(start, length int) string {
+	switch length {
+	case 0:
+		return strconv.Itoa(start) + ",0"
+	case 1:
+		return strconv.Itoa(start + 1)
+	}
+	return strconv.Itoa(start+1) + "," + strconv.Itoa(length)
+}
@@ -0,0 +1,65 @@
+package diffmatchpatch
+
+import (
+	"github.com/bmizerany/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_unifiedDiff(t *testing.T) {
+	dmp := createDMP()
+	a := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven\ntwelve\n"
+	b := strings.Replace(a, "two\n", "2\n", 1) + "thirteen"
+
+	stamp := time.Date(2026, 10, 17, 0, 19, 12, 364048800, time.UTC)
+	assert.Equal(t, "--- a\t2026-10-17 00:19:12.364048800 +0000\n"+
+		"+++ b\t2026-10-17 00:19:12.364048800 +0000\n"+
+		"@@ -1,5 +1,5 @@\n"+
+		" one\n"+
+		"-two\n"+
+		"+2\n"+
+		" three\n"+
+		" four\n"+
+		" five\n"+
+		"@@ -10,3 +10,4 @@\n"+
+		" ten\n"+
+		" eleven\n"+
+		" twelve\n"+
+		"+thirteen\n"+
+		"\\ No newline at end of file\n",
+		dmp.UnifiedDiff(a, b, UnifiedOptions{FromTime: stamp, ToTime: stamp}))
+
+	assert.Equal(t, "--- old.txt\n"+
+		"+++ new.txt\n"+
+		"@@ -1,3 +1,3 @@\n"+
+		" one\n"+
+		"-two\n"+
+		"+2\n"+
+		" three\n"+
+		"@@ -12 +12,2 @@\n"+
+		" twelve\n"+
+		"+thirteen\n"+
+		"\\ No newline at end of file\n",
+		dmp.UnifiedDiff(a, b, UnifiedOptions{FromFile: "old.txt", ToFile: "new.txt", Context: 1}))
+
+	// Changes close enough together share a hunk.
+	assert.Equal(t, "--- a\n"+
+		"+++ b\n"+
+		"@@ -1,12 +1,13 @@\n",
+		strings.SplitAfter(dmp.UnifiedDiff(a, b, UnifiedOptions{Context: 5}), "@@\n")[0])
+
+	// Without context.
+	assert.Equal(t, "--- a\n"+
+		"+++ b\n"+
+		"@@ -2 +2 @@\n"+
+		"-two\n"+
+		"+2\n"+
+		"@@ -12,0 +13 @@\n"+
+		"+thirteen\n"+
+		"\\ No newline at end of file\n",
+		dmp.UnifiedDiff(a, b, UnifiedOptions{Context: -1}))
+
+	assert.Equal(t, "--- a\n+++ b\n@@ -1 +0,0 @@\n-x\n", dmp.UnifiedDiff("x\n", "", UnifiedOptions{}))
+	assert.Equal(t, "", dmp.UnifiedDiff(a, a, UnifiedOptions{}))
+}
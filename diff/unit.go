@@ -0,0 +1,111 @@
+package diffmatchpatch
+
+import (
+	"errors"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Unit selects how lengths and offsets in text are counted when they are
+// exchanged with other programs.
+type Unit int
+
+const (
+	// UnitByte counts bytes of UTF-8, the native indexing of Go strings.
+	UnitByte Unit = iota
+	// UnitRune counts Unicode code points.
+	UnitRune
+	// UnitUTF16 counts UTF-16 code units, as the JavaScript, Java and C#
+	// ports of diff-match-patch do.  Characters outside the Basic
+	// Multilingual Plane count as two.
+	UnitUTF16
+)
+
+// unitLen returns the length of text counted in unit.
+func unitLen(text string, unit Unit) int {
+	switch unit {
+	case UnitRune:
+		return utf8.RuneCountInString(text)
+	case UnitUTF16:
+		n := 0
+		for _, r := range text {
+			n += utf16Len(r)
+		}
+		return n
+	}
+	return len(text)
+}
+
+// unitOffset returns the byte offset of the position n units into text.
+// It fails if text is shorter than n units or if the position falls
+// inside a character.
+func unitOffset(text string, n int, unit Unit) (int, error) {
+	if n < 0 {
+		return 0, errors.New("Negative offset: " + strconv.Itoa(n))
+	}
+	if unit == UnitByte {
+		if n > len(text) {
+			return 0, errors.New("Offset " + strconv.Itoa(n) + " beyond text of length " + strconv.Itoa(len(text)))
+		}
+		return n, nil
+	}
+	i := 0
+	for n > 0 {
+		if i == len(text) {
+			return 0, errors.New("Offset beyond end of text by " + strconv.Itoa(n) + " units")
+		}
+		r, size := utf8.DecodeRuneInString(text[i:])
+		step := 1
+		if unit == UnitUTF16 {
+			step = utf16Len(r)
+		}
+		if step > n {
+			return 0, errors.New("Offset falls inside the character at byte " + strconv.Itoa(i))
+		}
+		n -= step
+		i += size
+	}
+	return i, nil
+}
+
+// unitFloor returns the byte offset of the position n units into text,
+// moved back to the start of the character it falls inside and limited to
+// the text.
+func unitFloor(text string, n int, unit Unit) int {
+	if n <= 0 {
+		return 0
+	}
+	if unit == UnitByte {
+		if n > len(text) {
+			return len(text)
+		}
+		for !runeBoundary(text, n) {
+			n--
+		}
+		return n
+	}
+	for i, r := range text {
+		step := 1
+		if unit == UnitUTF16 {
+			step = utf16Len(r)
+		}
+		if step > n {
+			return i
+		}
+		n -= step
+	}
+	return len(text)
+}
+
+// runeBoundary reports whether offset i of text is at the start of a
+// character or the end of the text.
+func runeBoundary(text string, i int) bool {
+	return i == len(text) || utf8.RuneStart(text[i])
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 && r <= utf8.MaxRune {
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,117 @@
+package diffmatchpatch
+
+import (
+	"encoding/json"
+	"github.com/bmizerany/assert"
+	"io/ioutil"
+	"testing"
+)
+
+// conformanceCase is an entry of testdata/delta_conformance.json.  The
+// expected deltas and patch texts follow the JavaScript port: inserted
+// text is escaped with encodeURI and, for utf16, lengths and offsets count
+// UTF-16 code units.
+type conformanceCase struct {
+	Name       string
+	Text1      string
+	Text2      string
+	Diffs      []Diff
+	Delta      map[string]string
+	PatchUTF16 string `json:"patch_utf16"`
+}
+
+var conformanceUnits = map[string]Unit{
+	"byte":  UnitByte,
+	"rune":  UnitRune,
+	"utf16": UnitUTF16,
+}
+
+func Test_deltaConformance(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/delta_conformance.json")
+	assert.Equal(t, nil, err)
+	var cases []conformanceCase
+	assert.Equal(t, nil, json.Unmarshal(data, &cases))
+
+	dmp := createDMP()
+	for _, c := range cases {
+		assert.Equal(t, c.Text1, dmp.DiffText1(c.Diffs), c.Name)
+		assert.Equal(t, c.Text2, dmp.DiffText2(c.Diffs), c.Name)
+
+		for name, unit := range conformanceUnits {
+			assert.Equal(t, c.Delta[name], dmp.DiffToDeltaUnit(c.Diffs, unit), c.Name, name)
+			diffs, err := dmp.DiffFromDeltaUnit(c.Text1, c.Delta[name], unit)
+			assert.Equal(t, nil, err, c.Name, name)
+			assert.Equal(t, c.Diffs, diffs, c.Name, name)
+		}
+
+		// Patches from the JavaScript port round-trip byte for byte and
+		// apply cleanly.
+		patches, err := dmp.PatchFromTextUnit(c.PatchUTF16, c.Text1, UnitUTF16)
+		assert.Equal(t, nil, err, c.Name)
+		text, err := dmp.PatchToTextUnit(patches, c.Text1, UnitUTF16)
+		assert.Equal(t, nil, err, c.Name)
+		assert.Equal(t, c.PatchUTF16, text, c.Name)
+		for _, patch := range patches {
+			assert.Equal(t, nil, patch.Validate(), c.Name)
+		}
+		result, applied := dmp.PatchApply(patches, c.Text1)
+		assert.Equal(t, c.Text2, result, c.Name)
+		for _, ok := range applied {
+			assert.T(t, ok, c.Name)
+		}
+
+		// So do patches made here.
+		patches = dmp.PatchMakeFromTextAndDiffs(c.Text1, c.Diffs)
+		text, err = dmp.PatchToTextUnit(patches, c.Text1, UnitUTF16)
+		assert.Equal(t, nil, err, c.Name)
+		parsed, err := dmp.PatchFromTextUnit(text, c.Text1, UnitUTF16)
+		assert.Equal(t, nil, err, c.Name)
+		assert.Equal(t, dmp.PatchToText(patches), dmp.PatchToText(parsed), c.Name)
+	}
+}
+
+func Test_deltaUnits(t *testing.T) {
+	dmp := createDMP()
+	diffs := []Diff{
+		Diff{DiffEqual, "a\U0001F600"},
+		Diff{DiffDelete, "é"},
+		Diff{DiffInsert, "b"}}
+	assert.Equal(t, "=5\t-2\t+b", dmp.DiffToDeltaUnit(diffs, UnitByte))
+	assert.Equal(t, "=2\t-1\t+b", dmp.DiffToDeltaUnit(diffs, UnitRune))
+	assert.Equal(t, "=3\t-1\t+b", dmp.DiffToDeltaUnit(diffs, UnitUTF16))
+	assert.Equal(t, dmp.DiffToDeltaUnit(diffs, UnitRune), dmp.DiffToDelta(diffs))
+
+	// Lengths that end inside a character are rejected.
+	_, err := dmp.DiffFromDeltaUnit("\U0001F600", "=1", UnitUTF16)
+	assert.NotEqual(t, nil, err)
+	_, err = dmp.DiffFromDeltaUnit("\U0001F600", "=2", UnitUTF16)
+	assert.Equal(t, nil, err)
+	_, err = dmp.DiffFromDeltaUnit("é", "=1\t-1", UnitByte)
+	assert.Equal(t, nil, err, "bytes are taken as given")
+	_, err = dmp.DiffFromDeltaUnit("é", "=2", UnitRune)
+	assert.NotEqual(t, nil, err)
+}
+
+func Test_patchTextUnitsSplit(t *testing.T) {
+	dmp := createDMP()
+	dmp.MatchMaxBits = 32
+	text1 := "été 1234567890123456789012345678901234567890123456789012345678901234567890 \U0001F600"
+	text2 := "\U0001F601 abcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuv é"
+	patches := dmp.PatchSplitMax(dmp.PatchMake(text1, text2))
+	assert.T(t, len(patches) > 1)
+
+	// The pieces of a split patch have start1 != start2, which has to be
+	// carried over to the other unit too.
+	for _, unit := range []Unit{UnitRune, UnitUTF16} {
+		text, err := dmp.PatchToTextUnit(patches, text1, unit)
+		assert.Equal(t, nil, err)
+		parsed, err := dmp.PatchFromTextUnit(text, text1, unit)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, dmp.PatchToText(patches), dmp.PatchToText(parsed))
+	}
+
+	_, err := dmp.PatchFromTextUnit("@@ -1 +1 @@\n-x\n+y\n", "\U0001F600x", UnitUTF16)
+	assert.Equal(t, nil, err)
+	_, err = dmp.PatchFromTextUnit("@@ -2 +2 @@\n-x\n+y\n", "\U0001F600x", UnitUTF16)
+	assert.NotEqual(t, nil, err)
+}
@@ -0,0 +1,471 @@
+package diffmatchpatch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/adler32"
+	"strconv"
+)
+
+// VCDIFF (RFC 3284) header and indicator bits.
+var vcdMagic = []byte{0xd6, 0xc3, 0xc4, 0x00}
+
+const (
+	// Hdr_Indicator.
+	vcdDecompress = 0x01
+	vcdCodeTable  = 0x02
+	vcdAppHeader  = 0x04
+	// Win_Indicator.  vcdAdler32 is the xdelta3 extension for a checksum
+	// of the target window.
+	vcdSource  = 0x01
+	vcdTarget  = 0x02
+	vcdAdler32 = 0x04
+)
+
+// Instruction types and the sizes of the address cache of the default code
+// table.
+const (
+	vcdNoop = iota
+	vcdAdd
+	vcdRun
+	vcdCopy
+
+	vcdNear = 4
+	vcdSame = 3
+)
+
+// vcdInstruction is an entry of a code table: up to two instructions with
+// their sizes (0 if the size follows in the instruction section) and the
+// addressing modes of copies.
+type vcdInstruction struct {
+	type1, size1, mode1 byte
+	type2, size2, mode2 byte
+}
+
+// vcdDefaultTable is the default code table of RFC 3284 section 5.6.
+var vcdDefaultTable = func() [256]vcdInstruction {
+	var table [256]vcdInstruction
+	table[0] = vcdInstruction{type1: vcdRun}
+	i := 1
+	for size := 0; size <= 17; size++ {
+		table[i] = vcdInstruction{type1: vcdAdd, size1: byte(size)}
+		i++
+	}
+	for mode := 0; mode <= 8; mode++ {
+		table[i] = vcdInstruction{type1: vcdCopy, mode1: byte(mode)}
+		i++
+		for size := 4; size <= 18; size++ {
+			table[i] = vcdInstruction{type1: vcdCopy, size1: byte(size), mode1: byte(mode)}
+			i++
+		}
+	}
+	for mode := 0; mode <= 8; mode++ {
+		maxCopy := 6
+		if mode > 5 {
+			maxCopy = 4
+		}
+		for add := 1; add <= 4; add++ {
+			for size := 4; size <= maxCopy; size++ {
+				table[i] = vcdInstruction{vcdAdd, byte(add), 0, vcdCopy, byte(size), byte(mode)}
+				i++
+			}
+		}
+	}
+	for mode := 0; mode <= 8; mode++ {
+		table[i] = vcdInstruction{vcdCopy, 4, byte(mode), vcdAdd, 1, 0}
+		i++
+	}
+	return table
+}()
+
+// vcdCodes maps the instructions of the default code table to their codes.
+var vcdCodes = func() map[vcdInstruction]byte {
+	codes := make(map[vcdInstruction]byte, 256)
+	for i, inst := range vcdDefaultTable {
+		codes[inst] = byte(i)
+	}
+	return codes
+}()
+
+// vcdCache is the address cache of RFC 3284 section 5.1.
+type vcdCache struct {
+	near     [vcdNear]int
+	nextSlot int
+	same     [vcdSame * 256]int
+}
+
+func (c *vcdCache) update(addr int) {
+	c.near[c.nextSlot] = addr
+	c.nextSlot = (c.nextSlot + 1) % vcdNear
+	c.same[addr%(vcdSame*256)] = addr
+}
+
+// encode picks the mode for addr at position here as the sample encoder
+// of RFC 3284 section 5.3 does and returns it with the encoded address.
+func (c *vcdCache) encode(addr, here int) (byte, int) {
+	best, mode := addr, 0
+	if d := here - addr; d < best {
+		best, mode = d, 1
+	}
+	for i, near := range c.near {
+		if d := addr - near; d >= 0 && d < best {
+			best, mode = d, i+2
+		}
+	}
+	if d := addr % (vcdSame * 256); c.same[d] == addr {
+		best, mode = d%256, vcdNear+2+d/256
+	}
+	c.update(addr)
+	return byte(mode), best
+}
+
+// vcdOp is an instruction of a delta before it is coded.
+type vcdOp struct {
+	typ  byte
+	size int
+	addr int    // for copies
+	data string // for adds and runs
+}
+
+// DiffToVCDIFF encodes diffs as a VCDIFF delta in the format of RFC 3284
+// with the default code table, to be applied to the source text.
+// Equalities become copies from the source, insertions adds or runs.
+func (dmp *DiffMatchPatch) DiffToVCDIFF(diffs []Diff) []byte {
+	// Turn the diffs into instructions.  Equalities too short to be worth
+	// a copy are added instead.
+	var ops []vcdOp
+	add := func(text string) {
+		if n := len(ops); n > 0 && ops[n-1].typ == vcdAdd {
+			ops[n-1].data += text
+			ops[n-1].size += len(text)
+		} else {
+			ops = append(ops, vcdOp{typ: vcdAdd, size: len(text), data: text})
+		}
+	}
+	source := 0
+	for _, aDiff := range diffs {
+		switch aDiff.Type {
+		case DiffInsert:
+			if len(aDiff.Text) >= 4 && isRun(aDiff.Text) {
+				ops = append(ops, vcdOp{typ: vcdRun, size: len(aDiff.Text), data: aDiff.Text[:1]})
+			} else if len(aDiff.Text) != 0 {
+				add(aDiff.Text)
+			}
+		case DiffEqual:
+			if len(aDiff.Text) < 4 {
+				add(aDiff.Text)
+			} else {
+				ops = append(ops, vcdOp{typ: vcdCopy, size: len(aDiff.Text), addr: source})
+			}
+			source += len(aDiff.Text)
+		case DiffDelete:
+			source += len(aDiff.Text)
+		}
+	}
+
+	// Code the instructions, pairing them where the code table allows.
+	var data, inst, addrs bytes.Buffer
+	var cache vcdCache
+	here := source
+	target := 0
+	for i := 0; i < len(ops); i++ {
+		op := ops[i]
+		var mode byte
+		var addr int
+		if op.typ == vcdCopy {
+			mode, addr = cache.encode(op.addr, here+target)
+		}
+		first := vcdInstruction{type1: op.typ, mode1: mode}
+		if op.typ != vcdRun && op.size <= 18 {
+			first.size1 = byte(op.size)
+		}
+		code, ok := vcdCodes[first]
+		if !ok {
+			first.size1 = 0
+			code = vcdCodes[first]
+		}
+		target += op.size
+
+		// Try to pair a small add with the following copy, or a copy with
+		// the following one byte add.
+		explicitSize := first.size1 == 0 && op.typ != vcdNoop
+		if !explicitSize && i+1 < len(ops) && ops[i+1].typ != vcdRun && ops[i+1].size <= 18 {
+			next := ops[i+1]
+			var nextMode byte
+			var nextAddr int
+			var c vcdCache
+			if next.typ == vcdCopy {
+				c = cache
+				nextMode, nextAddr = c.encode(next.addr, here+target)
+			}
+			pair := first
+			pair.type2, pair.size2, pair.mode2 = next.typ, byte(next.size), nextMode
+			if pairCode, ok := vcdCodes[pair]; ok && pair.type1 != pair.type2 {
+				inst.WriteByte(pairCode)
+				writeVCDOp(&data, &addrs, op, mode, addr)
+				if next.typ == vcdCopy {
+					cache = c
+				}
+				writeVCDOp(&data, &addrs, next, nextMode, nextAddr)
+				target += next.size
+				i++
+				continue
+			}
+		}
+		inst.WriteByte(code)
+		if first.size1 == 0 {
+			writeVarint(&inst, op.size)
+		}
+		writeVCDOp(&data, &addrs, op, mode, addr)
+	}
+
+	// A single window with the whole source as its source segment.
+	var delta bytes.Buffer
+	writeVarint(&delta, target)
+	delta.WriteByte(0) // Delta_Indicator
+	writeVarint(&delta, data.Len())
+	writeVarint(&delta, inst.Len())
+	writeVarint(&delta, addrs.Len())
+	delta.Write(data.Bytes())
+	delta.Write(inst.Bytes())
+	delta.Write(addrs.Bytes())
+
+	var out bytes.Buffer
+	out.Write(vcdMagic)
+	out.WriteByte(0) // Hdr_Indicator
+	if len(ops) == 0 {
+		return out.Bytes()
+	}
+	if source > 0 {
+		out.WriteByte(vcdSource)
+		writeVarint(&out, source)
+		writeVarint(&out, 0)
+	} else {
+		out.WriteByte(0)
+	}
+	writeVarint(&out, delta.Len())
+	out.Write(delta.Bytes())
+	return out.Bytes()
+}
+
+// isRun reports whether text repeats a single byte.
+func isRun(text string) bool {
+	for i := 1; i < len(text); i++ {
+		if text[i] != text[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// writeVCDOp writes the data or address of an instruction.
+func writeVCDOp(data, addrs *bytes.Buffer, op vcdOp, mode byte, addr int) {
+	switch op.typ {
+	case vcdAdd, vcdRun:
+		data.WriteString(op.data)
+	case vcdCopy:
+		if mode >= vcdNear+2 {
+			addrs.WriteByte(byte(addr))
+		} else {
+			writeVarint(addrs, addr)
+		}
+	}
+}
+
+// writeVarint writes n as a VCDIFF integer: base 128, most significant
+// digit first.
+func writeVarint(out *bytes.Buffer, n int) {
+	var digits [binary.MaxVarintLen64]byte
+	i := len(digits) - 1
+	digits[i] = byte(n & 0x7f)
+	for n >>= 7; n > 0; n >>= 7 {
+		i--
+		digits[i] = byte(n&0x7f) | 0x80
+	}
+	out.Write(digits[i:])
+}
+
+// vcdReader reads the parts of a VCDIFF delta.
+type vcdReader struct {
+	b   []byte
+	err error
+}
+
+func (r *vcdReader) fail(msg string) {
+	if r.err == nil {
+		r.err = errors.New("Invalid VCDIFF delta: " + msg)
+	}
+}
+
+func (r *vcdReader) readByte() byte {
+	if len(r.b) == 0 {
+		r.fail("unexpected end")
+		return 0
+	}
+	c := r.b[0]
+	r.b = r.b[1:]
+	return c
+}
+
+func (r *vcdReader) readBytes(n int) []byte {
+	if n < 0 || n > len(r.b) {
+		r.fail("unexpected end")
+		r.b = nil
+		return nil
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+// varint reads a VCDIFF integer, which must fit in 31 bits.
+func (r *vcdReader) readVarint() int {
+	n := 0
+	for i := 0; i < 5; i++ {
+		c := r.readByte()
+		n = n<<7 | int(c&0x7f)
+		if c&0x80 == 0 {
+			if n > 1<<31-1 {
+				break
+			}
+			return n
+		}
+	}
+	r.fail("integer too large")
+	return 0
+}
+
+// ApplyVCDIFF decodes a VCDIFF delta (RFC 3284) and applies it to source.
+// Deltas with the default code table are supported, including the
+// application header and the Adler-32 window checksum, an extension of the
+// format by xdelta3; secondary compression and custom code tables are not.
+func (dmp *DiffMatchPatch) ApplyVCDIFF(source, delta []byte) ([]byte, error) {
+	r := &vcdReader{b: delta}
+	if !bytes.Equal(r.readBytes(4), vcdMagic) {
+		return nil, errors.New("Invalid VCDIFF delta: bad header")
+	}
+	indicator := r.readByte()
+	if indicator&(vcdDecompress|vcdCodeTable) != 0 {
+		return nil, errors.New("Unsupported VCDIFF delta: secondary compression or code table")
+	}
+	if indicator&vcdAppHeader != 0 {
+		r.readBytes(r.readVarint())
+	}
+
+	var target []byte
+	for r.err == nil && len(r.b) != 0 {
+		target = r.window(source, target)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return target, nil
+}
+
+// window decodes a window and returns target with its output appended.
+func (r *vcdReader) window(source, target []byte) []byte {
+	indicator := r.readByte()
+	var segment []byte
+	if indicator&(vcdSource|vcdTarget) != 0 {
+		size, pos := r.readVarint(), r.readVarint()
+		from := source
+		if indicator&vcdTarget != 0 {
+			from = target
+		}
+		if indicator&(vcdSource|vcdTarget) == vcdSource|vcdTarget || pos > len(from) || size > len(from)-pos {
+			r.fail("bad source segment")
+			return target
+		}
+		segment = from[pos : pos+size]
+	}
+	encoding := &vcdReader{b: r.readBytes(r.readVarint())}
+	size := encoding.readVarint()
+	if encoding.readByte() != 0 {
+		r.fail("compressed sections are not supported")
+		return target
+	}
+	dataLen, instLen, addrLen := encoding.readVarint(), encoding.readVarint(), encoding.readVarint()
+	var checksum []byte
+	if indicator&vcdAdler32 != 0 {
+		checksum = encoding.readBytes(4)
+	}
+	data := &vcdReader{b: encoding.readBytes(dataLen)}
+	inst := &vcdReader{b: encoding.readBytes(instLen)}
+	addrs := &vcdReader{b: encoding.readBytes(addrLen)}
+	if encoding.err != nil {
+		r.err = encoding.err
+		return target
+	}
+
+	start := len(target)
+	var cache vcdCache
+	for inst.err == nil && len(inst.b) != 0 {
+		entry := vcdDefaultTable[inst.readByte()]
+		for _, half := range [2][3]byte{
+			{entry.type1, entry.size1, entry.mode1},
+			{entry.type2, entry.size2, entry.mode2},
+		} {
+			typ, n, mode := half[0], int(half[1]), half[2]
+			if typ == vcdNoop {
+				continue
+			}
+			if n == 0 {
+				n = inst.readVarint()
+			}
+			if len(target)-start+n > size {
+				r.fail("window overflows its size")
+				return target
+			}
+			switch typ {
+			case vcdAdd:
+				target = append(target, data.readBytes(n)...)
+			case vcdRun:
+				c := data.readByte()
+				for k := 0; k < n; k++ {
+					target = append(target, c)
+				}
+			case vcdCopy:
+				// Addresses count from the start of the source segment,
+				// followed by the target window so far.
+				here := len(segment) + len(target) - start
+				var addr int
+				switch {
+				case mode == 0:
+					addr = addrs.readVarint()
+				case mode == 1:
+					addr = here - addrs.readVarint()
+				case mode < vcdNear+2:
+					addr = cache.near[mode-2] + addrs.readVarint()
+				default:
+					addr = cache.same[int(mode-vcdNear-2)*256+int(addrs.readByte())]
+				}
+				if addr < 0 || addr >= here {
+					r.fail("bad copy address " + strconv.Itoa(addr))
+					return target
+				}
+				cache.update(addr)
+				for k := 0; k < n; k++ {
+					// Copies from the target may overlap their output.
+					if addr+k < len(segment) {
+						target = append(target, segment[addr+k])
+					} else {
+						target = append(target, target[start+addr+k-len(segment)])
+					}
+				}
+			}
+		}
+	}
+	for _, part := range []*vcdReader{data, inst, addrs} {
+		if part.err != nil {
+			r.err = part.err
+			return target
+		}
+	}
+	if len(target)-start != size {
+		r.fail("window shorter than its size")
+	} else if checksum != nil && binary.BigEndian.Uint32(checksum) != adler32.Checksum(target[start:]) {
+		r.fail("checksum mismatch")
+	}
+	return target
+}
@@ -0,0 +1,192 @@
+package diffmatchpatch
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"github.com/bmizerany/assert"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// vcdiffCase is an entry of testdata/vcdiff.json.  The deltas were
+// assembled by hand following RFC 3284, not produced by another encoder:
+// "rfc3284" encodes the example of its section 3 with the default code
+// table, "checksum" is the same delta with an application header and an
+// Adler-32 window checksum, and "target window" copies from the target of
+// an earlier window.
+type vcdiffCase struct {
+	Name   string
+	Source string
+	Target string
+	Delta  string
+}
+
+func Test_applyVCDIFF(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/vcdiff.json")
+	assert.Equal(t, nil, err)
+	var cases []vcdiffCase
+	assert.Equal(t, nil, json.Unmarshal(data, &cases))
+
+	dmp := createDMP()
+	for _, c := range cases {
+		delta, err := hex.DecodeString(c.Delta)
+		assert.Equal(t, nil, err, c.Name)
+		target, err := dmp.ApplyVCDIFF([]byte(c.Source), delta)
+		assert.Equal(t, nil, err, c.Name)
+		assert.Equal(t, c.Target, string(target), c.Name)
+	}
+}
+
+func Test_diffToVCDIFF(t *testing.T) {
+	dmp := createDMP()
+	// Copy 4 bytes through the same cache, add 4 bytes paired with a copy
+	// from address 4, run 4 bytes.
+	diffs := []Diff{
+		Diff{DiffEqual, "abcd"},
+		Diff{DiffInsert, "wxyz"},
+		Diff{DiffEqual, "efgh"},
+		Diff{DiffDelete, "ijklmnop"},
+		Diff{DiffInsert, "zzzz"}}
+	delta := dmp.DiffToVCDIFF(diffs)
+	assert.Equal(t, "d6c3c40000011000101000050402"+"7778797a7a"+"74ac0004"+"0004", hex.EncodeToString(delta))
+	target, err := dmp.ApplyVCDIFF([]byte(dmp.DiffText1(diffs)), delta)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, dmp.DiffText2(diffs), string(target))
+
+	// Nothing to encode.
+	target, err = dmp.ApplyVCDIFF(nil, dmp.DiffToVCDIFF(nil))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(target))
+
+	// Random edits of random bytes round-trip.
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		a := make([]byte, r.Intn(3000))
+		r.Read(a)
+		for k := range a {
+			a[k] %= 4
+		}
+		b := append([]byte(nil), a...)
+		for k := 0; k < 10 && len(b) != 0; k++ {
+			pos := r.Intn(len(b))
+			switch r.Intn(3) {
+			case 0:
+				b = append(b[:pos], b[pos+r.Intn(len(b)-pos):]...)
+			case 1:
+				b = append(b[:pos], append(make([]byte, r.Intn(30)), b[pos:]...)...)
+			default:
+				b[pos] ^= 0xff
+			}
+		}
+		target, err := dmp.ApplyVCDIFF(a, dmp.DiffToVCDIFF(dmp.DiffBytes(a, b)))
+		assert.Equal(t, nil, err)
+		assert.Equal(t, string(b), string(target))
+	}
+}
+
+func Test_applyVCDIFFErrors(t *testing.T) {
+	dmp := createDMP()
+	valid, _ := hex.DecodeString("d6c3c40000011000121c000505037778797a7a74ac2c0004000404")
+	source := []byte("abcdefghijklmnop")
+	corrupt := func(pos int, c byte) []byte {
+		delta := append([]byte(nil), valid...)
+		delta[pos] = c
+		return delta
+	}
+	for name, delta := range map[string][]byte{
+		"magic":           corrupt(0, 'V'),
+		"secondary":       corrupt(4, vcdDecompress),
+		"code table":      corrupt(4, vcdCodeTable),
+		"truncated":       valid[:len(valid)-1],
+		"source segment":  corrupt(6, 17),
+		"target size":     corrupt(9, 0x1b),
+		"compressed":      corrupt(10, 1),
+		"copy address":    corrupt(len(valid)-1, 30),
+		"instruction run": corrupt(len(valid)-4, 5),
+	} {
+		_, err := dmp.ApplyVCDIFF(source, delta)
+		assert.NotEqual(t, nil, err, name)
+	}
+
+	// A checksum that doesn't match the target.
+	delta, _ := hex.DecodeString("d6c3c40000051000161c00050503000000007778797a7a74ac2c0004000404")
+	_, err := dmp.ApplyVCDIFF(source, delta)
+	assert.NotEqual(t, nil, err)
+}
+
+// Test_vcdiffTools exchanges deltas with xdelta3 and the vcdiff command of
+// open-vcdiff, when they are installed.
+func Test_vcdiffTools(t *testing.T) {
+	tools := []struct {
+		name           string
+		encode, decode []string // Arguments around source, target and delta.
+	}{
+		{"xdelta3", []string{"-e", "-f", "-S", "none", "-s", "SOURCE", "TARGET", "DELTA"},
+			[]string{"-d", "-f", "-s", "SOURCE", "DELTA", "TARGET"}},
+		{"vcdiff", []string{"encode", "-dictionary", "SOURCE", "-target", "TARGET", "-delta", "DELTA"},
+			[]string{"decode", "-dictionary", "SOURCE", "-delta", "DELTA", "-target", "TARGET"}},
+	}
+	dir, err := ioutil.TempDir("", "vcdiff")
+	assert.Equal(t, nil, err)
+	defer os.RemoveAll(dir)
+	files := strings.NewReplacer("SOURCE", filepath.Join(dir, "source"),
+		"TARGET", filepath.Join(dir, "target"), "DELTA", filepath.Join(dir, "delta"))
+	run := func(tool string, args []string) error {
+		for i := range args {
+			args[i] = files.Replace(args[i])
+		}
+		out, err := exec.Command(tool, args...).CombinedOutput()
+		if err != nil {
+			t.Log(tool, string(out))
+		}
+		return err
+	}
+	write := func(name string, data []byte) {
+		assert.Equal(t, nil, ioutil.WriteFile(filepath.Join(dir, name), data, 0644))
+	}
+	read := func(name string) []byte {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		assert.Equal(t, nil, err)
+		return data
+	}
+
+	dmp := createDMP()
+	r := rand.New(rand.NewSource(1))
+	found := false
+	for _, tool := range tools {
+		if _, err := exec.LookPath(tool.name); err != nil {
+			continue
+		}
+		found = true
+		for i := 0; i < 20; i++ {
+			a := []byte(strings.Repeat("abcdefghijklmnop", r.Intn(200)))
+			b := append([]byte(nil), a...)
+			for k := 0; k < 10 && len(b) != 0; k++ {
+				pos := r.Intn(len(b))
+				b = append(b[:pos], append([]byte("wxyz"), b[pos+r.Intn(len(b)-pos)/4:]...)...)
+			}
+
+			// The tool's delta decodes here.
+			write("source", a)
+			write("target", b)
+			assert.Equal(t, nil, run(tool.name, append([]string(nil), tool.encode...)), tool.name)
+			target, err := dmp.ApplyVCDIFF(a, read("delta"))
+			assert.Equal(t, nil, err, tool.name)
+			assert.Equal(t, string(b), string(target), tool.name)
+
+			// And ours decodes with the tool.
+			write("delta", dmp.DiffToVCDIFF(dmp.DiffBytes(a, b)))
+			write("target", nil)
+			assert.Equal(t, nil, run(tool.name, append([]string(nil), tool.decode...)), tool.name)
+			assert.Equal(t, string(b), string(read("target")), tool.name)
+		}
+	}
+	if !found {
+		t.Skip("neither xdelta3 nor vcdiff is installed")
+	}
+}
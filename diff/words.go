@@ -0,0 +1,137 @@
+package diffmatchpatch
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultWordRegexp makes words of runs of characters other than
+// whitespace, as git diff --word-diff does by default.
+var defaultWordRegexp = regexp.MustCompile(`\S+`)
+
+// maxTokens is the number of distinct tokens that can be given a
+// character of their own.
+var maxTokens = lineIndex(utf8.MaxRune) + 1
+
+// DiffWordsToChars splits two texts into words and reduces them to strings
+// where each Unicode character represents one word, the way
+// DiffLinesToChars does for lines.  Words are the matches of wordRegexp, or
+// runs of characters other than whitespace if it is nil.  The text between
+// two words is a token of its own, so DiffCharsToLines restores the texts
+// completely.  A Folder folds the tokens as it does lines.
+func (dmp *DiffMatchPatch) DiffWordsToChars(text1, text2 string, wordRegexp *regexp.Regexp) (string, string, []string) {
+	chars1, chars2, tokenArray, _, _ := dmp.diffWordsToChars(text1, text2, wordRegexp)
+	return chars1, chars2, tokenArray
+}
+
+// diffWordsToChars is DiffWordsToChars also returning the tokens of either
+// text, for diffCharsToTokens.
+func (dmp *DiffMatchPatch) diffWordsToChars(text1, text2 string, wordRegexp *regexp.Regexp) (string, string, []string, []string, []string) {
+	if wordRegexp == nil {
+		wordRegexp = defaultWordRegexp
+	}
+	// Index 0 is left unused as by DiffLinesToChars.
+	tokenArray := []string{""}
+	tokenHash := map[string]int{}
+
+	chars1, tokens1 := dmp.diffWordsToCharsMunge(text1, wordRegexp, &tokenArray, tokenHash)
+	chars2, tokens2 := dmp.diffWordsToCharsMunge(text2, wordRegexp, &tokenArray, tokenHash)
+	return chars1, chars2, tokenArray, tokens1, tokens2
+}
+
+// diffWordsToCharsMunge splits text into words and the text between them
+// and returns the string of their characters and the tokens.  Once the
+// characters run out the rest of the text becomes one token.
+func (dmp *DiffMatchPatch) diffWordsToCharsMunge(text string, wordRegexp *regexp.Regexp, tokenArray *[]string, tokenHash map[string]int) (string, []string) {
+	var runes []rune
+	var tokens []string
+	add := func(token string) bool {
+		if len(token) == 0 {
+			return true
+		}
+		// Keep a character for the rest of either text.
+		if _, ok := tokenHash[dmp.lineKey(token)]; !ok && len(*tokenArray) >= maxTokens-2 {
+			return false
+		}
+		runes = append(runes, dmp.tokenRune(token, tokenArray, tokenHash))
+		tokens = append(tokens, token)
+		return true
+	}
+	start := 0
+	for _, loc := range wordRegexp.FindAllStringIndex(text, -1) {
+		if !add(text[start:loc[0]]) {
+			break
+		}
+		start = loc[0]
+		if !add(text[loc[0]:loc[1]]) {
+			break
+		}
+		start = loc[1]
+	}
+	if start < len(text) {
+		runes = append(runes, dmp.tokenRune(text[start:], tokenArray, tokenHash))
+		tokens = append(tokens, text[start:])
+	}
+	return string(runes), tokens
+}
+
+// DiffWords finds the differences between two texts word by word.  Words
+// are the matches of wordRegexp, or runs of characters other than
+// whitespace if it is nil; see DiffWordsToChars.  The differences never
+// start or end inside a word.
+func (dmp *DiffMatchPatch) DiffWords(text1, text2 string, wordRegexp *regexp.Regexp) []Diff {
+	chars1, chars2, _, tokens1, tokens2 := dmp.diffWordsToChars(text1, text2, wordRegexp)
+	return diffCharsToTokens(dmp.DiffMain(chars1, chars2, false), tokens1, tokens2)
+}
+
+// WordDiffFormat selects the output of DiffToWordDiff.
+type WordDiffFormat int
+
+const (
+	// WordDiffPlain marks deletions [-like this-] and insertions
+	// {+like this+}, as git diff --word-diff=plain.
+	WordDiffPlain WordDiffFormat = iota
+	// WordDiffPorcelain writes each part on a line of its own, prefixed
+	// with " ", "-" or "+", and line breaks as a line holding "~", as git
+	// diff --word-diff=porcelain.
+	WordDiffPorcelain
+	// WordDiffColor shows deletions in red and insertions in green using
+	// ANSI escape sequences, as git diff --word-diff=color.
+	WordDiffColor
+)
+
+// DiffToWordDiff renders diffs in one of the formats of git diff
+// --word-diff.  Plain and colour output are those of a TerminalRenderer,
+// which offers more control.
+func (dmp *DiffMatchPatch) DiffToWordDiff(diffs []Diff, format WordDiffFormat) string {
+	var out bytes.Buffer
+	switch format {
+	case WordDiffPorcelain:
+		for _, aDiff := range diffs {
+			prefix := " "
+			switch aDiff.Type {
+			case DiffInsert:
+				prefix = "+"
+			case DiffDelete:
+				prefix = "-"
+			}
+			for i, part := range strings.Split(aDiff.Text, "\n") {
+				if i > 0 {
+					out.WriteString("~\n")
+				}
+				if len(part) != 0 {
+					out.WriteString(prefix + part + "\n")
+				}
+			}
+		}
+	case WordDiffColor:
+		r := &TerminalRenderer{Palette: DefaultPalette}
+		_ = r.Render(&out, diffs)
+	default:
+		r := &TerminalRenderer{NoColor: true}
+		_ = r.Render(&out, diffs)
+	}
+	return out.String()
+}
@@ -0,0 +1,79 @@
+package diffmatchpatch
+
+import (
+	"github.com/bmizerany/assert"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func Test_diffWordsToChars(t *testing.T) {
+	dmp := createDMP()
+	chars1, chars2, tokenArray := dmp.DiffWordsToChars("the cat  sat", "the  cat", nil)
+	assert.Equal(t, "\u0001\u0002\u0003\u0004\u0005", chars1)
+	assert.Equal(t, "\u0001\u0004\u0003", chars2)
+	assert.Equal(t, []string{"", "the", " ", "cat", "  ", "sat"}, tokenArray)
+
+	// A custom regexp; leading and trailing text are tokens too.
+	chars1, _, tokenArray = dmp.DiffWordsToChars(" a.b(c) ", "", regexp.MustCompile(`\w+|[^\w\s]`))
+	assert.Equal(t, "\u0001\u0002\u0003\u0004\u0005\u0006\u0007\u0001", chars1)
+	assert.Equal(t, []string{"", " ", "a", ".", "b", "(", "c", ")"}, tokenArray)
+
+	diffs := dmp.DiffCharsToLines([]Diff{Diff{DiffEqual, chars1}}, tokenArray)
+	assert.Equal(t, " a.b(c) ", diffs[0].Text)
+}
+
+func Test_diffWordsToCharsLimit(t *testing.T) {
+	dmp := createDMP()
+	defer func(n int) { maxTokens = n }(maxTokens)
+	maxTokens = 6
+
+	// Once the characters run out the rest of a text is one token.
+	chars1, chars2, tokenArray := dmp.DiffWordsToChars("a b c d e", "a x y", nil)
+	assert.Equal(t, "\u0001\u0002\u0003\u0002\u0004", chars1)
+	assert.Equal(t, "\u0001\u0002\u0005", chars2)
+	assert.Equal(t, []string{"", "a", " ", "b", "c d e", "x y"}, tokenArray)
+	diffs := dmp.DiffCharsToLines([]Diff{Diff{DiffEqual, chars1}, Diff{DiffInsert, chars2}}, tokenArray)
+	assert.Equal(t, "a b c d e", diffs[0].Text)
+	assert.Equal(t, "a x y", diffs[1].Text)
+}
+
+func Test_diffWords(t *testing.T) {
+	dmp := createDMP()
+	text1 := "The quick brown fox\njumps over the dog."
+	text2 := "The quick red fox\njumped over the lazy dog."
+	diffs := dmp.DiffWords(text1, text2, nil)
+	assert.Equal(t, []Diff{
+		Diff{DiffEqual, "The quick "},
+		Diff{DiffDelete, "brown"},
+		Diff{DiffInsert, "red"},
+		Diff{DiffEqual, " fox\n"},
+		Diff{DiffDelete, "jumps"},
+		Diff{DiffInsert, "jumped"},
+		Diff{DiffEqual, " over the"},
+		Diff{DiffInsert, " lazy"},
+		Diff{DiffEqual, " dog."}}, diffs)
+	assert.Equal(t, text1, dmp.DiffText1(diffs))
+	assert.Equal(t, text2, dmp.DiffText2(diffs))
+
+	// Words never break apart.
+	for _, aDiff := range dmp.DiffWords("unbelievable", "believable", nil) {
+		assert.Equal(t, false, strings.Contains(aDiff.Text, "believable") && aDiff.Type == DiffEqual)
+	}
+
+	assert.Equal(t, "The quick [-brown-]{+red+} fox\n[-jumps-]{+jumped+} over the{+ lazy+} dog.",
+		dmp.DiffToWordDiff(diffs, WordDiffPlain))
+	assert.Equal(t, " The quick \n"+
+		"-brown\n"+
+		"+red\n"+
+		"  fox\n"+
+		"~\n"+
+		"-jumps\n"+
+		"+jumped\n"+
+		"  over the\n"+
+		"+ lazy\n"+
+		"  dog.\n", dmp.DiffToWordDiff(diffs, WordDiffPorcelain))
+	assert.Equal(t, "The quick \x1b[31mbrown\x1b[0m\x1b[32mred\x1b[0m fox\n"+
+		"\x1b[31mjumps\x1b[0m\x1b[32mjumped\x1b[0m over the\x1b[32m lazy\x1b[0m dog.",
+		dmp.DiffToWordDiff(diffs, WordDiffColor))
+}